@@ -0,0 +1,5 @@
+CREATE TABLE card_name_cache (
+  `name` VARCHAR(255) PRIMARY KEY,
+  `cardId` CHAR(36) NOT NULL,
+  `updatedAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
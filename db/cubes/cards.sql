@@ -15,5 +15,8 @@ CREATE TABLE cards (
   `exp` VARCHAR(31) NOT NULL,
   `release_date` DATE NOT NULL,
   `image_url` VARCHAR(512) NOT NULL,
+  `image_ref` VARCHAR(71),
+  `mtgo_id` int,
+  `collector_number` VARCHAR(16),
   KEY `name` (`name`)
 );
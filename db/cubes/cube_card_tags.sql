@@ -0,0 +1,9 @@
+CREATE TABLE cube_card_tags (
+  `cubeId` CHAR(36) NOT NULL,
+  `versionNumber` int NOT NULL,
+  `board` VARCHAR(32) NOT NULL DEFAULT 'mainboard',
+  `cardId` CHAR(36) NOT NULL,
+  `tag` VARCHAR(64) NOT NULL,
+  PRIMARY KEY (`cubeId`, `versionNumber`, `board`, `cardId`, `tag`),
+  KEY `tag` (`cubeId`, `versionNumber`, `tag`)
+);
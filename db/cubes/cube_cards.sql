@@ -1,7 +1,8 @@
 CREATE TABLE cube_cards (
   `cubeId` CHAR(36) NOT NULL,
   `versionNumber` int NOT NULL,
+  `board` VARCHAR(32) NOT NULL DEFAULT 'mainboard',
   `cardId` char(36) not null,
   `count` int not null,
-  PRIMARY KEY (`cubeId`, `versionNumber`, `cardId`)
+  PRIMARY KEY (`cubeId`, `versionNumber`, `board`, `cardId`)
 );
@@ -1,4 +1,7 @@
 CREATE TABLE custom_cards (
   `imageUrl` varchar(255) PRIMARY KEY,
-  `cardId` CHAR(36) NOT NULL
+  `cardId` CHAR(36) NOT NULL,
+  `verified` tinyint(1) NOT NULL DEFAULT 0,
+  `phash` BIGINT UNSIGNED,
+  KEY `cardId` (`cardId`)
 );
@@ -1,5 +1,7 @@
 CREATE TABLE deck_cards (
   `deckId` CHAR(36) NOT NULL,
+  `board` VARCHAR(8) NOT NULL DEFAULT 'main',
   `cardId` CHAR(36) NOT NULL,
-  PRIMARY KEY (`deckId`, `cardId`)
+  `count` int NOT NULL DEFAULT 1,
+  PRIMARY KEY (`deckId`, `board`, `cardId`)
 );
@@ -0,0 +1,10 @@
+CREATE TABLE llm_cache (
+  `key` CHAR(64) PRIMARY KEY,
+  `model` VARCHAR(128) NOT NULL,
+  `tool` VARCHAR(64) NOT NULL,
+  `response` MEDIUMTEXT NOT NULL,
+  `createdAt` TIMESTAMP NOT NULL,
+  `hits` int NOT NULL DEFAULT 0,
+  `misses` int NOT NULL DEFAULT 0,
+  `lastHitAt` TIMESTAMP NULL
+);
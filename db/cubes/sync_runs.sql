@@ -0,0 +1,11 @@
+CREATE TABLE sync_runs (
+  `id` CHAR(36) PRIMARY KEY,
+  `cubeId` CHAR(36) NOT NULL,
+  `source` VARCHAR(32) NOT NULL,
+  `startedAt` TIMESTAMP NOT NULL,
+  `finishedAt` TIMESTAMP NOT NULL,
+  `outcome` VARCHAR(16) NOT NULL,
+  `versionNumber` int,
+  `error` TEXT NOT NULL,
+  KEY `cubeId` (`cubeId`, `startedAt`)
+);
@@ -0,0 +1,43 @@
+package cubes
+
+import "strings"
+
+// CardStats summarises the curve, colour balance and type breakdown of a set of cards
+type CardStats struct {
+	Count      int            `json:"count"`
+	Curve      map[int]int    `json:"curve"`
+	Colors     map[Color]int  `json:"colors"`
+	Colorless  int            `json:"colorless"`
+	Multicolor int            `json:"multicolor"`
+	Types      map[string]int `json:"types"`
+}
+
+// Stats computes CardStats for cards. Lands are left out of the curve. Multicolored cards count once toward each of
+// their colors as well as toward Multicolor.
+func Stats(cards []Card) CardStats {
+	stats := CardStats{
+		Curve:  make(map[int]int),
+		Colors: make(map[Color]int),
+		Types:  make(map[string]int),
+	}
+	for _, card := range cards {
+		stats.Count++
+		for _, t := range strings.Fields(card.Type) {
+			stats.Types[t]++
+		}
+		if !strings.Contains(card.Type, "Land") {
+			stats.Curve[card.ManaValue]++
+		}
+		switch len(card.Colors) {
+		case 0:
+			stats.Colorless++
+		case 1:
+		default:
+			stats.Multicolor++
+		}
+		for _, c := range card.Colors {
+			stats.Colors[c]++
+		}
+	}
+	return stats
+}
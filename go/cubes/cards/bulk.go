@@ -0,0 +1,250 @@
+package cards
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+const defaultBulkBatchSize = 1000
+
+// ScryfallBulkCardLoader loads cards from a Scryfall bulk data file on disk, such as default-cards or oracle-cards. The
+// file is streamed and cards are upserted in batches so memory stays bounded however large the file is.
+type ScryfallBulkCardLoader struct {
+	storage   cubes.Storage
+	path      string
+	batchSize int
+}
+
+type ScryfallBulkCardLoaderOpts func(*ScryfallBulkCardLoader)
+
+// BulkLoaderWithBatchSize sets how many cards are upserted at a time
+func BulkLoaderWithBatchSize(batchSize int) ScryfallBulkCardLoaderOpts {
+	return func(b *ScryfallBulkCardLoader) {
+		b.batchSize = batchSize
+	}
+}
+
+func NewScryfallBulkLoader(storage cubes.Storage, path string, opts ...ScryfallBulkCardLoaderOpts) *ScryfallBulkCardLoader {
+	loader := &ScryfallBulkCardLoader{
+		storage:   storage,
+		path:      path,
+		batchSize: defaultBulkBatchSize,
+	}
+	for _, opt := range opts {
+		opt(loader)
+	}
+	return loader
+}
+
+// LoadCards loads only the cards with the given IDs from the bulk file
+func (b *ScryfallBulkCardLoader) LoadCards(ctx context.Context, ids []string) (LoadReport, error) {
+	batch := newCardBatcher(b.storage, b.batchSize)
+	loaded, err := b.load(ctx, idFilter(ids), batch.add)
+	if err != nil {
+		return LoadReport{}, err
+	}
+	if err := batch.flush(ctx); err != nil {
+		return LoadReport{}, err
+	}
+	return loaded.report(ids), nil
+}
+
+// FetchCards reads the cards with the given IDs from the bulk file without storing them
+func (b *ScryfallBulkCardLoader) FetchCards(ctx context.Context, ids []string) ([]cubes.Card, LoadReport, error) {
+	var cards []cubes.Card
+	loaded, err := b.load(ctx, idFilter(ids), collectCards(&cards))
+	if err != nil {
+		return nil, LoadReport{}, err
+	}
+	return cards, loaded.report(ids), nil
+}
+
+// FetchCardsByName reads every printing in the bulk file of the named cards, matched ignoring case and by front face,
+// without storing them
+func (b *ScryfallBulkCardLoader) FetchCardsByName(ctx context.Context, names []string) ([]cubes.Card, error) {
+	var cards []cubes.Card
+	if _, err := b.load(ctx, nameFilter(names), collectCards(&cards)); err != nil {
+		return nil, err
+	}
+	return cards, nil
+}
+
+// LoadAll loads every card in the bulk file
+func (b *ScryfallBulkCardLoader) LoadAll(ctx context.Context) (LoadReport, error) {
+	batch := newCardBatcher(b.storage, b.batchSize)
+	loaded, err := b.load(ctx, nil, batch.add)
+	if err != nil {
+		return LoadReport{}, err
+	}
+	if err := batch.flush(ctx); err != nil {
+		return LoadReport{}, err
+	}
+	return loaded.report(nil), nil
+}
+
+// load streams the bulk file and passes every card filter keeps, or every card if filter is nil, to add
+func (b *ScryfallBulkCardLoader) load(ctx context.Context, filter cardFilter, add func(context.Context, cubes.Card) error) (*loadedIDs, error) {
+	f, err := os.Open(b.path)
+	if err != nil {
+		return nil, fmt.Errorf(`open bulk file: %w`, err)
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReaderSize(f, 1<<20))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf(`read bulk file: %w`, err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, fmt.Errorf(`bulk file should be a JSON array, found %v`, tok)
+	}
+
+	loaded := newLoadedIDs()
+	for dec.More() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var scryfallCard cubes.ScryfallCard
+		if err := dec.Decode(&scryfallCard); err != nil {
+			return nil, fmt.Errorf(`decode card: %w`, err)
+		}
+		if filter != nil && !filter(scryfallCard.ID, scryfallCard.Name) {
+			continue
+		}
+		card, err := scryfallCard.ToCard()
+		if err != nil {
+			loaded.failed(scryfallCard.ID)
+			continue
+		}
+		loaded.found(card.ID)
+		if err := add(ctx, card); err != nil {
+			return nil, err
+		}
+	}
+	return loaded, nil
+}
+
+// cardFilter picks the records a file loader passes on, by card ID and name
+type cardFilter func(id, name string) bool
+
+func idFilter(ids []string) cardFilter {
+	filter := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		filter[id] = struct{}{}
+	}
+	return func(id, _ string) bool {
+		_, ok := filter[id]
+		return ok
+	}
+}
+
+// nameFilter keeps cards whose front face matches one of names, ignoring case
+func nameFilter(names []string) cardFilter {
+	filter := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		filter[strings.ToLower(frontFace(name))] = struct{}{}
+	}
+	return func(_, name string) bool {
+		_, ok := filter[strings.ToLower(frontFace(name))]
+		return ok
+	}
+}
+
+// collectCards returns an add func for load that appends to cards
+func collectCards(cards *[]cubes.Card) func(context.Context, cubes.Card) error {
+	return func(_ context.Context, card cubes.Card) error {
+		*cards = append(*cards, card)
+		return nil
+	}
+}
+
+// cardBatcher collects cards and upserts them whenever a full batch is ready
+type cardBatcher struct {
+	storage cubes.Storage
+	size    int
+	cards   []cubes.Card
+}
+
+func newCardBatcher(storage cubes.Storage, size int) *cardBatcher {
+	return &cardBatcher{
+		storage: storage,
+		size:    size,
+		cards:   make([]cubes.Card, 0, size),
+	}
+}
+
+func (b *cardBatcher) add(ctx context.Context, card cubes.Card) error {
+	b.cards = append(b.cards, card)
+	if len(b.cards) >= b.size {
+		return b.flush(ctx)
+	}
+	return nil
+}
+
+func (b *cardBatcher) flush(ctx context.Context) error {
+	if len(b.cards) == 0 {
+		return nil
+	}
+	if err := b.storage.UpsertCards(ctx, b.cards); err != nil {
+		return fmt.Errorf(`upsert cards: %w`, err)
+	}
+	b.cards = b.cards[:0]
+	return nil
+}
+
+// loadedIDs tracks which IDs a file loader found and which failed to convert, in the order they were seen
+type loadedIDs struct {
+	status map[string]bool
+	order  []string
+}
+
+func newLoadedIDs() *loadedIDs {
+	return &loadedIDs{status: make(map[string]bool)}
+}
+
+func (l *loadedIDs) found(id string) {
+	l.set(id, true)
+}
+
+func (l *loadedIDs) failed(id string) {
+	l.set(id, false)
+}
+
+func (l *loadedIDs) set(id string, ok bool) {
+	if _, seen := l.status[id]; !seen {
+		l.order = append(l.order, id)
+	}
+	// A later printing that converts cleanly makes up for an earlier failure
+	l.status[id] = l.status[id] || ok
+}
+
+func (l *loadedIDs) seen(id string) bool {
+	_, ok := l.status[id]
+	return ok
+}
+
+// report accounts for the requested IDs, or for every ID seen if requested is nil
+func (l *loadedIDs) report(requested []string) LoadReport {
+	if requested == nil {
+		requested = l.order
+	}
+	var report LoadReport
+	for _, id := range requested {
+		ok, seen := l.status[id]
+		switch {
+		case !seen:
+			report.Missing = append(report.Missing, id)
+		case ok:
+			report.Found = append(report.Found, id)
+		default:
+			report.ConversionFailed = append(report.ConversionFailed, id)
+		}
+	}
+	return report
+}
@@ -0,0 +1,28 @@
+package cardstest
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// FixtureDeckName is the name of the deck in every deck fixture
+const FixtureDeckName = "Fixture Tempo"
+
+// DeckFixtures returns the deck fixture files keyed by file name. Each holds the same deck, built from the Scryfall
+// fixtures, in a different format: 6 maindeck and 2 sideboard cards across 4 and 2 lines.
+func DeckFixtures() (map[string][]byte, error) {
+	names, err := fs.Glob(fixtures, "fixtures/decks/*")
+	if err != nil {
+		return nil, fmt.Errorf(`glob deck fixtures: %w`, err)
+	}
+	decks := make(map[string][]byte, len(names))
+	for _, name := range names {
+		raw, err := fixtures.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf(`read %s: %w`, name, err)
+		}
+		decks[path.Base(name)] = raw
+	}
+	return decks, nil
+}
@@ -0,0 +1,306 @@
+// Package cardstest provides an offline stand-in for the Scryfall and CubeCobra APIs so the loaders in cards can be
+// exercised end to end without network access.
+package cardstest
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+//go:embed fixtures
+var fixtures embed.FS
+
+// FixtureCubeID is the ID of the CubeCobra cube served from the fixtures
+const FixtureCubeID = "fixture-cube"
+
+// Server serves the Scryfall /cards/collection and /cards/named endpoints and the CubeCobra cubeJSON endpoint from
+// fixtures. Point the loaders at it with cards.ScryfallLoaderWithBaseURL(srv.URL) and cards.CubeLoaderWithBaseURL(srv.URL).
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	cards    map[string]json.RawMessage
+	index    []indexedCard
+	cubes    map[string]json.RawMessage
+	failures []failure
+	requests int
+}
+
+// indexedCard holds the fields of a card that identifiers can match on
+type indexedCard struct {
+	id              string
+	names           []string
+	set             string
+	collectorNumber string
+}
+
+type failure struct {
+	status     int
+	retryAfter string
+}
+
+// NewServer starts a server loaded with the embedded fixtures. Close it when done.
+func NewServer() (*Server, error) {
+	s := &Server{
+		cards: make(map[string]json.RawMessage),
+		cubes: make(map[string]json.RawMessage),
+	}
+	if err := s.loadFixtures(); err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /cards/collection", s.handleCollection)
+	mux.HandleFunc("GET /cards/named", s.handleNamed)
+	mux.HandleFunc("GET /cube/api/cubeJSON/{id}", s.handleCube)
+	s.Server = httptest.NewServer(s.injectFailures(mux))
+	return s, nil
+}
+
+// FailNext makes the next n requests fail with status. retryAfter is sent as the Retry-After header when not empty.
+func (s *Server) FailNext(n int, status int, retryAfter string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, failure{status: status, retryAfter: retryAfter})
+	}
+}
+
+// Requests returns the number of requests the server has received, including failed ones
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) injectFailures(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+		var f *failure
+		if len(s.failures) > 0 {
+			f = &s.failures[0]
+			s.failures = s.failures[1:]
+		}
+		s.mu.Unlock()
+		if f == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if f.retryAfter != "" {
+			w.Header().Set("Retry-After", f.retryAfter)
+		}
+		writeError(w, f.status, "injected_failure", http.StatusText(f.status))
+	})
+}
+
+// AddCard serves a raw Scryfall card object in addition to the fixtures
+func (s *Server) AddCard(raw json.RawMessage) error {
+	var card struct {
+		ID              string `json:"id"`
+		Name            string `json:"name"`
+		Set             string `json:"set"`
+		CollectorNumber string `json:"collector_number"`
+		CardFaces       []struct {
+			Name string `json:"name"`
+		} `json:"card_faces"`
+	}
+	if err := json.Unmarshal(raw, &card); err != nil {
+		return fmt.Errorf(`unmarshal card: %w`, err)
+	}
+	indexed := indexedCard{
+		id:              card.ID,
+		names:           []string{card.Name},
+		set:             card.Set,
+		collectorNumber: card.CollectorNumber,
+	}
+	for _, face := range card.CardFaces {
+		indexed.names = append(indexed.names, face.Name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cards[card.ID] = raw
+	s.index = append(s.index, indexed)
+	return nil
+}
+
+// AddCube serves a raw CubeCobra cubeJSON response under cubeID
+func (s *Server) AddCube(cubeID string, raw json.RawMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cubes[cubeID] = raw
+}
+
+func (s *Server) loadFixtures() error {
+	raw, err := fixtures.ReadFile("fixtures/scryfall_cards.json")
+	if err != nil {
+		return fmt.Errorf(`read scryfall fixtures: %w`, err)
+	}
+	var cards []json.RawMessage
+	if err := json.Unmarshal(raw, &cards); err != nil {
+		return fmt.Errorf(`unmarshal scryfall fixtures: %w`, err)
+	}
+	for _, card := range cards {
+		if err := s.AddCard(card); err != nil {
+			return err
+		}
+	}
+
+	cubeFiles, err := fs.Glob(fixtures, "fixtures/cubecobra_*.json")
+	if err != nil {
+		return fmt.Errorf(`glob cube fixtures: %w`, err)
+	}
+	for _, name := range cubeFiles {
+		raw, err := fixtures.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf(`read %s: %w`, name, err)
+		}
+		cubeID := strings.TrimSuffix(strings.TrimPrefix(name, "fixtures/cubecobra_"), ".json")
+		s.AddCube(cubeID, raw)
+	}
+	return nil
+}
+
+type collectionRequest struct {
+	Identifiers []map[string]string `json:"identifiers"`
+}
+
+type collectionResponse struct {
+	Object   string              `json:"object"`
+	NotFound []map[string]string `json:"not_found"`
+	Data     []json.RawMessage   `json:"data"`
+}
+
+func (s *Server) handleCollection(w http.ResponseWriter, r *http.Request) {
+	var req collectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	if len(req.Identifiers) > 75 {
+		writeError(w, http.StatusUnprocessableEntity, "too_many_identifiers", "too many identifiers")
+		return
+	}
+	rsp := collectionResponse{
+		Object:   "list",
+		NotFound: []map[string]string{},
+		Data:     []json.RawMessage{},
+	}
+	s.mu.Lock()
+	for _, identifier := range req.Identifiers {
+		if id, ok := s.match(identifier); ok {
+			rsp.Data = append(rsp.Data, s.cards[id])
+		} else {
+			rsp.NotFound = append(rsp.NotFound, identifier)
+		}
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, rsp)
+}
+
+// match finds the card for a collection identifier. Callers must hold mu.
+func (s *Server) match(identifier map[string]string) (string, bool) {
+	for _, card := range s.index {
+		switch {
+		case identifier["id"] != "":
+			if card.id == identifier["id"] {
+				return card.id, true
+			}
+		case identifier["collector_number"] != "":
+			if strings.EqualFold(card.set, identifier["set"]) && card.collectorNumber == identifier["collector_number"] {
+				return card.id, true
+			}
+		case identifier["name"] != "":
+			if slices.ContainsFunc(card.names, func(n string) bool { return strings.EqualFold(n, identifier["name"]) }) &&
+				(identifier["set"] == "" || strings.EqualFold(card.set, identifier["set"])) {
+				return card.id, true
+			}
+		}
+	}
+	return "", false
+}
+
+// handleNamed approximates Scryfall's fuzzy search: a query matches every card whose name contains it once case and
+// punctuation are ignored
+func (s *Server) handleNamed(w http.ResponseWriter, r *http.Request) {
+	query := normalize(r.URL.Query().Get("fuzzy"))
+	set := r.URL.Query().Get("set")
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "bad_request", "fuzzy is required")
+		return
+	}
+	s.mu.Lock()
+	var matches []string
+	for _, card := range s.index {
+		if set != "" && !strings.EqualFold(card.set, set) {
+			continue
+		}
+		if slices.ContainsFunc(card.names, func(n string) bool { return strings.Contains(normalize(n), query) }) {
+			matches = append(matches, card.id)
+		}
+	}
+	var card json.RawMessage
+	if len(matches) == 1 {
+		card = s.cards[matches[0]]
+	}
+	s.mu.Unlock()
+
+	switch {
+	case len(matches) == 1:
+		writeJSON(w, http.StatusOK, card)
+	case len(matches) > 1:
+		writeJSON(w, http.StatusNotFound, map[string]any{
+			"object":  "error",
+			"code":    "not_found",
+			"type":    "ambiguous",
+			"status":  http.StatusNotFound,
+			"details": "Too many cards match ambiguous name. Add more words to refine your search.",
+		})
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "No cards found matching the given name")
+	}
+}
+
+func normalize(name string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+func (s *Server) handleCube(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	cube, ok := s.cubes[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "cube not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, cube)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error in the shape Scryfall uses
+func writeError(w http.ResponseWriter, status int, code, details string) {
+	writeJSON(w, status, map[string]any{
+		"object":  "error",
+		"code":    code,
+		"status":  status,
+		"details": details,
+	})
+}
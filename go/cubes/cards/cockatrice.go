@@ -0,0 +1,159 @@
+package cards
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+// Cockatrice deck zones
+const (
+	cockatriceMain = "main"
+	cockatriceSide = "side"
+)
+
+// cockatriceDeck is the layout of a Cockatrice .cod file
+type cockatriceDeck struct {
+	XMLName  xml.Name         `xml:"cockatrice_deck"`
+	Version  string           `xml:"version,attr"`
+	DeckName string           `xml:"deckname"`
+	Comments string           `xml:"comments"`
+	Zones    []cockatriceZone `xml:"zone"`
+}
+
+type cockatriceZone struct {
+	Name  string           `xml:"name,attr"`
+	Cards []cockatriceCard `xml:"card"`
+}
+
+type cockatriceCard struct {
+	Number          int    `xml:"number,attr"`
+	Name            string `xml:"name,attr"`
+	SetShortName    string `xml:"setShortName,attr,omitempty"`
+	CollectorNumber string `xml:"collectorNumber,attr,omitempty"`
+}
+
+// CockatriceFormat reads and writes Cockatrice .cod files. Names are resolved against the deck's cube and then against
+// stored cards, so cards from outside the cube such as basic lands still resolve. Storage may be nil.
+type CockatriceFormat struct {
+	storage cubes.Storage
+}
+
+func NewCockatriceFormat(storage cubes.Storage) *CockatriceFormat {
+	return &CockatriceFormat{storage: storage}
+}
+
+// parseCockatrice reads the deck name and the cards of the main and side zones. Other zones, such as tokens, are
+// skipped.
+func parseCockatrice(r io.Reader) (string, []DecklistEntry, error) {
+	decoder := xml.NewDecoder(r)
+	var name string
+	var entries []DecklistEntry
+	zone := ""
+	for {
+		line, _ := decoder.InputPos()
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf(`read cod: %w`, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "deckname":
+			if err := decoder.DecodeElement(&name, &start); err != nil {
+				return "", nil, fmt.Errorf(`line %d: decode deck name: %w`, line, err)
+			}
+		case "zone":
+			zone = ""
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "name" {
+					zone = attr.Value
+				}
+			}
+		case "card":
+			if zone != cockatriceMain && zone != cockatriceSide {
+				continue
+			}
+			var card cockatriceCard
+			if err := decoder.DecodeElement(&card, &start); err != nil {
+				return "", nil, fmt.Errorf(`line %d: decode card: %w`, line, err)
+			}
+			if card.Number <= 0 {
+				continue
+			}
+			entries = append(entries, DecklistEntry{
+				Line:            line,
+				Text:            strconv.Itoa(card.Number) + " " + card.Name,
+				Count:           card.Number,
+				Name:            card.Name,
+				Set:             strings.ToLower(card.SetShortName),
+				CollectorNumber: card.CollectorNumber,
+				Sideboard:       zone == cockatriceSide,
+			})
+		}
+	}
+	return strings.TrimSpace(name), entries, nil
+}
+
+// ReadDeck resolves a .cod file into the deck. The deck name becomes the description if the deck doesn't have one.
+func (c *CockatriceFormat) ReadDeck(ctx context.Context, deck cubes.Deck, data []byte) (cubes.Deck, error) {
+	name, entries, err := parseCockatrice(bytes.NewReader(data))
+	if err != nil {
+		return cubes.Deck{}, err
+	}
+	if deck.Description == "" {
+		deck.Description = name
+	}
+	return resolveEntriesWithStorage(ctx, c.storage, deck, entries)
+}
+
+// WriteDeck writes the deck as a .cod file named after its description
+func (c *CockatriceFormat) WriteDeck(w io.Writer, deck cubes.Deck) error {
+	cod := cockatriceDeck{
+		Version:  "1",
+		DeckName: deck.Description,
+		Zones:    []cockatriceZone{{Name: cockatriceMain}, {Name: cockatriceSide}},
+	}
+	for _, entry := range deckEntries(deck) {
+		zone := &cod.Zones[0]
+		if entry.Sideboard {
+			zone = &cod.Zones[1]
+		}
+		zone.Cards = append(zone.Cards, cockatriceCard{
+			Number:       entry.Count,
+			Name:         entry.Name,
+			SetShortName: exportSet(entry.Set),
+		})
+	}
+	out, err := xml.MarshalIndent(cod, "", "    ")
+	if err != nil {
+		return fmt.Errorf(`marshal cod: %w`, err)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	if _, err := w.Write(append(out, '\n')); err != nil {
+		return err
+	}
+	return nil
+}
+
+// exportSet is the set code other clients expect, upper case and empty for custom cards
+func exportSet(set string) string {
+	if strings.EqualFold(set, cubes.CustomSet) {
+		return ""
+	}
+	return strings.ToUpper(set)
+}
@@ -0,0 +1,203 @@
+package cards
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+// cockatriceCustomSet is the set code custom cards are given in a Cockatrice card database
+const cockatriceCustomSet = "CUSTOM"
+
+// cockatriceMainTypes is the order Cockatrice picks a card's main type in, which decides how it is grouped
+var cockatriceMainTypes = []string{"Planeswalker", "Creature", "Land", "Sorcery", "Instant", "Artifact", "Enchantment", "Battle"}
+
+// cockatriceSimpleSymbol matches mana symbols Cockatrice writes without braces, e.g. {1}{U}{U} is 1UU. Hybrid and
+// Phyrexian symbols such as {G/W} keep theirs.
+var cockatriceSimpleSymbol = regexp.MustCompile(`\{(\d+|[WUBRGCXS])\}`)
+
+// manaSymbol matches any mana symbol in a cost or rules text, capturing what is inside the braces, e.g. G/W in {G/W}
+var manaSymbol = regexp.MustCompile(`\{([^}]+)\}`)
+
+// reminderText matches parenthesised reminder text, whose mana symbols don't count towards colour identity
+var reminderText = regexp.MustCompile(`\([^)]*\)`)
+
+// cockatriceCardDatabase is the layout of a version 4 Cockatrice card database
+type cockatriceCardDatabase struct {
+	XMLName xml.Name              `xml:"cockatrice_carddatabase"`
+	Version string                `xml:"version,attr"`
+	Sets    []cockatriceSet       `xml:"sets>set"`
+	Cards   []cockatriceCardEntry `xml:"cards>card"`
+}
+
+type cockatriceSet struct {
+	Name        string `xml:"name"`
+	LongName    string `xml:"longname"`
+	SetType     string `xml:"settype"`
+	ReleaseDate string `xml:"releasedate,omitempty"`
+}
+
+type cockatriceCardEntry struct {
+	Name     string                 `xml:"name"`
+	Text     string                 `xml:"text"`
+	Prop     cockatriceCardProp     `xml:"prop"`
+	Set      cockatriceCardPrinting `xml:"set"`
+	TableRow int                    `xml:"tablerow"`
+}
+
+type cockatriceCardProp struct {
+	Layout        string `xml:"layout"`
+	Side          string `xml:"side"`
+	Type          string `xml:"type"`
+	MainType      string `xml:"maintype"`
+	ManaCost      string `xml:"manacost,omitempty"`
+	CMC           int    `xml:"cmc"`
+	Colors        string `xml:"colors,omitempty"`
+	ColorIdentity string `xml:"coloridentity,omitempty"`
+	PT            string `xml:"pt,omitempty"`
+	Loyalty       string `xml:"loyalty,omitempty"`
+}
+
+type cockatriceCardPrinting struct {
+	Code   string `xml:",chardata"`
+	PicURL string `xml:"picurl,attr,omitempty"`
+	UUID   string `xml:"uuid,attr,omitempty"`
+}
+
+// WriteCockatriceCardDatabase writes the custom cards in the cube's mainboard as a Cockatrice card database that players
+// can add to their custom sets folder. Custom cards go in a set named CUSTOM. With includeReal the real cards are
+// written too, under their own sets, so the file works without Cockatrice's own database. Cockatrice keys cards on
+// their name, so each name is written once, from the first card in the cube with it.
+func WriteCockatriceCardDatabase(w io.Writer, cube cubes.Cube, includeReal bool) error {
+	db := cockatriceCardDatabase{Version: "4"}
+	sets := make(map[string]cockatriceSet)
+	seen := make(map[string]bool)
+	for _, card := range cube.Cards {
+		custom := card.IsCustom() || slices.Contains(cube.Tags[card.ID], "custom")
+		if (!custom && !includeReal) || seen[card.Name] {
+			continue
+		}
+		seen[card.Name] = true
+
+		setCode := strings.ToUpper(card.Set)
+		set := cockatriceSet{Name: setCode, LongName: setCode, SetType: "Expansion"}
+		if custom {
+			setCode = cockatriceCustomSet
+			set = cockatriceSet{Name: setCode, LongName: fmt.Sprintf("%s custom cards", cube.Name), SetType: "Custom"}
+		}
+		if !card.ReleaseDate.IsZero() {
+			set.ReleaseDate = card.ReleaseDate.Format("2006-01-02")
+		}
+		// A set is dated by its earliest card
+		existing, ok := sets[setCode]
+		if !ok || existing.ReleaseDate == "" || (set.ReleaseDate != "" && set.ReleaseDate < existing.ReleaseDate) {
+			sets[setCode] = set
+		}
+
+		db.Cards = append(db.Cards, cockatriceCardEntry{
+			Name: card.Name,
+			Text: card.TextBox,
+			Prop: cockatriceProp(card),
+			Set: cockatriceCardPrinting{
+				Code:   setCode,
+				PicURL: card.ImageURI,
+				UUID:   card.ID,
+			},
+			TableRow: cockatriceTableRow(card),
+		})
+	}
+	for _, code := range slices.Sorted(maps.Keys(sets)) {
+		db.Sets = append(db.Sets, sets[code])
+	}
+
+	out, err := xml.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return fmt.Errorf(`marshal card database: %w`, err)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	if _, err := w.Write(append(out, '\n')); err != nil {
+		return err
+	}
+	return nil
+}
+
+func cockatriceProp(card cubes.Card) cockatriceCardProp {
+	var colors string
+	for _, c := range card.Colors {
+		colors += string(c)
+	}
+	prop := cockatriceCardProp{
+		Layout:        "normal",
+		Side:          "front",
+		Type:          card.TypeLine(),
+		MainType:      card.Type,
+		CMC:           card.ManaValue,
+		Colors:        colors,
+		ColorIdentity: colorIdentity(card),
+	}
+	for _, t := range cockatriceMainTypes {
+		if strings.Contains(card.Type, t) {
+			prop.MainType = t
+			break
+		}
+	}
+	if card.ManaCost != nil {
+		prop.ManaCost = cockatriceSimpleSymbol.ReplaceAllString(*card.ManaCost, "$1")
+	}
+	if strings.Contains(card.Type, "Creature") || slices.Contains(card.SubType, "Vehicle") {
+		prop.PT = fmt.Sprintf("%d/%d", card.Power, card.Toughness)
+	}
+	if strings.Contains(card.Type, "Planeswalker") {
+		prop.Loyalty = strconv.Itoa(card.Loyalty)
+	}
+	return prop
+}
+
+// colorIdentity returns the card's colour identity in WUBRG order: its colours along with the colours of every mana
+// symbol in its cost and rules text, both halves of hybrid symbols included. Reminder text doesn't count.
+func colorIdentity(card cubes.Card) string {
+	found := make(map[cubes.Color]bool)
+	for _, c := range card.Colors {
+		found[c] = true
+	}
+	text := reminderText.ReplaceAllString(card.TextBox, "")
+	if card.ManaCost != nil {
+		text = *card.ManaCost + " " + text
+	}
+	for _, symbol := range manaSymbol.FindAllStringSubmatch(text, -1) {
+		for _, part := range strings.Split(symbol[1], "/") {
+			found[cubes.Color(part)] = true
+		}
+	}
+	var identity string
+	for _, c := range []cubes.Color{cubes.White, cubes.Blue, cubes.Black, cubes.Red, cubes.Green} {
+		if found[c] {
+			identity += string(c)
+		}
+	}
+	return identity
+}
+
+// cockatriceTableRow is the row of the battlefield Cockatrice plays the card to: lands, other non-creature permanents,
+// creatures, then instants and sorceries
+func cockatriceTableRow(card cubes.Card) int {
+	switch {
+	case strings.Contains(card.Type, "Land"):
+		return 0
+	case strings.Contains(card.Type, "Creature"):
+		return 2
+	case strings.Contains(card.Type, "Instant"), strings.Contains(card.Type, "Sorcery"):
+		return 3
+	default:
+		return 1
+	}
+}
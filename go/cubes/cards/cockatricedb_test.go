@@ -0,0 +1,142 @@
+package cards
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+var (
+	cockatriceBolt = cubes.Card{
+		ID: "bolt-a25", Name: "Lightning Bolt", ManaCost: ptr("{R}"), ManaValue: 1, Type: "Instant",
+		TextBox: "Lightning Bolt deals 3 damage to any target.", Colors: []cubes.Color{cubes.Red}, Set: "a25",
+		ReleaseDate: time.Date(2018, 3, 16, 0, 0, 0, 0, time.UTC),
+	}
+	cockatriceBoltM11 = cubes.Card{
+		ID: "bolt-m11", Name: "Lightning Bolt", ManaCost: ptr("{R}"), ManaValue: 1, Type: "Instant",
+		Colors: []cubes.Color{cubes.Red}, Set: "m11", ReleaseDate: time.Date(2010, 7, 16, 0, 0, 0, 0, time.UTC),
+	}
+	cockatriceLand = cubes.Card{
+		ID: "land", Name: "Fixture Lands", Type: "Land", SuperType: []string{"Legendary"},
+		TextBox: "{T}: Add {U} or {R}.", Set: cubes.CustomSet, ImageURI: customImageURL,
+	}
+	cockatriceHybrid = cubes.Card{
+		ID: "hybrid", Name: "Fixture Hybrid", ManaCost: ptr("{2}{G/W}{G/W}"), ManaValue: 4, Type: "Creature",
+		SubType: []string{"Elf", "Knight"}, TextBox: "Vigilance\nWard {2/B} (Whenever this becomes the target of a spell, counter it unless that player pays {U}.)",
+		Power: 3, Toughness: 3, Colors: []cubes.Color{cubes.Green, cubes.White}, Set: cubes.CustomSet,
+	}
+	cockatriceTagged = cubes.Card{
+		ID: "tagged", Name: "Fixture Walker", ManaCost: ptr("{1}{U}{U}"), ManaValue: 3, Type: "Planeswalker",
+		SubType: []string{"Jace"}, Loyalty: 4, Colors: []cubes.Color{cubes.Blue}, Set: "tst",
+	}
+)
+
+func writeCockatriceCardDatabase(t *testing.T, cube cubes.Cube, includeReal bool) (cockatriceCardDatabase, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteCockatriceCardDatabase(&buf, cube, includeReal); err != nil {
+		t.Fatalf("write card database: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header) {
+		t.Errorf("output doesn't start with an XML header:\n%s", buf.String())
+	}
+	var db cockatriceCardDatabase
+	if err := xml.Unmarshal(buf.Bytes(), &db); err != nil {
+		t.Fatalf("unmarshal card database: %v", err)
+	}
+	return db, buf.String()
+}
+
+func TestWriteCockatriceCardDatabaseCustomCards(t *testing.T) {
+	cube := cubes.Cube{
+		Name:  "Fixture Cube",
+		Cards: []cubes.Card{cockatriceBolt, cockatriceLand, cockatriceHybrid, cockatriceTagged, cockatriceHybrid},
+		// A real printing tagged custom is treated as one
+		Tags: map[string][]string{cockatriceTagged.ID: {"custom"}},
+	}
+	db, out := writeCockatriceCardDatabase(t, cube, false)
+
+	if db.Version != "4" || len(db.Sets) != 1 {
+		t.Fatalf("database version %s with sets %+v, want version 4 with one set", db.Version, db.Sets)
+	}
+	if set := db.Sets[0]; set.Name != "CUSTOM" || set.LongName != "Fixture Cube custom cards" || set.SetType != "Custom" {
+		t.Errorf("set = %+v", set)
+	}
+	if len(db.Cards) != 3 {
+		t.Fatalf("wrote %d cards, want the 3 custom ones:\n%s", len(db.Cards), out)
+	}
+
+	tests := []struct {
+		card     cockatriceCardEntry
+		name     string
+		prop     cockatriceCardProp
+		tableRow int
+	}{
+		{db.Cards[0], "Fixture Lands", cockatriceCardProp{
+			Layout: "normal", Side: "front", Type: "Legendary Land", MainType: "Land", ColorIdentity: "UR",
+		}, 0},
+		{db.Cards[1], "Fixture Hybrid", cockatriceCardProp{
+			Layout: "normal", Side: "front", Type: "Creature — Elf Knight", MainType: "Creature", ManaCost: "2{G/W}{G/W}",
+			CMC: 4, Colors: "GW", ColorIdentity: "WBG", PT: "3/3",
+		}, 2},
+		{db.Cards[2], "Fixture Walker", cockatriceCardProp{
+			Layout: "normal", Side: "front", Type: "Planeswalker — Jace", MainType: "Planeswalker", ManaCost: "1UU",
+			CMC: 3, Colors: "U", ColorIdentity: "U", Loyalty: "4",
+		}, 1},
+	}
+	for _, tt := range tests {
+		if tt.card.Name != tt.name {
+			t.Errorf("card %s, want %s", tt.card.Name, tt.name)
+			continue
+		}
+		if tt.card.Prop != tt.prop {
+			t.Errorf("%s props = %+v\nwant %+v", tt.name, tt.card.Prop, tt.prop)
+		}
+		if tt.card.TableRow != tt.tableRow {
+			t.Errorf("%s table row = %d, want %d", tt.name, tt.card.TableRow, tt.tableRow)
+		}
+		if tt.card.Set.Code != "CUSTOM" {
+			t.Errorf("%s set = %s, want CUSTOM", tt.name, tt.card.Set.Code)
+		}
+	}
+	if printing := db.Cards[0].Set; printing.PicURL != customImageURL || printing.UUID != "land" {
+		t.Errorf("Fixture Lands printing = %+v", printing)
+	}
+}
+
+func TestWriteCockatriceCardDatabaseIncludeReal(t *testing.T) {
+	cube := cubes.Cube{
+		Name:  "Fixture Cube",
+		Cards: []cubes.Card{cockatriceBolt, cockatriceLand, cockatriceBoltM11, cockatriceBolt},
+	}
+	db, out := writeCockatriceCardDatabase(t, cube, true)
+
+	// Cockatrice rejects a database that lists a name twice, so only the first printing is written
+	if len(db.Cards) != 2 || db.Cards[0].Name != "Lightning Bolt" || db.Cards[1].Name != "Fixture Lands" {
+		t.Fatalf("cards = %+v, want one Lightning Bolt and Fixture Lands:\n%s", db.Cards, out)
+	}
+	bolt := db.Cards[0]
+	if bolt.Set.Code != "A25" || bolt.Set.UUID != "bolt-a25" || bolt.Text != cockatriceBolt.TextBox || bolt.TableRow != 3 {
+		t.Errorf("Lightning Bolt = %+v", bolt)
+	}
+	if bolt.Prop.ManaCost != "R" || bolt.Prop.Colors != "R" || bolt.Prop.ColorIdentity != "R" || bolt.Prop.MainType != "Instant" {
+		t.Errorf("Lightning Bolt props = %+v", bolt.Prop)
+	}
+
+	want := []cockatriceSet{
+		{Name: "A25", LongName: "A25", SetType: "Expansion", ReleaseDate: "2018-03-16"},
+		{Name: "CUSTOM", LongName: "Fixture Cube custom cards", SetType: "Custom"},
+	}
+	if len(db.Sets) != len(want) {
+		t.Fatalf("sets = %+v, want %+v", db.Sets, want)
+	}
+	for i, set := range db.Sets {
+		if set != want[i] {
+			t.Errorf("set %d = %+v, want %+v", i, set, want[i])
+		}
+	}
+}
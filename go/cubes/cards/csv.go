@@ -0,0 +1,386 @@
+package cards
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+// cubeCobraCSVHeader is the column layout CubeCobra uses for CSV export and import
+var cubeCobraCSVHeader = []string{
+	"name", "CMC", "Type", "Color", "Set", "Collector Number", "Rarity", "Color Category", "status", "Finish",
+	"maybeboard", "image URL", "image Back URL", "tags", "Notes", "MTGO ID", "Count",
+}
+
+// CubeCobraCSVLoader loads a cube from a CubeCobra CSV export on disk. The file for a cube is <dir>/<cubeID>.csv.
+// CubeCobra CSVs carry no Scryfall IDs so cards are resolved by name, first from an offline CardNameLoader if one is
+// configured and then against cards that are already stored. Names that resolve to nothing are left out if the
+// loader's PartialLoadPolicy allows it. Custom cards are still read from their image.
+type CubeCobraCSVLoader struct {
+	storage    cubes.Storage
+	dir        string
+	loader     *CubeCobraLoader
+	loaderOpts []CubeCobraLoaderOpts
+	nameLoader CardNameLoader
+}
+
+// CardNameLoader reads cards by name from an offline source such as a Scryfall bulk data or MTGJSON AllPrintings file
+type CardNameLoader interface {
+	FetchCardsByName(ctx context.Context, names []string) ([]cubes.Card, error)
+}
+
+type CubeCobraCSVLoaderOpts func(*CubeCobraCSVLoader)
+
+// CSVLoaderWithCardNameLoader resolves names from nameLoader before falling back to stored cards, so a CSV can be
+// loaded into an empty database without network access
+func CSVLoaderWithCardNameLoader(nameLoader CardNameLoader) CubeCobraCSVLoaderOpts {
+	return func(c *CubeCobraCSVLoader) {
+		c.nameLoader = nameLoader
+	}
+}
+
+// CSVLoaderWithCubeCobraOpts configures the CubeCobraLoader that stores the cube read from the CSV, e.g. its deduper or
+// image syncer
+func CSVLoaderWithCubeCobraOpts(opts ...CubeCobraLoaderOpts) CubeCobraCSVLoaderOpts {
+	return func(c *CubeCobraCSVLoader) {
+		c.loaderOpts = append(c.loaderOpts, opts...)
+	}
+}
+
+func NewCubeCobraCSVLoader(storage cubes.Storage, customCardReader CustomCardReader, dir string, opts ...CubeCobraCSVLoaderOpts) *CubeCobraCSVLoader {
+	c := &CubeCobraCSVLoader{
+		storage: storage,
+		dir:     dir,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.loader = NewCubeCobraLoader(storage, nil, customCardReader, c.loaderOpts...)
+	return c
+}
+
+type cubeCobraCSVRow struct {
+	Name            string
+	Set             string
+	CollectorNumber string
+	Count           int
+	Maybeboard      bool
+	ImageURL        string
+	Tags            []string
+}
+
+func (c *CubeCobraCSVLoader) Load(ctx context.Context, cubeID string) error {
+	cubeCobraCube, offline, err := c.readCube(ctx, cubeID)
+	if err != nil {
+		return err
+	}
+	if len(offline) > 0 {
+		if err := c.storage.UpsertCards(ctx, offline); err != nil {
+			return fmt.Errorf(`upsert offline cards: %w`, err)
+		}
+	}
+	return c.loader.loadCube(ctx, cubeID, cubeCobraCube)
+}
+
+// Plan reports what Load would do. Cards come from storage or an offline file, so only custom cards and the cube diff
+// can change.
+func (c *CubeCobraCSVLoader) Plan(ctx context.Context, cubeID string) (*LoadPlan, error) {
+	cubeCobraCube, _, err := c.readCube(ctx, cubeID)
+	if err != nil {
+		return nil, err
+	}
+	return c.loader.planCube(ctx, cubeID, cubeCobraCube)
+}
+
+// readCube reads the cube's CSV and resolves its cards into the shape the CubeCobra API returns. It also returns the
+// cards picked from the offline name loader, which aren't stored yet.
+func (c *CubeCobraCSVLoader) readCube(ctx context.Context, cubeID string) (cubes.CubeCobraCube, []cubes.Card, error) {
+	f, err := os.Open(filepath.Join(c.dir, cubeID+".csv"))
+	if err != nil {
+		return cubes.CubeCobraCube{}, nil, fmt.Errorf(`open csv: %w`, err)
+	}
+	defer f.Close()
+
+	rows, err := readCubeCobraCSV(f)
+	if err != nil {
+		return cubes.CubeCobraCube{}, nil, fmt.Errorf(`read csv: %w`, err)
+	}
+
+	var names []string
+	for _, row := range rows {
+		if !slices.Contains(row.Tags, "custom") {
+			names = append(names, frontFace(row.Name))
+		}
+	}
+	cardsByName := make(map[string][]cubes.Card)
+	offlineIDs := make(map[string]struct{})
+	addCards := func(cards []cubes.Card, offline bool) {
+		for _, card := range cards {
+			key := strings.ToLower(frontFace(card.Name))
+			cardsByName[key] = append(cardsByName[key], card)
+			if offline {
+				offlineIDs[card.ID] = struct{}{}
+			}
+		}
+	}
+	if c.nameLoader != nil && len(names) > 0 {
+		fetched, err := c.nameLoader.FetchCardsByName(ctx, names)
+		if err != nil {
+			return cubes.CubeCobraCube{}, nil, fmt.Errorf(`fetch cards by name: %w`, err)
+		}
+		addCards(fetched, true)
+	}
+	var remaining []string
+	for _, name := range names {
+		if _, ok := cardsByName[strings.ToLower(name)]; !ok {
+			remaining = append(remaining, name)
+		}
+	}
+	if len(remaining) > 0 {
+		stored, err := c.storage.GetByNames(ctx, remaining)
+		if err != nil {
+			return cubes.CubeCobraCube{}, nil, fmt.Errorf(`get by names: %w`, err)
+		}
+		addCards(stored, false)
+	}
+
+	cubeCobraCube := cubes.CubeCobraCube{
+		ID:   cubeID,
+		Name: cubeID,
+		Cards: cubes.CubeCobraCards{
+			Boards: make(map[string][]cubes.CubeCobraCard),
+		},
+	}
+	currentCube, err := c.storage.GetCube(ctx, cubeID, nil)
+	if err != nil {
+		return cubes.CubeCobraCube{}, nil, fmt.Errorf(`get cube: %w`, err)
+	}
+	if currentCube != nil {
+		cubeCobraCube.Name = currentCube.Name
+	}
+
+	var unresolved []string
+	var offline []cubes.Card
+	for _, row := range rows {
+		card := cubes.CubeCobraCard{
+			Tags:     row.Tags,
+			ImageURL: row.ImageURL,
+		}
+		if !slices.Contains(row.Tags, "custom") {
+			match, ok := pickPrinting(cardsByName[strings.ToLower(frontFace(row.Name))], row.Set, row.CollectorNumber)
+			if !ok {
+				unresolved = append(unresolved, row.Name)
+				continue
+			}
+			card.ID = match.ID
+			if _, ok := offlineIDs[match.ID]; ok {
+				offline = append(offline, match)
+				delete(offlineIDs, match.ID)
+			}
+		}
+		board := cubes.MainBoard
+		if row.Maybeboard {
+			board = cubes.MaybeBoard
+		}
+		for range row.Count {
+			cubeCobraCube.Cards.Boards[board] = append(cubeCobraCube.Cards.Boards[board], card)
+		}
+	}
+	// Unresolved names are missing cards as far as the partial load policy is concerned
+	if err := c.loader.partialPolicy(LoadReport{Missing: unresolved}); err != nil {
+		return cubes.CubeCobraCube{}, nil, fmt.Errorf(`%d cards not found: %w`, len(unresolved), err)
+	}
+	cubeCobraCube.Cards.MainBoard = cubeCobraCube.Cards.Boards[cubes.MainBoard]
+	cubeCobraCube.Cards.MaybeBoard = cubeCobraCube.Cards.Boards[cubes.MaybeBoard]
+
+	return cubeCobraCube, offline, nil
+}
+
+func readCubeCobraCSV(r io.Reader) ([]cubeCobraCSVRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf(`read header: %w`, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New(`missing name column`)
+	}
+	get := func(record []string, column string) string {
+		if i, ok := columns[strings.ToLower(column)]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rows []cubeCobraCSVRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf(`read record: %w`, err)
+		}
+		name := get(record, "name")
+		if name == "" {
+			continue
+		}
+		maybeboard, _ := strconv.ParseBool(get(record, "maybeboard"))
+		count := 1
+		if n, err := strconv.Atoi(get(record, "count")); err == nil && n > 0 {
+			count = n
+		}
+		var tags []string
+		for _, tag := range strings.Split(get(record, "tags"), ";") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		rows = append(rows, cubeCobraCSVRow{
+			Name:            name,
+			Set:             get(record, "set"),
+			CollectorNumber: get(record, "collector number"),
+			Count:           count,
+			Maybeboard:      maybeboard,
+			ImageURL:        get(record, "image url"),
+			Tags:            tags,
+		})
+	}
+	return rows, nil
+}
+
+// pickPrinting chooses the printing with set and collector number if there is one, then any printing from set,
+// otherwise the most recent printing
+func pickPrinting(printings []cubes.Card, set, collectorNumber string) (cubes.Card, bool) {
+	if len(printings) == 0 {
+		return cubes.Card{}, false
+	}
+	best := printings[0]
+	var inSet *cubes.Card
+	for _, card := range printings {
+		if set != "" && strings.EqualFold(card.Set, set) {
+			if collectorNumber == "" || card.CollectorNumber == collectorNumber {
+				return card, true
+			}
+			if inSet == nil {
+				inSet = &card
+			}
+		}
+		if card.ReleaseDate.After(best.ReleaseDate) {
+			best = card
+		}
+	}
+	if inSet != nil {
+		return *inSet, true
+	}
+	return best, true
+}
+
+// frontFace returns the name of the first face of a multi-faced card name such as "Fable of the Mirror-Breaker // Reflection of Kiki-Jiki"
+func frontFace(name string) string {
+	front, _, _ := strings.Cut(name, "//")
+	return strings.TrimSpace(front)
+}
+
+// WriteCubeCobraCSV writes the mainboard and maybeboard of the cube in CubeCobra's CSV import format, one row per card
+// with its number of copies in Count. CubeCobra CSVs have no way to express any other board.
+func WriteCubeCobraCSV(w io.Writer, cube cubes.Cube) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(cubeCobraCSVHeader); err != nil {
+		return fmt.Errorf(`write header: %w`, err)
+	}
+	for _, board := range []string{cubes.MainBoard, cubes.MaybeBoard} {
+		var order []cubes.Card
+		counts := make(map[string]int)
+		for _, card := range cube.Board(board) {
+			if counts[card.ID] == 0 {
+				order = append(order, card)
+			}
+			counts[card.ID]++
+		}
+		for _, card := range order {
+			var colors string
+			for _, c := range card.Colors {
+				colors += string(c)
+			}
+			if colors == "" {
+				colors = "C"
+			}
+			tags := cube.TagsOn(board)[card.ID]
+			var imageURL string
+			if card.IsCustom() || slices.Contains(tags, "custom") {
+				imageURL = card.ImageURI
+				// Only the custom tag tells CubeCobra and readCubeCobraCSV to read the card from its image
+				if !slices.Contains(tags, "custom") {
+					tags = append(slices.Clone(tags), "custom")
+				}
+			}
+			var mtgoID string
+			if card.MTGOID != 0 {
+				mtgoID = strconv.Itoa(card.MTGOID)
+			}
+			record := []string{
+				card.Name,
+				strconv.Itoa(card.ManaValue),
+				card.TypeLine(),
+				colors,
+				card.Set,
+				card.CollectorNumber,
+				"",
+				colorCategory(card),
+				"Owned",
+				"Non-foil",
+				strconv.FormatBool(board == cubes.MaybeBoard),
+				imageURL,
+				"",
+				strings.Join(tags, ";"),
+				"",
+				mtgoID,
+				strconv.Itoa(counts[card.ID]),
+			}
+			if err := writer.Write(record); err != nil {
+				return fmt.Errorf(`write %s: %w`, card.Name, err)
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func colorCategory(card cubes.Card) string {
+	switch {
+	case strings.Contains(card.Type, "Land"):
+		return "Lands"
+	case len(card.Colors) > 1:
+		return "Multicolored"
+	case len(card.Colors) == 0:
+		return "Colorless"
+	}
+	switch card.Colors[0] {
+	case cubes.White:
+		return "White"
+	case cubes.Blue:
+		return "Blue"
+	case cubes.Black:
+		return "Black"
+	case cubes.Red:
+		return "Red"
+	case cubes.Green:
+		return "Green"
+	}
+	return "Colorless"
+}
@@ -0,0 +1,267 @@
+package cards
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+	"github.com/mgdunn2/cube-datahub/cubes/cards/cardstest"
+	"github.com/mgdunn2/cube-datahub/cubes/images"
+)
+
+var (
+	csvBolt         = cubes.Card{ID: "77c6fa74-5543-42ac-9ead-0e890b188e99", Name: "Lightning Bolt", Type: "Instant", Colors: []cubes.Color{cubes.Red}, Set: "m11", CollectorNumber: "149", ManaValue: 1}
+	csvCounterspell = cubes.Card{ID: "1920dae4-fb92-4f19-ae4b-eb3276b8dac7", Name: "Counterspell", Type: "Instant", Colors: []cubes.Color{cubes.Blue}, Set: "tmp", CollectorNumber: "57", ManaValue: 2}
+	csvCustom       = cubes.Card{ID: "c0ffee00-0000-0000-0000-000000000001", Name: "Fixture Custom Card", Type: "Creature", Set: cubes.CustomSet, ImageURI: customImageURL}
+)
+
+func TestCubeCobraCSVRoundTrip(t *testing.T) {
+	cube := cubes.Cube{
+		ID:     "csv-cube",
+		Cards:  []cubes.Card{csvBolt, csvCustom, csvBolt},
+		Boards: map[string][]cubes.Card{cubes.MaybeBoard: {csvCounterspell, csvBolt, csvCustom}},
+		Tags:   map[string][]string{csvBolt.ID: {"burn", "red"}, csvCustom.ID: {"custom"}},
+		// The maybeboard copies have tags of their own, and the custom card is missing its custom tag
+		BoardTags: map[string]map[string][]string{cubes.MaybeBoard: {csvBolt.ID: {"cut"}}},
+	}
+	var buf bytes.Buffer
+	if err := WriteCubeCobraCSV(&buf, cube); err != nil {
+		t.Fatalf("write csv: %v", err)
+	}
+
+	rows, err := readCubeCobraCSV(&buf)
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	want := []cubeCobraCSVRow{
+		{Name: "Lightning Bolt", Set: "m11", CollectorNumber: "149", Count: 2, Tags: []string{"burn", "red"}},
+		{Name: "Fixture Custom Card", Set: cubes.CustomSet, Count: 1, ImageURL: customImageURL, Tags: []string{"custom"}},
+		{Name: "Counterspell", Set: "tmp", CollectorNumber: "57", Count: 1, Maybeboard: true},
+		{Name: "Lightning Bolt", Set: "m11", CollectorNumber: "149", Count: 1, Maybeboard: true, Tags: []string{"cut"}},
+		{Name: "Fixture Custom Card", Set: cubes.CustomSet, Count: 1, Maybeboard: true, ImageURL: customImageURL, Tags: []string{"custom"}},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("read %d rows, want %d: %+v", len(rows), len(want), rows)
+	}
+	for i, row := range rows {
+		w := want[i]
+		if row.Name != w.Name || row.Set != w.Set || row.CollectorNumber != w.CollectorNumber || row.Count != w.Count ||
+			row.Maybeboard != w.Maybeboard || row.ImageURL != w.ImageURL || !slices.Equal(row.Tags, w.Tags) {
+			t.Errorf("row %d = %+v, want %+v", i, row, w)
+		}
+	}
+}
+
+func TestReadCubeCobraCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    []cubeCobraCSVRow
+		wantErr bool
+	}{
+		{
+			name: "columns in any order and case",
+			csv:  "Count,NAME,set,Collector Number\n3,Lightning Bolt,m11,149\n",
+			want: []cubeCobraCSVRow{{Name: "Lightning Bolt", Set: "m11", CollectorNumber: "149", Count: 3}},
+		},
+		{
+			name: "missing or bad count is one copy",
+			csv:  "name,Count\nLightning Bolt,\nCounterspell,none\nTarmogoyf,0\n",
+			want: []cubeCobraCSVRow{
+				{Name: "Lightning Bolt", Count: 1},
+				{Name: "Counterspell", Count: 1},
+				{Name: "Tarmogoyf", Count: 1},
+			},
+		},
+		{
+			name: "blank names are skipped",
+			csv:  "name,tags\n,ignored\nLightning Bolt, burn ; ;red\n",
+			want: []cubeCobraCSVRow{{Name: "Lightning Bolt", Count: 1, Tags: []string{"burn", "red"}}},
+		},
+		{
+			name: "short records",
+			csv:  "name,maybeboard,image URL\nLightning Bolt\n",
+			want: []cubeCobraCSVRow{{Name: "Lightning Bolt", Count: 1}},
+		},
+		{
+			name:    "no name column",
+			csv:     "card,set\nLightning Bolt,m11\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			csv:     "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := readCubeCobraCSV(bytes.NewBufferString(tt.csv))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("read csv: got %v, want error %v", err, tt.wantErr)
+			}
+			if len(rows) != len(tt.want) {
+				t.Fatalf("rows = %+v, want %+v", rows, tt.want)
+			}
+			for i, row := range rows {
+				w := tt.want[i]
+				if row.Name != w.Name || row.Set != w.Set || row.CollectorNumber != w.CollectorNumber ||
+					row.Count != w.Count || !slices.Equal(row.Tags, w.Tags) {
+					t.Errorf("row %d = %+v, want %+v", i, row, w)
+				}
+			}
+		})
+	}
+}
+
+func TestCubeCobraCSVLoadKeepsCopies(t *testing.T) {
+	storage := newMemStorage()
+	if err := storage.UpsertCards(context.Background(), []cubes.Card{csvBolt, csvCounterspell}); err != nil {
+		t.Fatalf("upsert cards: %v", err)
+	}
+	dir := t.TempDir()
+	cube := cubes.Cube{
+		Cards:  []cubes.Card{csvBolt, csvBolt, csvBolt},
+		Boards: map[string][]cubes.Card{cubes.MaybeBoard: {csvCounterspell}},
+	}
+	var buf bytes.Buffer
+	if err := WriteCubeCobraCSV(&buf, cube); err != nil {
+		t.Fatalf("write csv: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "csv-cube.csv"), buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	loader := NewCubeCobraCSVLoader(storage, &staticCardReader{}, dir)
+	if err := loader.Load(context.Background(), "csv-cube"); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	stored, err := storage.GetCube(context.Background(), "csv-cube", nil)
+	if err != nil || stored == nil {
+		t.Fatalf("get cube: %v, %v", stored, err)
+	}
+	if got := countNames(stored.Cards); got["Lightning Bolt"] != 3 || len(got) != 1 {
+		t.Errorf("mainboard = %v, want 3 Lightning Bolt", got)
+	}
+	if got := countNames(stored.Board(cubes.MaybeBoard)); got["Counterspell"] != 1 || len(got) != 1 {
+		t.Errorf("maybeboard = %v, want 1 Counterspell", got)
+	}
+}
+
+func TestCubeCobraCSVLoaderOpts(t *testing.T) {
+	storage := newMemStorage()
+	if loader := NewCubeCobraCSVLoader(storage, nil, t.TempDir()); loader.loader.deduper == nil {
+		t.Error("CSV loader doesn't dedupe custom cards by default")
+	}
+	loader := NewCubeCobraCSVLoader(storage, nil, t.TempDir(), CSVLoaderWithCubeCobraOpts(CubeLoaderWithoutDedupe()))
+	if loader.loader.deduper != nil {
+		t.Error("CSV loader dedupes custom cards with CubeLoaderWithoutDedupe")
+	}
+}
+
+func TestCubeCobraCSVLoadPassesLoaderOpts(t *testing.T) {
+	ctx := context.Background()
+	srv := newDedupeImageServer(t)
+	storage := newMemStorage()
+	if err := storage.UpsertCards(ctx, []cubes.Card{csvBolt}); err != nil {
+		t.Fatalf("upsert cards: %v", err)
+	}
+	dir := t.TempDir()
+	csvFile := "name,Set,image URL,tags\n" +
+		"Lightning Bolt,m11,,\n" +
+		"Not A Real Card,,,\n" +
+		"Fixture Custom Card,custom," + srv.URL + "/original.png,custom\n"
+	if err := os.WriteFile(filepath.Join(dir, "csv-cube.csv"), []byte(csvFile), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	custom := csvCustom
+	custom.ImageURI = srv.URL + "/original.png"
+	reader := &staticCardReader{card: custom}
+
+	// Without a policy an unresolved name stops the load
+	strict := NewCubeCobraCSVLoader(storage, reader, dir, CSVLoaderWithCubeCobraOpts(CubeLoaderWithoutDedupe()))
+	if err := strict.Load(ctx, "csv-cube"); !errors.Is(err, ErrPartialLoad) {
+		t.Fatalf("load: got %v, want ErrPartialLoad", err)
+	}
+
+	store, err := images.NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	loader := NewCubeCobraCSVLoader(storage, reader, dir, CSVLoaderWithCubeCobraOpts(
+		CubeLoaderWithoutDedupe(),
+		CubeLoaderWithPartialLoadPolicy(AllowPartialLoads),
+		CubeLoaderWithImageSyncer(images.NewSyncer(store, storage)),
+	))
+	if err := loader.Load(ctx, "csv-cube"); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	stored, err := storage.GetCube(ctx, "csv-cube", nil)
+	if err != nil || stored == nil {
+		t.Fatalf("get cube: %v, %v", stored, err)
+	}
+	if got := countNames(stored.Cards); len(got) != 2 || got["Lightning Bolt"] != 1 || got["Fixture Custom Card"] != 1 {
+		t.Errorf("mainboard = %v, want Lightning Bolt and Fixture Custom Card", got)
+	}
+	customID := storage.customCards[srv.URL+"/original.png"]
+	if ref := storage.cards[customID].ImageRef; ref == "" {
+		t.Error("custom card read from the CSV has no local image")
+	} else if has, err := store.Has(ctx, ref); err != nil || !has {
+		t.Errorf("image %s not in the store: %v", ref, err)
+	}
+}
+
+func TestCubeCobraAndCSVLoadSharedCustomCard(t *testing.T) {
+	srv := newTestServer(t)
+	storage := newMemStorage()
+	dir := t.TempDir()
+	csvFile := "name,Set,image URL,tags\nFixture Custom Card,custom," + customImageURL + ",custom\n"
+	if err := os.WriteFile(filepath.Join(dir, "csv-cube.csv"), []byte(csvFile), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	// A slow read leaves plenty of time for the other load to reach the same image
+	reader := &staticCardReader{card: cubes.Card{Name: "Fixture Custom Card", Type: "Creature"}, delay: 50 * time.Millisecond}
+	cubeCobraLoader := NewCubeCobraLoader(
+		storage,
+		NewScryfallLoader(storage, ScryfallLoaderWithBaseURL(srv.URL)),
+		reader,
+		CubeLoaderWithBaseURL(srv.URL),
+		CubeLoaderWithoutDedupe(),
+	)
+	csvLoader := NewCubeCobraCSVLoader(storage, reader, dir, CSVLoaderWithCubeCobraOpts(
+		CubeLoaderWithoutDedupe(),
+		CubeLoaderWithSharedCustomCards(cubeCobraLoader),
+	))
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, load := range []func() error{
+		func() error { return cubeCobraLoader.Load(context.Background(), cardstest.FixtureCubeID) },
+		func() error { return csvLoader.Load(context.Background(), "csv-cube") },
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = load()
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("load cube: %v", err)
+		}
+	}
+	if reader.reads != 1 {
+		t.Errorf("custom card read %d times, want 1", reader.reads)
+	}
+	if ids, _ := storage.GetAllCustomCardIDs(context.Background()); len(ids) != 1 {
+		t.Errorf("custom cards = %v, want one", ids)
+	}
+}
@@ -0,0 +1,58 @@
+package cards
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+// FieldDiff is a single card field that differs between two reads of a card
+type FieldDiff struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// DiffCard compares the user facing fields of two cards, ignoring the ID
+func DiffCard(old, new cubes.Card) []FieldDiff {
+	fields := []struct {
+		name     string
+		old, new string
+	}{
+		{"name", old.Name, new.Name},
+		{"mana_cost", manaCostString(old.ManaCost), manaCostString(new.ManaCost)},
+		{"mana_value", fmt.Sprint(old.ManaValue), fmt.Sprint(new.ManaValue)},
+		{"type_line", old.TypeLine(), new.TypeLine()},
+		{"text_box", old.TextBox, new.TextBox},
+		{"power", fmt.Sprint(old.Power), fmt.Sprint(new.Power)},
+		{"toughness", fmt.Sprint(old.Toughness), fmt.Sprint(new.Toughness)},
+		{"loyalty", fmt.Sprint(old.Loyalty), fmt.Sprint(new.Loyalty)},
+		{"defense", fmt.Sprint(old.Defense), fmt.Sprint(new.Defense)},
+		{"colors", colorsString(old.Colors), colorsString(new.Colors)},
+		{"set", old.Set, new.Set},
+		{"image_uri", old.ImageURI, new.ImageURI},
+	}
+	var diffs []FieldDiff
+	for _, f := range fields {
+		if f.old != f.new {
+			diffs = append(diffs, FieldDiff{Field: f.name, Old: f.old, New: f.new})
+		}
+	}
+	return diffs
+}
+
+func manaCostString(manaCost *string) string {
+	if manaCost == nil {
+		return ""
+	}
+	return *manaCost
+}
+
+func colorsString(colors []cubes.Color) string {
+	var sb strings.Builder
+	for _, c := range colors {
+		sb.WriteString(string(c))
+	}
+	return sb.String()
+}
@@ -6,12 +6,19 @@ import (
 	"fmt"
 	"github.com/mgdunn2/cube-datahub/cubes"
 	"github.com/mgdunn2/cube-datahub/cubes/llm"
+	"io"
+	"strings"
 )
 
 type DeckReader interface {
 	ReadDeck(ctx context.Context, deck cubes.Deck, image []byte) (cubes.Deck, error)
 }
 
+// DeckWriter writes a deck in a format other clients can import
+type DeckWriter interface {
+	WriteDeck(w io.Writer, deck cubes.Deck) error
+}
+
 type LLMDeckReader struct {
 	s  cubes.Storage
 	ir llm.ImageReader
@@ -25,20 +32,36 @@ func NewLLMDeckReader(s cubes.Storage, ir llm.ImageReader) *LLMDeckReader {
 }
 
 func (l *LLMDeckReader) ReadDeck(ctx context.Context, deck cubes.Deck, image []byte) (cubes.Deck, error) {
-	cardNamesStr := ""
-	for i, card := range deck.Event.Cube.Cards {
-		cardNamesStr += fmt.Sprintf("* %s", card.Name)
-		if i != len(deck.Cards)-1 {
-			cardNamesStr += "\n"
+	cardNames, err := l.readCardNames(ctx, deck.Event.Cube.Cards, image, "")
+	if err != nil {
+		return cubes.Deck{}, err
+	}
+	cardsByName := make(map[string]cubes.Card)
+	for _, card := range deck.Event.Cube.Cards {
+		cardsByName[card.Name] = card
+	}
+	for _, cardName := range cardNames {
+		if card, ok := cardsByName[cardName]; ok {
+			deck.Cards = append(deck.Cards, card)
 		}
 	}
+	return deck, nil
+}
+
+// readCardNames asks the model for the names of the cards in image, out of pool. note is added to the prompt to say
+// what part of the photo the image is.
+func (l *LLMDeckReader) readCardNames(ctx context.Context, pool []cubes.Card, image []byte, note string) ([]string, error) {
+	names := make([]string, 0, len(pool))
+	for _, card := range pool {
+		names = append(names, "* "+card.Name)
+	}
 	req := llm.Request{
 		Prompt: fmt.Sprintf(`Your job is to look at an image of a set of Magic cards that comprise a Vintage Cube deck and output
 all of the cards that you see in the picture. Below is a list of every possible card that could be present. Some of them
 are not real magic cards but all will have names that correspond to one of the cards in the provided list.
 
 The image may have glare and may be rotate but do your best to identify every card that you can see the name of.
-
+%s
 Every name that you return should exactly match one of the card names listed below.
 
 You MUST return EVERY card that can be identified. Make sure to find a name for EVERY card in the image.
@@ -48,7 +71,7 @@ of the card names.
 
 ## Card List
 %s
-`, cardNamesStr),
+`, note, strings.Join(names, "\n")),
 		ImageByes: image,
 		Schema: llm.ToolSchema{
 			Name:        "deck",
@@ -58,20 +81,11 @@ of the card names.
 	}
 	rsp, err := l.ir.Generate(ctx, req)
 	if err != nil {
-		return cubes.Deck{}, fmt.Errorf(`generate: %w`, err)
+		return nil, fmt.Errorf(`generate: %w`, err)
 	}
 	var llmDeck cubes.LLMDeckSchema
 	if err = json.Unmarshal([]byte(rsp), &llmDeck); err != nil {
-		return cubes.Deck{}, fmt.Errorf(`unmarshall: %w`, err)
-	}
-	cardsByName := make(map[string]cubes.Card)
-	for _, card := range deck.Event.Cube.Cards {
-		cardsByName[card.Name] = card
-	}
-	for _, cardName := range llmDeck.CardNames {
-		if card, ok := cardsByName[cardName]; ok {
-			deck.Cards = append(deck.Cards, card)
-		}
+		return nil, fmt.Errorf(`unmarshall: %w`, err)
 	}
-	return deck, nil
+	return llmDeck.CardNames, nil
 }
@@ -0,0 +1,157 @@
+package cards
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+	"github.com/mgdunn2/cube-datahub/cubes/cards/cardstest"
+)
+
+type deckFormat interface {
+	DeckReader
+	DeckWriter
+}
+
+// fixtureDeck returns an empty deck whose cube holds every Scryfall fixture card
+func fixtureDeck(t *testing.T) cubes.Deck {
+	t.Helper()
+	srv := newTestServer(t)
+	storage := newMemStorage()
+	loader := NewScryfallLoader(storage, ScryfallLoaderWithBaseURL(srv.URL))
+	if _, err := loader.LoadCards(context.Background(), slices.Collect(maps.Keys(fixtureCardIDs))); err != nil {
+		t.Fatalf("load fixture cards: %v", err)
+	}
+	return cubes.Deck{Event: cubes.Event{Cube: cubes.Cube{Cards: slices.Collect(maps.Values(storage.cards))}}}
+}
+
+// countNames counts the copies of each card by name
+func countNames(cards []cubes.Card) map[string]int {
+	counts := make(map[string]int)
+	for _, card := range cards {
+		counts[card.Name]++
+	}
+	return counts
+}
+
+func TestDeckFormatRoundTrip(t *testing.T) {
+	fixtures, err := cardstest.DeckFixtures()
+	if err != nil {
+		t.Fatalf("deck fixtures: %v", err)
+	}
+	empty := fixtureDeck(t)
+
+	tests := []struct {
+		fixture string
+		format  deckFormat
+	}{
+		{"cockatrice.cod", NewCockatriceFormat(nil)},
+		{"xmage.dck", NewXMageFormat(nil)},
+		{"forge.dck", NewForgeFormat(nil)},
+		{"mtgo.dek", NewDekFormat()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			ctx := context.Background()
+			deck, err := tt.format.ReadDeck(ctx, empty, fixtures[tt.fixture])
+			if err != nil {
+				t.Fatalf("read fixture: %v", err)
+			}
+			if len(deck.Cards) != 6 || len(deck.Sideboard) != 2 {
+				t.Fatalf("fixture read as %d maindeck and %d sideboard cards, want 6 and 2", len(deck.Cards), len(deck.Sideboard))
+			}
+
+			var buf bytes.Buffer
+			if err := tt.format.WriteDeck(&buf, deck); err != nil {
+				t.Fatalf("write deck: %v", err)
+			}
+			reread, err := tt.format.ReadDeck(ctx, empty, buf.Bytes())
+			if err != nil {
+				t.Fatalf("read written deck: %v\n%s", err, buf.String())
+			}
+			if got, want := countNames(reread.Cards), countNames(deck.Cards); !maps.Equal(got, want) {
+				t.Errorf("maindeck = %v, want %v", got, want)
+			}
+			if got, want := countNames(reread.Sideboard), countNames(deck.Sideboard); !maps.Equal(got, want) {
+				t.Errorf("sideboard = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDekResolvesByCatID(t *testing.T) {
+	bolt := cubes.Card{ID: "bolt", Name: "Lightning Bolt", MTGOID: 65580}
+	boltPromo := cubes.Card{ID: "bolt-promo", Name: "Lightning Bolt", MTGOID: 101}
+	delver := cubes.Card{ID: "delver", Name: "Delver of Secrets", MTGOID: 94071}
+	goyf := cubes.Card{ID: "goyf", Name: "Tarmogoyf"}
+	empty := cubes.Deck{Event: cubes.Event{Cube: cubes.Cube{Cards: []cubes.Card{bolt, boltPromo, delver, goyf}}}}
+	dek := `<?xml version="1.0" encoding="utf-8"?>
+<Deck xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <Cards CatID="101" Quantity="2" Sideboard="false" Name="Lightning Bolt" Annotation="0" />
+  <Cards CatID="94071" Quantity="1" Sideboard="false" Name="Delver of Secrets // Insectile Aberration" Annotation="0" />
+  <Cards CatID="0" Quantity="1" Sideboard="true" Name="Tarmogoyf" Annotation="0" />
+</Deck>`
+
+	format := NewDekFormat()
+	deck, err := format.ReadDeck(context.Background(), empty, []byte(dek))
+	if err != nil {
+		t.Fatalf("read dek: %v", err)
+	}
+	// The CatID picks the printing, even where the name alone would match another one or nothing at all
+	var ids []string
+	for _, card := range deck.Cards {
+		ids = append(ids, card.ID)
+	}
+	if want := []string{"bolt-promo", "bolt-promo", "delver"}; !slices.Equal(ids, want) {
+		t.Errorf("maindeck = %v, want %v", ids, want)
+	}
+	if len(deck.Sideboard) != 1 || deck.Sideboard[0].ID != "goyf" {
+		t.Errorf("sideboard = %v, want Tarmogoyf by name", deck.Sideboard)
+	}
+
+	var buf bytes.Buffer
+	if err := format.WriteDeck(&buf, deck); err != nil {
+		t.Fatalf("write dek: %v", err)
+	}
+	for _, line := range []string{
+		`<Cards CatID="101" Quantity="2" Sideboard="false" Name="Lightning Bolt" Annotation="0"></Cards>`,
+		`<Cards CatID="94071" Quantity="1" Sideboard="false" Name="Delver of Secrets" Annotation="0"></Cards>`,
+		`<Cards CatID="0" Quantity="1" Sideboard="true" Name="Tarmogoyf" Annotation="0"></Cards>`,
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(line)) {
+			t.Errorf("missing %s in\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestXMageWritesPrintings(t *testing.T) {
+	deck := cubes.Deck{
+		Description: "Printings",
+		Cards: []cubes.Card{
+			{ID: "bolt", Name: "Lightning Bolt", Set: "a25", CollectorNumber: "141"},
+			{ID: "bolt", Name: "Lightning Bolt", Set: "a25", CollectorNumber: "141"},
+			{ID: "delver", Name: "Delver of Secrets", Set: "mid"},
+			{ID: "custom", Name: "Custom Card", Set: cubes.CustomSet},
+		},
+		Sideboard: []cubes.Card{{ID: "goyf", Name: "Tarmogoyf", Set: "mm3", CollectorNumber: "126"}},
+	}
+	var buf bytes.Buffer
+	if err := NewXMageFormat(nil).WriteDeck(&buf, deck); err != nil {
+		t.Fatalf("write deck: %v", err)
+	}
+	for _, line := range []string{
+		`NAME:Printings`,
+		`2 \[A25:141\] Lightning Bolt`,
+		`1 Delver of Secrets`,
+		`1 Custom Card`,
+		`SB: 1 \[MM3:126\] Tarmogoyf`,
+	} {
+		if !regexp.MustCompile(`(?m)^` + line + `$`).Match(buf.Bytes()) {
+			t.Errorf("missing line %q in\n%s", line, buf.String())
+		}
+	}
+}
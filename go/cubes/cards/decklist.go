@@ -0,0 +1,285 @@
+package cards
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+// DecklistEntry is one card line of a text decklist
+type DecklistEntry struct {
+	Line            int
+	Text            string
+	Count           int
+	Name            string
+	Set             string
+	CollectorNumber string
+	// MTGOID is the Magic Online catalog ID, set by formats that carry one
+	MTGOID    int
+	Sideboard bool
+}
+
+type decklistSection int
+
+const (
+	sectionMain decklistSection = iota
+	sectionSide
+	sectionIgnored
+)
+
+// decklistHeaders maps section headers, lower cased and without any trailing count or colon, to their section.
+// Arena exports use Deck/Sideboard/Commander/Companion and an About block holding the deck name.
+var decklistHeaders = map[string]decklistSection{
+	"deck":        sectionMain,
+	"main":        sectionMain,
+	"maindeck":    sectionMain,
+	"main deck":   sectionMain,
+	"mainboard":   sectionMain,
+	"commander":   sectionMain,
+	"sideboard":   sectionSide,
+	"side":        sectionSide,
+	"sb":          sectionSide,
+	"companion":   sectionSide,
+	"maybeboard":  sectionIgnored,
+	"considering": sectionIgnored,
+	"about":       sectionIgnored,
+	"tokens":      sectionIgnored,
+}
+
+var (
+	// 2 Lightning Bolt, 2x Lightning Bolt
+	countPattern = regexp.MustCompile(`^(\d+)\s*[xX]?\s+(.+)$`)
+	// Lightning Bolt (M10) 146, Lightning Bolt [M10], optionally followed by a finish marker such as *F*
+	printingPattern = regexp.MustCompile(`^(.+?)\s+[(\[]([A-Za-z0-9]{2,6})[)\]](?:\s+([A-Za-z0-9★-]+))?(?:\s+\*[A-Za-z]+\*)?$`)
+	// Sideboard (15), Sideboard: 15
+	headerCountPattern = regexp.MustCompile(`\s*[(:]?\s*\d*\s*\)?\s*$`)
+)
+
+// ParseDecklist reads the common text decklist formats: "1 Card Name", "1x Card Name (SET) 123", MTGO style lists where
+// a blank line separates the sideboard, Arena style Deck/Sideboard headers and "SB: 1 Card Name" lines. Comments
+// starting with # or // are skipped.
+func ParseDecklist(r io.Reader) ([]DecklistEntry, error) {
+	var entries []DecklistEntry
+	section := sectionMain
+	sawHeader := false
+	sawCards := false
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+		if line == "" {
+			// Without headers a blank line after the maindeck starts the sideboard
+			if !sawHeader && sawCards {
+				section = sectionSide
+			}
+			continue
+		}
+		comment := strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//")
+		header := strings.TrimSpace(strings.TrimLeft(line, "#/"))
+		if s, ok := decklistHeaders[strings.ToLower(headerCountPattern.ReplaceAllString(header, ""))]; ok {
+			section = s
+			sawHeader = true
+			continue
+		}
+		if comment || section == sectionIgnored {
+			continue
+		}
+
+		entry := DecklistEntry{Line: lineNumber, Text: line, Count: 1, Sideboard: section == sectionSide}
+		if rest, ok := cutPrefixFold(line, "SB:"); ok {
+			entry.Sideboard = true
+			line = strings.TrimSpace(rest)
+		}
+		if m := countPattern.FindStringSubmatch(line); m != nil {
+			count, err := strconv.Atoi(m[1])
+			if err != nil {
+				return nil, fmt.Errorf(`line %d: bad count %q: %w`, lineNumber, m[1], err)
+			}
+			entry.Count = count
+			line = m[2]
+		}
+		if m := printingPattern.FindStringSubmatch(line); m != nil {
+			line = m[1]
+			entry.Set = strings.ToLower(m[2])
+			entry.CollectorNumber = m[3]
+		}
+		entry.Name = strings.TrimSpace(line)
+		if entry.Count == 0 || entry.Name == "" {
+			continue
+		}
+		entries = append(entries, entry)
+		sawCards = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf(`read decklist: %w`, err)
+	}
+	return entries, nil
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return s, false
+}
+
+// UnresolvedLine is a decklist entry that matched no card, with the closest names as suggestions
+type UnresolvedLine struct {
+	Entry       DecklistEntry
+	Suggestions []string
+}
+
+// UnresolvedLinesError is returned with a partially resolved deck when some entries matched no card
+type UnresolvedLinesError struct {
+	Lines []UnresolvedLine
+}
+
+func (e *UnresolvedLinesError) Error() string {
+	parts := make([]string, 0, len(e.Lines))
+	for _, line := range e.Lines {
+		part := fmt.Sprintf("line %d %q", line.Entry.Line, line.Entry.Name)
+		if len(line.Suggestions) > 0 {
+			part += fmt.Sprintf(" (did you mean %s?)", strings.Join(line.Suggestions, ", "))
+		}
+		parts = append(parts, part)
+	}
+	return fmt.Sprintf("%d unresolved lines: %s", len(e.Lines), strings.Join(parts, "; "))
+}
+
+// ResolveDecklist adds the entries to the deck's maindeck and sideboard, matching names against every board of the
+// event's cube version. Entries that match nothing are returned instead.
+func ResolveDecklist(deck cubes.Deck, entries []DecklistEntry) (cubes.Deck, []UnresolvedLine) {
+	pool := deck.Event.Cube.Cards
+	for _, board := range sortedBoardNames(deck.Event.Cube.Boards) {
+		pool = append(pool, deck.Event.Cube.Boards[board]...)
+	}
+	matcher := newCardMatcher(pool)
+
+	var unresolved []UnresolvedLine
+	for _, entry := range entries {
+		card, ok := matcher.byMTGOID[entry.MTGOID]
+		var suggestions []string
+		if !ok {
+			card, suggestions, ok = matcher.match(entry.Name, entry.Set)
+		}
+		if !ok {
+			unresolved = append(unresolved, UnresolvedLine{Entry: entry, Suggestions: suggestions})
+			continue
+		}
+		deck = addCopies(deck, entry, card)
+	}
+	return deck, unresolved
+}
+
+// addCopies adds a copy of card to the deck's maindeck or sideboard for each one the entry counts
+func addCopies(deck cubes.Deck, entry DecklistEntry, card cubes.Card) cubes.Deck {
+	for range entry.Count {
+		if entry.Sideboard {
+			deck.Sideboard = append(deck.Sideboard, card)
+		} else {
+			deck.Cards = append(deck.Cards, card)
+		}
+	}
+	return deck
+}
+
+// resolveEntriesWithStorage resolves entries against the deck's cube and then looks up any left over by exact name in
+// storage. Storage may be nil to only use the cube.
+func resolveEntriesWithStorage(ctx context.Context, storage cubes.Storage, deck cubes.Deck, entries []DecklistEntry) (cubes.Deck, error) {
+	deck, unresolved := ResolveDecklist(deck, entries)
+	if len(unresolved) == 0 {
+		return deck, nil
+	}
+	if storage != nil {
+		var names []string
+		for _, line := range unresolved {
+			names = append(names, frontFace(line.Entry.Name))
+		}
+		stored, err := storage.GetByNames(ctx, names)
+		if err != nil {
+			return cubes.Deck{}, fmt.Errorf(`get by names: %w`, err)
+		}
+		matcher := newCardMatcher(stored)
+		var stillUnresolved []UnresolvedLine
+		for _, line := range unresolved {
+			card, ok := matcher.exact(line.Entry.Name, line.Entry.Set)
+			if !ok {
+				stillUnresolved = append(stillUnresolved, line)
+				continue
+			}
+			deck = addCopies(deck, line.Entry, card)
+		}
+		unresolved = stillUnresolved
+	}
+	if len(unresolved) > 0 {
+		return deck, &UnresolvedLinesError{Lines: unresolved}
+	}
+	return deck, nil
+}
+
+// deckEntries groups the deck's cards into one entry per card and board, in the order each card first appears, with
+// the maindeck before the sideboard
+func deckEntries(deck cubes.Deck) []DecklistEntry {
+	var entries []DecklistEntry
+	for _, board := range []struct {
+		cards     []cubes.Card
+		sideboard bool
+	}{
+		{deck.Cards, false},
+		{deck.Sideboard, true},
+	} {
+		indexes := make(map[string]int)
+		for _, card := range board.cards {
+			if i, ok := indexes[card.ID]; ok {
+				entries[i].Count++
+				continue
+			}
+			indexes[card.ID] = len(entries)
+			entries = append(entries, DecklistEntry{
+				Count:           1,
+				Name:            card.Name,
+				Set:             card.Set,
+				CollectorNumber: card.CollectorNumber,
+				MTGOID:          card.MTGOID,
+				Sideboard:       board.sideboard,
+			})
+		}
+	}
+	return entries
+}
+
+// TextDeckReader reads a deck from a text decklist rather than a photo
+type TextDeckReader struct{}
+
+func NewTextDeckReader() *TextDeckReader {
+	return &TextDeckReader{}
+}
+
+// ReadDeck parses the decklist in text and resolves it against the deck's cube. If any lines can't be resolved the deck
+// is returned with the cards that could be, along with an *UnresolvedLinesError.
+func (t *TextDeckReader) ReadDeck(_ context.Context, deck cubes.Deck, text []byte) (cubes.Deck, error) {
+	entries, err := ParseDecklist(bytes.NewReader(text))
+	if err != nil {
+		return cubes.Deck{}, fmt.Errorf(`parse decklist: %w`, err)
+	}
+	return resolveEntries(deck, entries)
+}
+
+// resolveEntries resolves parsed entries into the deck, returning an *UnresolvedLinesError alongside the partial deck
+// when some can't be resolved
+func resolveEntries(deck cubes.Deck, entries []DecklistEntry) (cubes.Deck, error) {
+	deck, unresolved := ResolveDecklist(deck, entries)
+	if len(unresolved) > 0 {
+		return deck, &UnresolvedLinesError{Lines: unresolved}
+	}
+	return deck, nil
+}
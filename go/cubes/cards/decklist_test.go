@@ -0,0 +1,196 @@
+package cards
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+	"github.com/mgdunn2/cube-datahub/cubes/cards/cardstest"
+)
+
+func TestParseDecklist(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []DecklistEntry
+	}{
+		{
+			name: "counts",
+			text: "2 Lightning Bolt\n3x Counterspell\n1X Tarmogoyf\nDelver of Secrets\n0 Scalding Tarn",
+			want: []DecklistEntry{
+				{Line: 1, Count: 2, Name: "Lightning Bolt"},
+				{Line: 2, Count: 3, Name: "Counterspell"},
+				{Line: 3, Count: 1, Name: "Tarmogoyf"},
+				{Line: 4, Count: 1, Name: "Delver of Secrets"},
+			},
+		},
+		{
+			name: "printings",
+			text: "1 Lightning Bolt (A25) 141\n1 Counterspell [EMA]\n1 Tarmogoyf (MM3) 126 *F*\n1 Fire // Ice (MH2) 290",
+			want: []DecklistEntry{
+				{Line: 1, Count: 1, Name: "Lightning Bolt", Set: "a25", CollectorNumber: "141"},
+				{Line: 2, Count: 1, Name: "Counterspell", Set: "ema"},
+				{Line: 3, Count: 1, Name: "Tarmogoyf", Set: "mm3", CollectorNumber: "126"},
+				{Line: 4, Count: 1, Name: "Fire // Ice", Set: "mh2", CollectorNumber: "290"},
+			},
+		},
+		{
+			name: "arena headers",
+			text: "About\nName Fixture Tempo\n\nCommander\n1 Jace, the Mind Sculptor\n\nDeck\n2 Lightning Bolt (A25) 141\n\n1 Delver of Secrets (MID) 47\n\nSideboard\n1 Counterspell\n\nCompanion\n1 Tarmogoyf",
+			want: []DecklistEntry{
+				{Line: 5, Count: 1, Name: "Jace, the Mind Sculptor"},
+				{Line: 8, Count: 2, Name: "Lightning Bolt", Set: "a25", CollectorNumber: "141"},
+				// With headers a blank line doesn't start the sideboard
+				{Line: 10, Count: 1, Name: "Delver of Secrets", Set: "mid", CollectorNumber: "47"},
+				{Line: 13, Count: 1, Name: "Counterspell", Sideboard: true},
+				{Line: 16, Count: 1, Name: "Tarmogoyf", Sideboard: true},
+			},
+		},
+		{
+			name: "header counts and comments",
+			text: "// Fixture Tempo\n# Main (2)\n2 Lightning Bolt\n// Sideboard: 1\n1 Counterspell\nMaybeboard\n1 Tarmogoyf",
+			want: []DecklistEntry{
+				{Line: 3, Count: 2, Name: "Lightning Bolt"},
+				{Line: 5, Count: 1, Name: "Counterspell", Sideboard: true},
+			},
+		},
+		{
+			name: "blank line sideboard",
+			text: "\n\n2 Lightning Bolt\n1 Tarmogoyf\n\n1 Counterspell\n\n1 Scalding Tarn",
+			want: []DecklistEntry{
+				{Line: 3, Count: 2, Name: "Lightning Bolt"},
+				{Line: 4, Count: 1, Name: "Tarmogoyf"},
+				{Line: 6, Count: 1, Name: "Counterspell", Sideboard: true},
+				{Line: 8, Count: 1, Name: "Scalding Tarn", Sideboard: true},
+			},
+		},
+		{
+			name: "SB prefix",
+			text: "\uFEFF2 Lightning Bolt\nSB: 1 Counterspell\nsb:2x Tarmogoyf (MM3)",
+			want: []DecklistEntry{
+				{Line: 1, Count: 2, Name: "Lightning Bolt"},
+				{Line: 2, Count: 1, Name: "Counterspell", Sideboard: true},
+				{Line: 3, Count: 2, Name: "Tarmogoyf", Set: "mm3", Sideboard: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := ParseDecklist(strings.NewReader(tt.text))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			for i := range entries {
+				entries[i].Text = ""
+			}
+			if !slices.Equal(entries, tt.want) {
+				t.Errorf("entries = %+v\nwant %+v", entries, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDecklistFixture(t *testing.T) {
+	fixtures, err := cardstest.DeckFixtures()
+	if err != nil {
+		t.Fatalf("deck fixtures: %v", err)
+	}
+	entries, err := ParseDecklist(strings.NewReader(string(fixtures["decklist.txt"])))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var main, side int
+	for _, entry := range entries {
+		if entry.Sideboard {
+			side += entry.Count
+		} else {
+			main += entry.Count
+		}
+	}
+	if len(entries) != 6 || main != 6 || side != 2 {
+		t.Fatalf("parsed %d lines, %d maindeck and %d sideboard cards, want 6, 6 and 2", len(entries), main, side)
+	}
+	if entries[0].Text != "2 Lightning Bolt (A25) 141" {
+		t.Errorf("first line text = %q", entries[0].Text)
+	}
+
+	deck, err := NewTextDeckReader().ReadDeck(context.Background(), fixtureDeck(t), fixtures["decklist.txt"])
+	if err != nil {
+		t.Fatalf("read deck: %v", err)
+	}
+	if counts := countNames(deck.Cards); len(deck.Cards) != 6 || counts["Lightning Bolt"] != 2 || counts["Scalding Tarn"] != 2 {
+		t.Errorf("maindeck = %v", counts)
+	}
+	if counts := countNames(deck.Sideboard); len(deck.Sideboard) != 2 || counts["Counterspell"] != 1 || counts["Tarmogoyf"] != 1 {
+		t.Errorf("sideboard = %v", counts)
+	}
+}
+
+func TestResolveDecklist(t *testing.T) {
+	boltA25 := cubes.Card{ID: "bolt-a25", Name: "Lightning Bolt", Set: "a25"}
+	boltM11 := cubes.Card{ID: "bolt-m11", Name: "Lightning Bolt", Set: "m11"}
+	delver := cubes.Card{ID: "delver", Name: "Delver of Secrets // Insectile Aberration", Set: "mid"}
+	jace := cubes.Card{ID: "jace", Name: "Jace, the Mind Sculptor", Set: "wwk"}
+	seance := cubes.Card{ID: "seance", Name: "Séance", Set: "sok"}
+	counterspell := cubes.Card{ID: "counterspell", Name: "Counterspell", Set: "ema"}
+	goyf := cubes.Card{ID: "goyf", Name: "Tarmogoyf", Set: "mm3"}
+	empty := cubes.Deck{Event: cubes.Event{Cube: cubes.Cube{
+		Cards:  []cubes.Card{boltA25, boltM11, delver, jace, seance, counterspell},
+		Boards: map[string][]cubes.Card{cubes.MaybeBoard: {goyf}},
+	}}}
+
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"exact", "1 Counterspell", "counterspell"},
+		{"set picks the printing", "1 Lightning Bolt (M11)", "bolt-m11"},
+		{"front face", "1 Delver of Secrets", "delver"},
+		{"full double faced name", "1 Delver of Secrets // Insectile Aberration", "delver"},
+		{"case and punctuation", "1 JACE THE MIND SCULPTOR", "jace"},
+		{"accents", "1 Seance", "seance"},
+		{"typo", "1 Tarmogoyff", "goyf"},
+		{"other boards of the cube", "1 Tarmogoyf", "goyf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := ParseDecklist(strings.NewReader(tt.line))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			deck, unresolved := ResolveDecklist(empty, entries)
+			if len(unresolved) != 0 || len(deck.Cards) != 1 || deck.Cards[0].ID != tt.want {
+				t.Errorf("resolved %v with %+v unresolved, want %s", deck.Cards, unresolved, tt.want)
+			}
+		})
+	}
+
+	// Lines that match nothing come back with the closest names, and the rest of the deck is still resolved
+	text := "2 Lightning Bolt\n1 Counterspel\n1 Countermagic\n\n1 Black Lotus"
+	deck, err := NewTextDeckReader().ReadDeck(context.Background(), empty, []byte(text))
+	var unresolvedErr *UnresolvedLinesError
+	if !errors.As(err, &unresolvedErr) {
+		t.Fatalf("read deck: got %v, want an *UnresolvedLinesError", err)
+	}
+	var ids []string
+	for _, card := range deck.Cards {
+		ids = append(ids, card.ID)
+	}
+	if !slices.Equal(ids, []string{"bolt-a25", "bolt-a25", "counterspell"}) {
+		t.Errorf("maindeck = %v, want 2 Lightning Bolt and Counterspell", ids)
+	}
+	lines := unresolvedErr.Lines
+	if len(lines) != 2 || lines[0].Entry.Name != "Countermagic" || lines[1].Entry.Name != "Black Lotus" || !lines[1].Entry.Sideboard {
+		t.Fatalf("unresolved = %+v, want Countermagic and a sideboard Black Lotus", lines)
+	}
+	if len(lines[0].Suggestions) != maxSuggestions || lines[0].Suggestions[0] != "Counterspell" {
+		t.Errorf("suggestions for Countermagic = %v, want %d starting with Counterspell", lines[0].Suggestions, maxSuggestions)
+	}
+	if !strings.Contains(err.Error(), `line 3 "Countermagic" (did you mean Counterspell`) {
+		t.Errorf("error = %v", err)
+	}
+}
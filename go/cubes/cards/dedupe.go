@@ -0,0 +1,198 @@
+package cards
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+	"github.com/mgdunn2/cube-datahub/cubes/images"
+)
+
+// DefaultCustomCardDistance is how many of the 64 dHash bits two custom card images may differ by and still be
+// treated as the same card. Re-encodes and resizes typically land within a few bits.
+const DefaultCustomCardDistance = 8
+
+// CustomCardDeduper recognises custom card images that have already been read, even when they were re-uploaded under a
+// new URL or at a different size, by comparing perceptual hashes
+type CustomCardDeduper struct {
+	storage     cubes.Storage
+	client      *http.Client
+	api         *apiClient
+	maxDistance int
+}
+
+type CustomCardDeduperOpts func(*CustomCardDeduper)
+
+func DeduperWithClient(client http.Client) CustomCardDeduperOpts {
+	return func(d *CustomCardDeduper) {
+		d.client = &client
+	}
+}
+
+// DeduperWithMaxDistance sets how many hash bits two images may differ by and still match
+func DeduperWithMaxDistance(maxDistance int) CustomCardDeduperOpts {
+	return func(d *CustomCardDeduper) {
+		d.maxDistance = maxDistance
+	}
+}
+
+func NewCustomCardDeduper(storage cubes.Storage, opts ...CustomCardDeduperOpts) *CustomCardDeduper {
+	deduper := &CustomCardDeduper{
+		storage:     storage,
+		maxDistance: DefaultCustomCardDistance,
+	}
+	for _, opt := range opts {
+		opt(deduper)
+	}
+	if deduper.client == nil {
+		deduper.client = &http.Client{
+			Timeout: 30 * time.Second,
+		}
+	}
+	deduper.api = newAPIClient(deduper.client, 0, defaultMaxRetries)
+	return deduper
+}
+
+// HashImage downloads an image and returns its perceptual hash
+func (d *CustomCardDeduper) HashImage(ctx context.Context, imageURL string) (uint64, error) {
+	body, err := d.api.getBytes(ctx, imageURL)
+	if err != nil {
+		return 0, fmt.Errorf(`get image: %w`, err)
+	}
+	return images.DecodeDHash(bytes.NewReader(body))
+}
+
+// FindSimilar returns the stored custom card whose image is closest to phash, along with its distance, or nil if none
+// is within the max distance
+func (d *CustomCardDeduper) FindSimilar(ctx context.Context, phash uint64) (*cubes.CustomCard, int, error) {
+	customCards, err := d.storage.GetCustomCards(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf(`get custom cards: %w`, err)
+	}
+	var closest *cubes.CustomCard
+	closestDistance := d.maxDistance + 1
+	for i, customCard := range customCards {
+		if customCard.PHash == nil {
+			continue
+		}
+		if distance := images.Distance(phash, *customCard.PHash); distance < closestDistance {
+			closest = &customCards[i]
+			closestDistance = distance
+		}
+	}
+	if closest == nil {
+		return nil, 0, nil
+	}
+	return closest, closestDistance, nil
+}
+
+// HashMissing computes and stores the hash of every custom card image that doesn't have one yet. Images that can't be
+// fetched or decoded are returned by URL rather than stopping the rest.
+func (d *CustomCardDeduper) HashMissing(ctx context.Context) (int, map[string]error, error) {
+	customCards, err := d.storage.GetCustomCards(ctx)
+	if err != nil {
+		return 0, nil, fmt.Errorf(`get custom cards: %w`, err)
+	}
+	hashed := 0
+	failed := make(map[string]error)
+	for _, customCard := range customCards {
+		if customCard.PHash != nil {
+			continue
+		}
+		phash, err := d.HashImage(ctx, customCard.ImageURL)
+		if err != nil {
+			failed[customCard.ImageURL] = err
+			continue
+		}
+		if err := d.storage.SetCustomCardPHash(ctx, customCard.ImageURL, phash); err != nil {
+			return hashed, failed, fmt.Errorf(`set phash: %w`, err)
+		}
+		hashed++
+	}
+	return hashed, failed, nil
+}
+
+// DuplicateGroup is a set of custom cards read from what looks like the same image. Keep is the card the others should
+// be merged into.
+type DuplicateGroup struct {
+	Keep       cubes.CustomCard
+	Duplicates []cubes.CustomCard
+}
+
+// FindDuplicates groups stored custom cards whose image hashes are within the max distance of each other. Only cards
+// whose images have been hashed are considered.
+func (d *CustomCardDeduper) FindDuplicates(ctx context.Context) ([]DuplicateGroup, error) {
+	customCards, err := d.storage.GetCustomCards(ctx)
+	if err != nil {
+		return nil, fmt.Errorf(`get custom cards: %w`, err)
+	}
+	// Several image URLs can already map to one card; compare each card once using any of its hashes
+	byCard := make(map[string][]cubes.CustomCard)
+	var cardIDs []string
+	for _, customCard := range customCards {
+		if customCard.PHash == nil {
+			continue
+		}
+		if _, ok := byCard[customCard.Card.ID]; !ok {
+			cardIDs = append(cardIDs, customCard.Card.ID)
+		}
+		byCard[customCard.Card.ID] = append(byCard[customCard.Card.ID], customCard)
+	}
+	slices.Sort(cardIDs)
+
+	similar := func(a, b string) bool {
+		for _, x := range byCard[a] {
+			for _, y := range byCard[b] {
+				if images.Distance(*x.PHash, *y.PHash) <= d.maxDistance {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	// Single linkage: a card joins a group if it is similar to any card already in it
+	grouped := make(map[string]bool, len(cardIDs))
+	var groups []DuplicateGroup
+	for _, id := range cardIDs {
+		if grouped[id] {
+			continue
+		}
+		grouped[id] = true
+		members := []string{id}
+		for i := 0; i < len(members); i++ {
+			for _, other := range cardIDs {
+				if !grouped[other] && similar(members[i], other) {
+					grouped[other] = true
+					members = append(members, other)
+				}
+			}
+		}
+		if len(members) < 2 {
+			continue
+		}
+		groupCards := make([]cubes.CustomCard, 0, len(members))
+		for _, member := range members {
+			groupCards = append(groupCards, byCard[member][0])
+		}
+		slices.SortFunc(groupCards, keepOrder)
+		groups = append(groups, DuplicateGroup{Keep: groupCards[0], Duplicates: groupCards[1:]})
+	}
+	return groups, nil
+}
+
+// keepOrder prefers verified cards, then the oldest. Custom card IDs are UUIDv7 so they sort by creation time.
+func keepOrder(a, b cubes.CustomCard) int {
+	if a.Verified != b.Verified {
+		if a.Verified {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a.Card.ID, b.Card.ID)
+}
@@ -0,0 +1,186 @@
+package cards
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+	"github.com/mgdunn2/cube-datahub/cubes/images"
+)
+
+// cardArt draws shade over a w x h image in coordinates scaled to [0, 1), so the same shade at two sizes is a resize
+func cardArt(w, h int, shade func(u, v float64) float64) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			g := shade(float64(x)/float64(w), float64(y)/float64(h))
+			img.SetGray(x, y, color.Gray{Y: uint8(127 + 127*g)})
+		}
+	}
+	return img
+}
+
+func waves(u, v float64) float64 {
+	return math.Sin(2 * math.Pi * u * (1 + 3*v))
+}
+
+func stripes(u, v float64) float64 {
+	return math.Cos(2 * math.Pi * (5*u - 2*v))
+}
+
+// newImageServer serves each image as a PNG at its path
+func newImageServer(t *testing.T, imgs map[string]image.Image) *httptest.Server {
+	t.Helper()
+	encoded := make(map[string][]byte, len(imgs))
+	for path, img := range imgs {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatalf("encode %s: %v", path, err)
+		}
+		encoded[path] = buf.Bytes()
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := encoded[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newDedupeImageServer(t *testing.T) *httptest.Server {
+	return newImageServer(t, map[string]image.Image{
+		"/original.png":  cardArt(488, 680, waves),
+		"/resized.png":   cardArt(244, 340, waves),
+		"/different.png": cardArt(488, 680, stripes),
+	})
+}
+
+func TestCustomCardDeduper(t *testing.T) {
+	ctx := context.Background()
+	srv := newDedupeImageServer(t)
+	storage := newMemStorage()
+	deduper := NewCustomCardDeduper(storage)
+
+	hashes := make(map[string]uint64)
+	for _, path := range []string{"/original.png", "/resized.png", "/different.png"} {
+		hash, err := deduper.HashImage(ctx, srv.URL+path)
+		if err != nil {
+			t.Fatalf("hash %s: %v", path, err)
+		}
+		hashes[path] = hash
+	}
+	if d := images.Distance(hashes["/original.png"], hashes["/resized.png"]); d > DefaultCustomCardDistance {
+		t.Errorf("resized image is %d bits away, want at most %d", d, DefaultCustomCardDistance)
+	}
+	if d := images.Distance(hashes["/original.png"], hashes["/different.png"]); d <= DefaultCustomCardDistance {
+		t.Errorf("different image is only %d bits away", d)
+	}
+	if _, err := deduper.HashImage(ctx, srv.URL+"/missing.png"); err == nil {
+		t.Error("hashed a missing image")
+	}
+
+	// One card read from the original image and one from the different image
+	original := cubes.Card{ID: "01900000-0000-7000-8000-000000000001", Name: "Original", Set: cubes.CustomSet}
+	different := cubes.Card{ID: "01900000-0000-7000-8000-000000000002", Name: "Different", Set: cubes.CustomSet}
+	storage.UpsertCards(ctx, []cubes.Card{original, different})
+	storage.AddCustomCard(ctx, srv.URL+"/original.png", original.ID)
+	storage.SetCustomCardPHash(ctx, srv.URL+"/original.png", hashes["/original.png"])
+	storage.AddCustomCard(ctx, srv.URL+"/different.png", different.ID)
+	storage.SetCustomCardPHash(ctx, srv.URL+"/different.png", hashes["/different.png"])
+
+	match, distance, err := deduper.FindSimilar(ctx, hashes["/resized.png"])
+	if err != nil {
+		t.Fatalf("find similar: %v", err)
+	}
+	if match == nil || match.Card.ID != original.ID || distance > DefaultCustomCardDistance {
+		t.Errorf("resized image matched %v at distance %d, want %s", match, distance, original.Name)
+	}
+	strict := NewCustomCardDeduper(storage, DeduperWithMaxDistance(-1))
+	if match, _, _ := strict.FindSimilar(ctx, hashes["/resized.png"]); match != nil {
+		t.Errorf("resized image matched %s with a negative max distance", match.Card.Name)
+	}
+
+	groups, err := deduper.FindDuplicates(ctx)
+	if err != nil {
+		t.Fatalf("find duplicates: %v", err)
+	}
+	if len(groups) != 0 {
+		t.Errorf("groups = %+v, want none", groups)
+	}
+
+	// A second card read from the resized image duplicates the original
+	resized := cubes.Card{ID: "01900000-0000-7000-8000-000000000003", Name: "Resized", Set: cubes.CustomSet}
+	storage.UpsertCards(ctx, []cubes.Card{resized})
+	storage.AddCustomCard(ctx, srv.URL+"/resized.png", resized.ID)
+	storage.SetCustomCardPHash(ctx, srv.URL+"/resized.png", hashes["/resized.png"])
+	storage.SetCustomCardVerified(ctx, resized.ID, true)
+	groups, err = deduper.FindDuplicates(ctx)
+	if err != nil {
+		t.Fatalf("find duplicates: %v", err)
+	}
+	if len(groups) != 1 || len(groups[0].Duplicates) != 1 {
+		t.Fatalf("groups = %+v, want one pair", groups)
+	}
+	// The verified card is kept even though it is newer
+	if keep, dup := groups[0].Keep.Card.ID, groups[0].Duplicates[0].Card.ID; keep != resized.ID || dup != original.ID {
+		t.Errorf("group keeps %s and merges %s, want %s and %s", keep, dup, resized.ID, original.ID)
+	}
+}
+
+func TestCubeCobraLoadReusesResizedCustomCard(t *testing.T) {
+	ctx := context.Background()
+	srv := newDedupeImageServer(t)
+	storage := newMemStorage()
+	reader := &staticCardReader{card: cubes.Card{Name: "Fixture Custom Card", Type: "Creature", Set: cubes.CustomSet}}
+	loader := NewCubeCobraLoader(storage, nil, reader)
+
+	load := func(path string) string {
+		t.Helper()
+		cube := cubes.CubeCobraCube{
+			ID:   "dedupe-cube",
+			Name: "Dedupe Cube",
+			Cards: cubes.CubeCobraCards{Boards: map[string][]cubes.CubeCobraCard{
+				cubes.MainBoard: {{Tags: []string{"custom"}, ImageURL: srv.URL + path}},
+			}},
+		}
+		if err := loader.loadCube(ctx, cube.ID, cube); err != nil {
+			t.Fatalf("load %s: %v", path, err)
+		}
+		return storage.customCards[srv.URL+path]
+	}
+
+	originalID := load("/original.png")
+	if reader.reads != 1 {
+		t.Fatalf("read %d images, want 1", reader.reads)
+	}
+	storage.SetCustomCardVerified(ctx, originalID, true)
+
+	if resizedID := load("/resized.png"); resizedID != originalID {
+		t.Errorf("resized image stored as %s, want the original card %s", resizedID, originalID)
+	}
+	if reader.reads != 1 {
+		t.Errorf("read %d images after the resized one, want 1", reader.reads)
+	}
+	if storage.verified[originalID] {
+		t.Error("reused card is still verified")
+	}
+
+	if differentID := load("/different.png"); differentID == originalID || differentID == "" {
+		t.Errorf("different image stored as %q, want a new card", differentID)
+	}
+	if reader.reads != 2 {
+		t.Errorf("read %d images after the different one, want 2", reader.reads)
+	}
+}
@@ -0,0 +1,112 @@
+package cards
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+// mtgoDeck is the layout of an MTGO .dek file
+type mtgoDeck struct {
+	XMLName              xml.Name       `xml:"Deck"`
+	XSD                  string         `xml:"xmlns:xsd,attr"`
+	XSI                  string         `xml:"xmlns:xsi,attr"`
+	NetDeckID            int            `xml:"NetDeckID"`
+	PreconstructedDeckID int            `xml:"PreconstructedDeckID"`
+	Cards                []mtgoDeckCard `xml:"Cards"`
+}
+
+type mtgoDeckCard struct {
+	CatID      int    `xml:"CatID,attr"`
+	Quantity   int    `xml:"Quantity,attr"`
+	Sideboard  bool   `xml:"Sideboard,attr"`
+	Name       string `xml:"Name,attr"`
+	Annotation int    `xml:"Annotation,attr"`
+}
+
+// DekFormat reads and writes MTGO .dek files. Cards are matched on their MTGO catalog ID and then by name.
+type DekFormat struct{}
+
+func NewDekFormat() *DekFormat {
+	return &DekFormat{}
+}
+
+// ParseDek reads the cards of a .dek file. Line is the line of each card's element.
+func ParseDek(r io.Reader) ([]DecklistEntry, error) {
+	decoder := xml.NewDecoder(r)
+	var entries []DecklistEntry
+	for {
+		line, _ := decoder.InputPos()
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf(`read dek: %w`, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "Cards" {
+			continue
+		}
+		var card mtgoDeckCard
+		if err := decoder.DecodeElement(&card, &start); err != nil {
+			return nil, fmt.Errorf(`line %d: decode card: %w`, line, err)
+		}
+		if card.Quantity <= 0 {
+			continue
+		}
+		entries = append(entries, DecklistEntry{
+			Line:      line,
+			Text:      strconv.Itoa(card.Quantity) + " " + card.Name,
+			Count:     card.Quantity,
+			Name:      card.Name,
+			MTGOID:    card.CatID,
+			Sideboard: card.Sideboard,
+		})
+	}
+	return entries, nil
+}
+
+// ReadDeck resolves a .dek file against the deck's cube. Unresolved cards come back as an *UnresolvedLinesError
+// alongside the deck.
+func (d *DekFormat) ReadDeck(_ context.Context, deck cubes.Deck, data []byte) (cubes.Deck, error) {
+	entries, err := ParseDek(bytes.NewReader(data))
+	if err != nil {
+		return cubes.Deck{}, err
+	}
+	return resolveEntries(deck, entries)
+}
+
+// WriteDeck writes the deck as a .dek file. Cards without an MTGO ID, such as custom cards, are written by name with a
+// CatID of 0.
+func (d *DekFormat) WriteDeck(w io.Writer, deck cubes.Deck) error {
+	dek := mtgoDeck{
+		XSD: "http://www.w3.org/2001/XMLSchema",
+		XSI: "http://www.w3.org/2001/XMLSchema-instance",
+	}
+	for _, entry := range deckEntries(deck) {
+		dek.Cards = append(dek.Cards, mtgoDeckCard{
+			CatID:     entry.MTGOID,
+			Quantity:  entry.Count,
+			Sideboard: entry.Sideboard,
+			Name:      entry.Name,
+		})
+	}
+	out, err := xml.MarshalIndent(dek, "", "  ")
+	if err != nil {
+		return fmt.Errorf(`marshal dek: %w`, err)
+	}
+	if _, err := io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?>`+"\n"); err != nil {
+		return err
+	}
+	if _, err := w.Write(append(out, '\n')); err != nil {
+		return err
+	}
+	return nil
+}
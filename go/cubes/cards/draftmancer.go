@@ -0,0 +1,234 @@
+package cards
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+// DefaultDraftmancerPackSize is the pack size used when the settings give neither one nor any slots
+const DefaultDraftmancerPackSize = 15
+
+// draftmancerMainSlot is the slot every card goes in when no slots are configured
+const draftmancerMainSlot = "MainSlot"
+
+// DraftmancerSettings controls how a cube is laid out in packs on Draftmancer
+type DraftmancerSettings struct {
+	// PackSize is the number of cards in a pack when no slots are given
+	PackSize int `json:"packSize,omitempty"`
+	// Slots split the cube by tag; every pack takes Count cards from each slot. A card goes in the first slot whose tag
+	// it has, and a slot without a tag, wherever it is listed, takes every card left over.
+	Slots []DraftmancerSlot `json:"slots,omitempty"`
+	// Layouts are alternative pack layouts, picked at random by weight, that set how many cards come from each slot.
+	// Slot counts are ignored when there are layouts.
+	Layouts           []DraftmancerLayout `json:"layouts,omitempty"`
+	ColorBalance      bool                `json:"colorBalance,omitempty"`
+	WithReplacement   bool                `json:"withReplacement,omitempty"`
+	BoostersPerPlayer int                 `json:"boostersPerPlayer,omitempty"`
+}
+
+type DraftmancerSlot struct {
+	Name  string `json:"name"`
+	Tag   string `json:"tag,omitempty"`
+	Count int    `json:"count,omitempty"`
+}
+
+type DraftmancerLayout struct {
+	Name   string         `json:"name"`
+	Weight int            `json:"weight"`
+	Slots  map[string]int `json:"slots"`
+}
+
+// draftmancerSettingsSection is the [Settings] section of a Draftmancer cube file
+type draftmancerSettingsSection struct {
+	Name              string                       `json:"name,omitempty"`
+	Layouts           map[string]draftmancerLayout `json:"layouts,omitempty"`
+	ColorBalance      bool                         `json:"colorBalance"`
+	WithReplacement   bool                         `json:"withReplacement"`
+	BoostersPerPlayer int                          `json:"boostersPerPlayer,omitempty"`
+}
+
+type draftmancerLayout struct {
+	Weight int            `json:"weight"`
+	Slots  map[string]int `json:"slots"`
+}
+
+// draftmancerCard is a custom card definition in Draftmancer's [CustomCards] section
+type draftmancerCard struct {
+	Name       string   `json:"name"`
+	ManaCost   string   `json:"mana_cost"`
+	Type       string   `json:"type"`
+	Subtypes   []string `json:"subtypes,omitempty"`
+	Colors     []string `json:"colors"`
+	Set        string   `json:"set"`
+	Image      string   `json:"image,omitempty"`
+	OracleText string   `json:"oracle_text,omitempty"`
+	Power      *int     `json:"power,omitempty"`
+	Toughness  *int     `json:"toughness,omitempty"`
+	Loyalty    *int     `json:"loyalty,omitempty"`
+}
+
+// Validate checks that slot and layout settings are consistent
+func (s DraftmancerSettings) Validate() error {
+	if s.PackSize < 0 {
+		return errors.New(`pack size can't be negative`)
+	}
+	names := make(map[string]bool, len(s.Slots))
+	catchAll := 0
+	for _, slot := range s.Slots {
+		if slot.Name == "" {
+			return errors.New(`every slot needs a name`)
+		}
+		if names[slot.Name] {
+			return fmt.Errorf(`slot %s is listed twice`, slot.Name)
+		}
+		names[slot.Name] = true
+		if slot.Tag == "" {
+			catchAll++
+		}
+		if len(s.Layouts) == 0 && slot.Count <= 0 {
+			return fmt.Errorf(`slot %s needs a positive count`, slot.Name)
+		}
+	}
+	if catchAll > 1 {
+		return errors.New(`only one slot can be without a tag`)
+	}
+	if len(s.Layouts) > 0 && len(s.Slots) == 0 {
+		return errors.New(`layouts need slots`)
+	}
+	for _, layout := range s.Layouts {
+		if layout.Weight <= 0 {
+			return fmt.Errorf(`layout %s needs a positive weight`, layout.Name)
+		}
+		for slot := range layout.Slots {
+			if !names[slot] {
+				return fmt.Errorf(`layout %s uses unknown slot %s`, layout.Name, slot)
+			}
+		}
+	}
+	return nil
+}
+
+// WriteDraftmancerCube writes the cube's mainboard as a Draftmancer custom card list. Custom cards are defined in the
+// file with their images; real cards are listed by name and set for Draftmancer to look up.
+func WriteDraftmancerCube(w io.Writer, cube cubes.Cube, settings DraftmancerSettings) error {
+	if err := settings.Validate(); err != nil {
+		return fmt.Errorf(`invalid settings: %w`, err)
+	}
+	slots := settings.Slots
+	if len(slots) == 0 {
+		packSize := settings.PackSize
+		if packSize == 0 {
+			packSize = DefaultDraftmancerPackSize
+		}
+		slots = []DraftmancerSlot{{Name: draftmancerMainSlot, Count: packSize}}
+	}
+
+	slotCards := make(map[string][]string, len(slots))
+	var customCards []draftmancerCard
+	var unslotted []string
+	seenCustom := make(map[string]bool)
+	for _, card := range cube.Cards {
+		tags := cube.Tags[card.ID]
+		slot := slices.IndexFunc(slots, func(s DraftmancerSlot) bool {
+			return s.Tag != "" && slices.Contains(tags, s.Tag)
+		})
+		if slot == -1 {
+			slot = slices.IndexFunc(slots, func(s DraftmancerSlot) bool { return s.Tag == "" })
+		}
+		if slot == -1 {
+			unslotted = append(unslotted, card.Name)
+			continue
+		}
+
+		line := card.Name
+		if card.IsCustom() || slices.Contains(tags, "custom") {
+			if !seenCustom[card.ID] {
+				seenCustom[card.ID] = true
+				customCards = append(customCards, toDraftmancerCard(card))
+			}
+		} else if card.Set != "" {
+			line += fmt.Sprintf(" (%s)", strings.ToUpper(card.Set))
+		}
+		slotCards[slots[slot].Name] = append(slotCards[slots[slot].Name], "1 "+line)
+	}
+	if len(unslotted) > 0 {
+		return fmt.Errorf(`%d cards have no slot: %v`, len(unslotted), unslotted)
+	}
+
+	section := draftmancerSettingsSection{
+		Name:              cube.Name,
+		ColorBalance:      settings.ColorBalance,
+		WithReplacement:   settings.WithReplacement,
+		BoostersPerPlayer: settings.BoostersPerPlayer,
+	}
+	if len(settings.Layouts) > 0 {
+		section.Layouts = make(map[string]draftmancerLayout, len(settings.Layouts))
+		for _, layout := range settings.Layouts {
+			section.Layouts[layout.Name] = draftmancerLayout{Weight: layout.Weight, Slots: layout.Slots}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := writeDraftmancerJSON(bw, "Settings", section); err != nil {
+		return err
+	}
+	if len(customCards) > 0 {
+		if err := writeDraftmancerJSON(bw, "CustomCards", customCards); err != nil {
+			return err
+		}
+	}
+	for _, slot := range slots {
+		// Layouts set the slot sizes, so slot headers only carry a count without them
+		if len(settings.Layouts) > 0 {
+			fmt.Fprintf(bw, "[%s]\n", slot.Name)
+		} else {
+			fmt.Fprintf(bw, "[%s(%d)]\n", slot.Name, slot.Count)
+		}
+		for _, line := range slotCards[slot.Name] {
+			bw.WriteString(line + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+func writeDraftmancerJSON(w *bufio.Writer, section string, v any) error {
+	raw, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return fmt.Errorf(`marshal %s: %w`, section, err)
+	}
+	fmt.Fprintf(w, "[%s]\n%s\n", section, raw)
+	return nil
+}
+
+func toDraftmancerCard(card cubes.Card) draftmancerCard {
+	typeLine, _, _ := strings.Cut(card.TypeLine(), " — ")
+	dc := draftmancerCard{
+		Name:       card.Name,
+		Type:       typeLine,
+		Subtypes:   card.SubType,
+		Colors:     make([]string, 0, len(card.Colors)),
+		Set:        cubes.CustomSet,
+		Image:      card.ImageURI,
+		OracleText: card.TextBox,
+	}
+	if card.ManaCost != nil {
+		dc.ManaCost = *card.ManaCost
+	}
+	for _, c := range card.Colors {
+		dc.Colors = append(dc.Colors, string(c))
+	}
+	if strings.Contains(card.Type, "Creature") || slices.Contains(card.SubType, "Vehicle") {
+		dc.Power, dc.Toughness = &card.Power, &card.Toughness
+	}
+	if strings.Contains(card.Type, "Planeswalker") {
+		dc.Loyalty = &card.Loyalty
+	}
+	return dc
+}
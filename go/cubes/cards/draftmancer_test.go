@@ -0,0 +1,225 @@
+package cards
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+var (
+	draftBolt   = cubes.Card{ID: "bolt", Name: "Lightning Bolt", Type: "Instant", Set: "m11"}
+	draftGoyf   = cubes.Card{ID: "goyf", Name: "Tarmogoyf", Type: "Creature", Set: "fut"}
+	draftTarn   = cubes.Card{ID: "tarn", Name: "Scalding Tarn", Type: "Land", Set: "zen"}
+	draftCustom = cubes.Card{
+		ID:        "custom",
+		Name:      "Fixture Custom Card",
+		ManaCost:  ptr("{1}{U}"),
+		Type:      "Creature",
+		SubType:   []string{"Human", "Wizard"},
+		TextBox:   "Flying",
+		Power:     2,
+		Toughness: 1,
+		Colors:    []cubes.Color{cubes.Blue},
+		Set:       cubes.CustomSet,
+		ImageURI:  customImageURL,
+	}
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// draftmancerSections splits a Draftmancer cube file into the body of each [section], keyed by its header
+func draftmancerSections(t *testing.T, out string) (map[string][]string, []string) {
+	t.Helper()
+	sections := make(map[string][]string)
+	var order []string
+	var current string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") && !strings.HasPrefix(line, "[\t") {
+			current = strings.Trim(line, "[]")
+			order = append(order, current)
+			continue
+		}
+		if current == "" {
+			t.Fatalf("line %q before any section", line)
+		}
+		sections[current] = append(sections[current], line)
+	}
+	return sections, order
+}
+
+func writeDraftmancer(t *testing.T, cube cubes.Cube, settings DraftmancerSettings) (map[string][]string, []string) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteDraftmancerCube(&buf, cube, settings); err != nil {
+		t.Fatalf("write draftmancer cube: %v", err)
+	}
+	return draftmancerSections(t, buf.String())
+}
+
+func TestDraftmancerSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings DraftmancerSettings
+		wantErr  string
+	}{
+		{"empty", DraftmancerSettings{}, ""},
+		{"pack size", DraftmancerSettings{PackSize: 12}, ""},
+		{"negative pack size", DraftmancerSettings{PackSize: -1}, "negative"},
+		{"slots", DraftmancerSettings{Slots: []DraftmancerSlot{{Name: "Lands", Tag: "land", Count: 1}, {Name: "Rest", Count: 14}}}, ""},
+		{"unnamed slot", DraftmancerSettings{Slots: []DraftmancerSlot{{Count: 1}}}, "needs a name"},
+		{"duplicate slot", DraftmancerSettings{Slots: []DraftmancerSlot{{Name: "A", Tag: "a", Count: 1}, {Name: "A", Tag: "b", Count: 1}}}, "listed twice"},
+		{"slot without count", DraftmancerSettings{Slots: []DraftmancerSlot{{Name: "Rest"}}}, "positive count"},
+		{"two catch-alls", DraftmancerSettings{Slots: []DraftmancerSlot{{Name: "A", Count: 1}, {Name: "B", Count: 1}}}, "without a tag"},
+		{"layouts without slots", DraftmancerSettings{Layouts: []DraftmancerLayout{{Name: "L", Weight: 1}}}, "need slots"},
+		{"layout slots need no count", DraftmancerSettings{
+			Slots:   []DraftmancerSlot{{Name: "Rest"}},
+			Layouts: []DraftmancerLayout{{Name: "L", Weight: 1, Slots: map[string]int{"Rest": 15}}},
+		}, ""},
+		{"layout without weight", DraftmancerSettings{
+			Slots:   []DraftmancerSlot{{Name: "Rest"}},
+			Layouts: []DraftmancerLayout{{Name: "L", Slots: map[string]int{"Rest": 15}}},
+		}, "positive weight"},
+		{"layout with unknown slot", DraftmancerSettings{
+			Slots:   []DraftmancerSlot{{Name: "Rest"}},
+			Layouts: []DraftmancerLayout{{Name: "L", Weight: 1, Slots: map[string]int{"Rares": 1}}},
+		}, "unknown slot Rares"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.settings.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validate: got %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteDraftmancerCubeDefaultSlot(t *testing.T) {
+	cube := cubes.Cube{Name: "Fixture Cube", Cards: []cubes.Card{draftBolt, draftBolt, draftGoyf}}
+	sections, order := writeDraftmancer(t, cube, DraftmancerSettings{})
+
+	if want := []string{"Settings", "MainSlot(15)"}; !slices.Equal(order, want) {
+		t.Fatalf("sections = %v, want %v", order, want)
+	}
+	var settings draftmancerSettingsSection
+	if err := json.Unmarshal([]byte(strings.Join(sections["Settings"], "\n")), &settings); err != nil {
+		t.Fatalf("unmarshal settings: %v", err)
+	}
+	if settings.Name != "Fixture Cube" || settings.Layouts != nil {
+		t.Errorf("settings = %+v, want the cube name and no layouts", settings)
+	}
+	if want := []string{"1 Lightning Bolt (M11)", "1 Lightning Bolt (M11)", "1 Tarmogoyf (FUT)"}; !slices.Equal(sections["MainSlot(15)"], want) {
+		t.Errorf("main slot = %v, want %v", sections["MainSlot(15)"], want)
+	}
+}
+
+func TestWriteDraftmancerCubeSlots(t *testing.T) {
+	cube := cubes.Cube{
+		Name:  "Fixture Cube",
+		Cards: []cubes.Card{draftBolt, draftTarn, draftGoyf},
+		Tags:  map[string][]string{"tarn": {"land"}, "goyf": {"rare"}},
+	}
+	// The catch-all is listed first but only takes the cards no tagged slot wants
+	settings := DraftmancerSettings{Slots: []DraftmancerSlot{
+		{Name: "Rest", Count: 13},
+		{Name: "Lands", Tag: "land", Count: 1},
+		{Name: "Rares", Tag: "rare", Count: 1},
+	}}
+	sections, order := writeDraftmancer(t, cube, settings)
+
+	if want := []string{"Settings", "Rest(13)", "Lands(1)", "Rares(1)"}; !slices.Equal(order, want) {
+		t.Fatalf("sections = %v, want %v", order, want)
+	}
+	for header, want := range map[string][]string{
+		"Rest(13)": {"1 Lightning Bolt (M11)"},
+		"Lands(1)": {"1 Scalding Tarn (ZEN)"},
+		"Rares(1)": {"1 Tarmogoyf (FUT)"},
+	} {
+		if !slices.Equal(sections[header], want) {
+			t.Errorf("%s = %v, want %v", header, sections[header], want)
+		}
+	}
+
+	// Without a catch-all every card needs a tagged slot
+	settings.Slots = settings.Slots[1:]
+	var buf bytes.Buffer
+	err := WriteDraftmancerCube(&buf, cube, settings)
+	if err == nil || !strings.Contains(err.Error(), "1 cards have no slot: [Lightning Bolt]") {
+		t.Errorf("write: got %v, want Lightning Bolt unslotted", err)
+	}
+}
+
+func TestWriteDraftmancerCubeLayouts(t *testing.T) {
+	cube := cubes.Cube{
+		Name:  "Fixture Cube",
+		Cards: []cubes.Card{draftBolt, draftTarn},
+		Tags:  map[string][]string{"tarn": {"land"}},
+	}
+	settings := DraftmancerSettings{
+		Slots: []DraftmancerSlot{{Name: "Lands", Tag: "land"}, {Name: "Rest"}},
+		Layouts: []DraftmancerLayout{
+			{Name: "Normal", Weight: 3, Slots: map[string]int{"Rest": 14, "Lands": 1}},
+			{Name: "Landless", Weight: 1, Slots: map[string]int{"Rest": 15}},
+		},
+		ColorBalance:      true,
+		BoostersPerPlayer: 3,
+	}
+	sections, order := writeDraftmancer(t, cube, settings)
+
+	// Layouts set the slot sizes, so the headers have no counts
+	if want := []string{"Settings", "Lands", "Rest"}; !slices.Equal(order, want) {
+		t.Fatalf("sections = %v, want %v", order, want)
+	}
+	var section draftmancerSettingsSection
+	if err := json.Unmarshal([]byte(strings.Join(sections["Settings"], "\n")), &section); err != nil {
+		t.Fatalf("unmarshal settings: %v", err)
+	}
+	if !section.ColorBalance || section.BoostersPerPlayer != 3 || len(section.Layouts) != 2 {
+		t.Errorf("settings = %+v, want colour balance, 3 boosters and 2 layouts", section)
+	}
+	if normal := section.Layouts["Normal"]; normal.Weight != 3 || normal.Slots["Lands"] != 1 || normal.Slots["Rest"] != 14 {
+		t.Errorf("normal layout = %+v", normal)
+	}
+}
+
+func TestWriteDraftmancerCubeCustomCards(t *testing.T) {
+	cube := cubes.Cube{Name: "Fixture Cube", Cards: []cubes.Card{draftCustom, draftBolt, draftCustom}}
+	sections, order := writeDraftmancer(t, cube, DraftmancerSettings{PackSize: 10})
+
+	if want := []string{"Settings", "CustomCards", "MainSlot(10)"}; !slices.Equal(order, want) {
+		t.Fatalf("sections = %v, want %v", order, want)
+	}
+	var custom []draftmancerCard
+	if err := json.Unmarshal([]byte(strings.Join(sections["CustomCards"], "\n")), &custom); err != nil {
+		t.Fatalf("unmarshal custom cards: %v", err)
+	}
+	// Both copies share one definition
+	if len(custom) != 1 {
+		t.Fatalf("custom cards = %+v, want one", custom)
+	}
+	card := custom[0]
+	if card.Name != "Fixture Custom Card" || card.ManaCost != "{1}{U}" || card.Type != "Creature" ||
+		!slices.Equal(card.Subtypes, []string{"Human", "Wizard"}) || !slices.Equal(card.Colors, []string{"U"}) ||
+		card.Set != cubes.CustomSet || card.Image != customImageURL || card.OracleText != "Flying" {
+		t.Errorf("custom card = %+v", card)
+	}
+	if card.Power == nil || *card.Power != 2 || card.Toughness == nil || *card.Toughness != 1 || card.Loyalty != nil {
+		t.Errorf("custom card stats = %v/%v loyalty %v, want 2/1 and no loyalty", card.Power, card.Toughness, card.Loyalty)
+	}
+	want := []string{"1 Fixture Custom Card", "1 Lightning Bolt (M11)", "1 Fixture Custom Card"}
+	if !slices.Equal(sections["MainSlot(10)"], want) {
+		t.Errorf("main slot = %v, want %v", sections["MainSlot(10)"], want)
+	}
+}
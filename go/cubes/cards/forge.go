@@ -0,0 +1,131 @@
+package cards
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+// ForgeFormat reads and writes Forge .dck files. Names are resolved the same way as CockatriceFormat. Storage may be
+// nil.
+type ForgeFormat struct {
+	storage cubes.Storage
+}
+
+func NewForgeFormat(storage cubes.Storage) *ForgeFormat {
+	return &ForgeFormat{storage: storage}
+}
+
+// IsForgeDeck reports whether a .dck file is a Forge deck rather than an XMage one. Forge decks are split into
+// bracketed sections such as [metadata] and [Main].
+func IsForgeDeck(data []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		return strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]")
+	}
+	return false
+}
+
+// parseForge reads the deck name from [metadata] and the cards of the [Main] and [Sideboard] sections. Cards are written
+// "4 Lightning Bolt|A25|1", where the optional fields are the set and Forge's art index.
+func parseForge(r io.Reader) (string, []DecklistEntry, error) {
+	var name string
+	var entries []DecklistEntry
+	section := ""
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.Trim(line, "[]"))
+			continue
+		}
+		switch section {
+		case "metadata":
+			if key, value, ok := strings.Cut(line, "="); ok && strings.EqualFold(key, "name") {
+				name = strings.TrimSpace(value)
+			}
+		case "main", "sideboard":
+			countStr, card, ok := strings.Cut(line, " ")
+			count, err := strconv.Atoi(countStr)
+			if !ok || err != nil {
+				return "", nil, fmt.Errorf(`line %d: unrecognised card line %q`, lineNumber, line)
+			}
+			if count == 0 {
+				continue
+			}
+			fields := strings.Split(card, "|")
+			entry := DecklistEntry{
+				Line:      lineNumber,
+				Text:      line,
+				Count:     count,
+				Name:      strings.TrimSpace(fields[0]),
+				Sideboard: section == "sideboard",
+			}
+			if len(fields) > 1 {
+				entry.Set = strings.ToLower(strings.TrimSpace(fields[1]))
+			}
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", nil, fmt.Errorf(`read dck: %w`, err)
+	}
+	return name, entries, nil
+}
+
+// ReadDeck resolves a Forge .dck file into the deck. The deck name becomes the description if the deck doesn't have
+// one.
+func (f *ForgeFormat) ReadDeck(ctx context.Context, deck cubes.Deck, data []byte) (cubes.Deck, error) {
+	name, entries, err := parseForge(bytes.NewReader(data))
+	if err != nil {
+		return cubes.Deck{}, err
+	}
+	if deck.Description == "" {
+		deck.Description = name
+	}
+	return resolveEntriesWithStorage(ctx, f.storage, deck, entries)
+}
+
+// WriteDeck writes the deck as a Forge .dck file
+func (f *ForgeFormat) WriteDeck(w io.Writer, deck cubes.Deck) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "[metadata]\nName=%s\n", deck.Description)
+	var main, side []string
+	for _, entry := range deckEntries(deck) {
+		line := fmt.Sprintf("%d %s", entry.Count, entry.Name)
+		if set := exportSet(entry.Set); set != "" {
+			line += "|" + set
+		}
+		if entry.Sideboard {
+			side = append(side, line)
+		} else {
+			main = append(main, line)
+		}
+	}
+	bw.WriteString("[Main]\n")
+	for _, line := range main {
+		bw.WriteString(line + "\n")
+	}
+	if len(side) > 0 {
+		bw.WriteString("[Sideboard]\n")
+		for _, line := range side {
+			bw.WriteString(line + "\n")
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,270 @@
+package cards
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Errors a StatusError matches with errors.Is depending on its status code
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrRateLimited = errors.New("rate limited")
+	ErrUnavailable = errors.New("service unavailable")
+	ErrBadRequest  = errors.New("bad request")
+)
+
+// StatusError is returned when an API responds with a non-2xx status. Code, Type and Details are filled from Scryfall
+// style error bodies when present.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Code       string
+	Type       string
+	Details    string
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Details != "" {
+		msg += ": " + e.Details
+	}
+	return msg
+}
+
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrUnavailable:
+		return e.StatusCode >= 500
+	case ErrBadRequest:
+		return e.StatusCode >= 400 && e.StatusCode < 500 && e.StatusCode != http.StatusNotFound && e.StatusCode != http.StatusTooManyRequests
+	}
+	return false
+}
+
+// scryfallRequestInterval keeps us at Scryfall's requested 10 requests per second
+const scryfallRequestInterval = 100 * time.Millisecond
+
+const (
+	defaultMaxRetries = 3
+	defaultBaseDelay  = 500 * time.Millisecond
+	defaultMaxDelay   = 30 * time.Second
+)
+
+// apiClient makes context aware JSON requests. Rate limited, unavailable and network failures are retried with jittered
+// exponential backoff, honouring Retry-After up to maxDelay when the server sends it.
+type apiClient struct {
+	client     *http.Client
+	limiter    *rateLimiter
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+func newAPIClient(client *http.Client, requestInterval time.Duration, maxRetries int) *apiClient {
+	a := &apiClient{
+		client:     client,
+		maxRetries: maxRetries,
+		baseDelay:  defaultBaseDelay,
+		maxDelay:   defaultMaxDelay,
+	}
+	if requestInterval > 0 {
+		a.limiter = &rateLimiter{interval: requestInterval}
+	}
+	return a
+}
+
+// getJSON decodes the response into out and returns how many times the request was retried
+func (a *apiClient) getJSON(ctx context.Context, url string, out any) (int, error) {
+	body, retries, err := a.do(ctx, http.MethodGet, url, "application/json", nil)
+	if err != nil {
+		return retries, err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return retries, fmt.Errorf(`unmarshal response: %w`, err)
+	}
+	return retries, nil
+}
+
+// postJSON sends in as the request body, decodes the response into out and returns how many times the request was
+// retried
+func (a *apiClient) postJSON(ctx context.Context, url string, in, out any) (int, error) {
+	reqBody, err := json.Marshal(in)
+	if err != nil {
+		return 0, fmt.Errorf(`marshal request: %w`, err)
+	}
+	body, retries, err := a.do(ctx, http.MethodPost, url, "application/json", reqBody)
+	if err != nil {
+		return retries, err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return retries, fmt.Errorf(`unmarshal response: %w`, err)
+	}
+	return retries, nil
+}
+
+// getBytes returns the raw response body, e.g. an image
+func (a *apiClient) getBytes(ctx context.Context, url string) ([]byte, error) {
+	body, _, err := a.do(ctx, http.MethodGet, url, "*/*", nil)
+	return body, err
+}
+
+// do sends the request, retrying failures that are worth retrying, and returns the body of the first 2xx response
+// along with the number of retries it took
+func (a *apiClient) do(ctx context.Context, method, url, accept string, reqBody []byte) ([]byte, int, error) {
+	for attempt := 0; ; attempt++ {
+		body, retryAfter, err := a.doOnce(ctx, method, url, accept, reqBody)
+		if err == nil {
+			return body, attempt, nil
+		}
+		if attempt >= a.maxRetries || !retryable(ctx, err) {
+			return nil, attempt, err
+		}
+		// A server asking us to wait longer than maxDelay still only gets maxDelay
+		delay := min(retryAfter, a.maxDelay)
+		if delay <= 0 {
+			delay = a.backoff(attempt)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, attempt, fmt.Errorf(`waiting to retry after %w: %w`, err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func (a *apiClient) doOnce(ctx context.Context, method, url, accept string, reqBody []byte) ([]byte, time.Duration, error) {
+	if a.limiter != nil {
+		if err := a.limiter.wait(ctx); err != nil {
+			return nil, 0, err
+		}
+	}
+	var bodyReader io.Reader
+	if reqBody != nil {
+		bodyReader = bytes.NewReader(reqBody)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+	if err != nil {
+		return nil, 0, fmt.Errorf(`new request: %w`, err)
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", "cube-datahub/1.0")
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	rsp, err := a.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf(`%s %s: %w`, method, url, err)
+	}
+	defer rsp.Body.Close()
+	body, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf(`read body: %w`, err)
+	}
+	if rsp.StatusCode >= 200 && rsp.StatusCode < 300 {
+		return body, 0, nil
+	}
+
+	statusErr := &StatusError{Method: method, URL: url, StatusCode: rsp.StatusCode}
+	var apiErr struct {
+		Code    string `json:"code"`
+		Type    string `json:"type"`
+		Details string `json:"details"`
+	}
+	if json.Unmarshal(body, &apiErr) == nil {
+		statusErr.Code = apiErr.Code
+		statusErr.Type = apiErr.Type
+		statusErr.Details = apiErr.Details
+	}
+	return nil, parseRetryAfter(rsp.Header.Get("Retry-After")), statusErr
+}
+
+// backoff returns a random delay between zero and base * 2^attempt, capped at maxDelay
+func (a *apiClient) backoff(attempt int) time.Duration {
+	ceiling := a.baseDelay << attempt
+	if ceiling <= 0 || ceiling > a.maxDelay {
+		ceiling = a.maxDelay
+	}
+	return time.Duration(rand.Int64N(int64(ceiling))) + 1
+}
+
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return errors.Is(statusErr, ErrRateLimited) || errors.Is(statusErr, ErrUnavailable)
+	}
+	// Of the transport errors, only those a later attempt might not hit are retried: timeouts, dropped connections and
+	// truncated bodies. A bad URL or a refused TLS handshake won't fix itself.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// rateLimiter spaces requests at least interval apart
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,128 @@
+package cards
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mgdunn2/cube-datahub/cubes/cards/cardstest"
+)
+
+const boltID = "77c6fa74-5543-42ac-9ead-0e890b188e99"
+
+// newFastScryfallLoader returns a loader against srv whose retries wait at most maxDelay
+func newFastScryfallLoader(srv *cardstest.Server, maxDelay time.Duration) *ScryfallApiCardLoader {
+	loader := NewScryfallLoader(newMemStorage(), ScryfallLoaderWithBaseURL(srv.URL))
+	loader.api.baseDelay = time.Millisecond
+	loader.api.maxDelay = maxDelay
+	return loader
+}
+
+func TestRetryAfterRateLimit(t *testing.T) {
+	srv := newTestServer(t)
+	loader := newFastScryfallLoader(srv, 50*time.Millisecond)
+	// Retry-After is far beyond maxDelay, so the retry has to be clamped for the test to finish quickly
+	srv.FailNext(1, http.StatusTooManyRequests, "120")
+
+	start := time.Now()
+	report, err := loader.LoadCards(context.Background(), []string{boltID})
+	if err != nil {
+		t.Fatalf("load cards: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("retry waited %v, Retry-After was not clamped", elapsed)
+	}
+	if len(report.Found) != 1 || len(report.Retried) != 1 {
+		t.Errorf("report = %+v, want one found and retried card", report)
+	}
+	if got := srv.Requests(); got != 2 {
+		t.Errorf("server saw %d requests, want 2", got)
+	}
+}
+
+func TestRetryUnavailable(t *testing.T) {
+	srv := newTestServer(t)
+	loader := newFastScryfallLoader(srv, 10*time.Millisecond)
+	srv.FailNext(1, http.StatusServiceUnavailable, "")
+
+	report, err := loader.LoadCards(context.Background(), []string{boltID})
+	if err != nil {
+		t.Fatalf("load cards: %v", err)
+	}
+	if len(report.Found) != 1 || len(report.Retried) != 1 {
+		t.Errorf("report = %+v, want one found and retried card", report)
+	}
+	if got := srv.Requests(); got != 2 {
+		t.Errorf("server saw %d requests, want 2", got)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	srv := newTestServer(t)
+	loader := NewCubeCobraLoader(newMemStorage(), nil, nil, CubeLoaderWithBaseURL(srv.URL), CubeLoaderWithoutDedupe())
+
+	err := loader.Load(context.Background(), "no-such-cube")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("load unknown cube: got %v, want ErrNotFound", err)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Details != "cube not found" {
+		t.Errorf("status error = %+v, want details from the error body", statusErr)
+	}
+	// Not found is not retried
+	if got := srv.Requests(); got != 1 {
+		t.Errorf("server saw %d requests, want 1", got)
+	}
+}
+
+func TestScryfallOutage(t *testing.T) {
+	srv := newTestServer(t)
+	loader := newFastScryfallLoader(srv, 10*time.Millisecond)
+	srv.FailNext(defaultMaxRetries+1, http.StatusServiceUnavailable, "")
+
+	_, err := loader.LoadCards(context.Background(), []string{boltID})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("load cards: got %v, want ErrUnavailable", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "scryfall") || !strings.Contains(msg, "503 Service Unavailable") {
+		t.Errorf("error %q doesn't say Scryfall is unavailable", msg)
+	}
+	if got := srv.Requests(); got != defaultMaxRetries+1 {
+		t.Errorf("server saw %d requests, want %d", got, defaultMaxRetries+1)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	_, refused := http.Get(closed.URL)
+	_, badScheme := http.Get("ftp://example.com")
+
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rate limited", &StatusError{StatusCode: http.StatusTooManyRequests}, true},
+		{"unavailable", &StatusError{StatusCode: http.StatusBadGateway}, true},
+		{"bad request", &StatusError{StatusCode: http.StatusBadRequest}, false},
+		{"truncated body", fmt.Errorf(`read body: %w`, io.ErrUnexpectedEOF), true},
+		{"connection refused", refused, true},
+		{"unsupported scheme", badScheme, false},
+		{"unmarshal", errors.New("unmarshal response"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(ctx, tt.err); got != tt.want {
+				t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
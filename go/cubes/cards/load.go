@@ -1,28 +1,64 @@
 package cards
 
 import (
-	"bytes"
 	"context"
 	_ "embed"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/mgdunn2/cube-datahub/cubes"
-	"io"
-	"log"
+	"github.com/mgdunn2/cube-datahub/cubes/images"
 	"net/http"
 	"slices"
+	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	DefaultScryfallBaseURL  = "https://api.scryfall.com"
+	DefaultCubeCobraBaseURL = "https://cubecobra.com"
+)
+
 type CardLoader interface {
-	LoadCards(ctx context.Context, ids []string) error
+	LoadCards(ctx context.Context, ids []string) (LoadReport, error)
+}
+
+// CardFetcher fetches cards by ID without storing them
+type CardFetcher interface {
+	FetchCards(ctx context.Context, ids []string) ([]cubes.Card, LoadReport, error)
+}
+
+// LoadReport accounts for every ID passed to LoadCards. Retried lists IDs whose request had to be retried; they are
+// also in one of the other lists.
+type LoadReport struct {
+	Found            []string
+	Missing          []string
+	ConversionFailed []string
+	Retried          []string
 }
 
+// Complete reports whether every requested card was loaded
+func (r LoadReport) Complete() bool {
+	return len(r.Missing) == 0 && len(r.ConversionFailed) == 0
+}
+
+func (r *LoadReport) merge(other LoadReport) {
+	r.Found = append(r.Found, other.Found...)
+	r.Missing = append(r.Missing, other.Missing...)
+	r.ConversionFailed = append(r.ConversionFailed, other.ConversionFailed...)
+	r.Retried = append(r.Retried, other.Retried...)
+}
+
+const defaultScryfallConcurrency = 4
+
 type ScryfallApiCardLoader struct {
-	client  *http.Client
-	storage cubes.Storage
+	client      *http.Client
+	api         *apiClient
+	baseURL     string
+	maxRetries  int
+	concurrency int
+	storage     cubes.Storage
 }
 
 type ScryfallApiCardLoaderOpts func(*ScryfallApiCardLoader)
@@ -33,9 +69,34 @@ func ScryfallLoaderWithClient(client http.Client) ScryfallApiCardLoaderOpts {
 	}
 }
 
+// ScryfallLoaderWithBaseURL points the loader at a Scryfall mirror or stand-in instead of api.scryfall.com
+func ScryfallLoaderWithBaseURL(baseURL string) ScryfallApiCardLoaderOpts {
+	return func(c *ScryfallApiCardLoader) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// ScryfallLoaderWithRetries sets how many times a rate limited, unavailable or failed request is retried
+func ScryfallLoaderWithRetries(maxRetries int) ScryfallApiCardLoaderOpts {
+	return func(c *ScryfallApiCardLoader) {
+		c.maxRetries = maxRetries
+	}
+}
+
+// ScryfallLoaderWithConcurrency sets how many collection batches are fetched at once. Requests are still spaced to
+// Scryfall's rate limit however many are in flight.
+func ScryfallLoaderWithConcurrency(concurrency int) ScryfallApiCardLoaderOpts {
+	return func(c *ScryfallApiCardLoader) {
+		c.concurrency = max(concurrency, 1)
+	}
+}
+
 func NewScryfallLoader(storage cubes.Storage, opts ...ScryfallApiCardLoaderOpts) *ScryfallApiCardLoader {
 	loader := &ScryfallApiCardLoader{
-		storage: storage,
+		storage:     storage,
+		baseURL:     DefaultScryfallBaseURL,
+		maxRetries:  defaultMaxRetries,
+		concurrency: defaultScryfallConcurrency,
 	}
 	for _, opt := range opts {
 		opt(loader)
@@ -45,81 +106,134 @@ func NewScryfallLoader(storage cubes.Storage, opts ...ScryfallApiCardLoaderOpts)
 			Timeout: 10 * time.Second,
 		}
 	}
+	loader.api = newAPIClient(loader.client, scryfallRequestInterval, loader.maxRetries)
 	return loader
 }
 
 type CollectionRequest struct {
 	Identifiers []CardIdentifier `json:"identifiers"`
 }
+
+// CardIdentifier identifies a card to Scryfall by ID, by name, by name and set, or by set and collector number
 type CardIdentifier struct {
-	ID string `json:"id"`
+	ID              string `json:"id,omitempty"`
+	Name            string `json:"name,omitempty"`
+	Set             string `json:"set,omitempty"`
+	CollectorNumber string `json:"collector_number,omitempty"`
 }
 
 type CollectionResponse struct {
-	Cards []cubes.ScryfallCard `json:"data"`
+	Cards    []cubes.ScryfallCard `json:"data"`
+	NotFound []CardIdentifier     `json:"not_found"`
 }
 
-func (f *ScryfallApiCardLoader) LoadCards(ctx context.Context, ids []string) error {
+// LoadCards fetches the cards and upserts the ones that were found
+func (f *ScryfallApiCardLoader) LoadCards(ctx context.Context, ids []string) (LoadReport, error) {
+	cards, report, err := f.FetchCards(ctx, ids)
+	if err != nil {
+		return LoadReport{}, err
+	}
+	if err := f.storage.UpsertCards(ctx, cards); err != nil {
+		return LoadReport{}, fmt.Errorf(`upsert cards: %w`, err)
+	}
+	return report, nil
+}
+
+// FetchCards fetches the cards in batches of 75 across the loader's workers
+func (f *ScryfallApiCardLoader) FetchCards(ctx context.Context, ids []string) ([]cubes.Card, LoadReport, error) {
 	const batchSize = 75
-	var allCards []cubes.Card
+	var batches [][]string
+	for start := 0; start < len(ids); start += batchSize {
+		batches = append(batches, ids[start:min(start+batchSize, len(ids))])
+	}
 
-	var missingCards []string
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	jobs := make(chan int, len(batches))
+	for i := range batches {
+		jobs <- i
+	}
+	close(jobs)
 
-	for start := 0; start < len(ids); start += batchSize {
-		end := start + batchSize
-		if end > len(ids) {
-			end = len(ids)
-		}
+	results := make([]batchResult, len(batches))
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for range min(f.concurrency, len(batches)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					return
+				}
+				result, err := f.fetchBatch(ctx, batches[i])
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+				results[i] = result
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, LoadReport{}, firstErr
+	}
 
-		// Prepare batch
-		cardIDs := make([]CardIdentifier, end-start)
-		for i := start; i < end; i++ {
-			cardIDs[i-start] = CardIdentifier{ID: ids[i]}
-		}
-		collectionRequest := CollectionRequest{
-			Identifiers: cardIDs,
-		}
-		jsonReq, err := json.Marshal(collectionRequest)
-		if err != nil {
-			return fmt.Errorf(`marshal collection request: %w`, err)
-		}
+	var report LoadReport
+	var allCards []cubes.Card
+	for _, result := range results {
+		report.merge(result.report)
+		allCards = append(allCards, result.cards...)
+	}
+	return allCards, report, nil
+}
 
-		rsp, err := f.client.Post("https://api.scryfall.com/cards/collection", "application/json", bytes.NewBuffer(jsonReq))
-		if err != nil {
-			return fmt.Errorf(`post collection: %w`, err)
-		}
-		bodyBytes, err := io.ReadAll(rsp.Body)
-		_ = rsp.Body.Close()
+type batchResult struct {
+	cards  []cubes.Card
+	report LoadReport
+}
 
-		var response CollectionResponse
-		if err := json.Unmarshal(bodyBytes, &response); err != nil {
-			return fmt.Errorf(`unmarshal collection response: %w`, err)
-		}
+func (f *ScryfallApiCardLoader) fetchBatch(ctx context.Context, ids []string) (batchResult, error) {
+	identifiers := make([]CardIdentifier, len(ids))
+	for i, id := range ids {
+		identifiers[i] = CardIdentifier{ID: id}
+	}
+	var response CollectionResponse
+	retries, err := f.api.postJSON(ctx, f.baseURL+"/cards/collection", CollectionRequest{Identifiers: identifiers}, &response)
+	if err != nil {
+		return batchResult{}, fmt.Errorf(`post scryfall collection: %w`, err)
+	}
 
-		foundCardIDs := make(map[string]struct{}, len(response.Cards))
-		for _, scryfallCard := range response.Cards {
-			card, err := scryfallCard.ToCard()
-			if err != nil {
-				log.Println(fmt.Errorf(`converting to card: %w`, err))
-			}
-			foundCardIDs[card.ID] = struct{}{}
-			allCards = append(allCards, card)
+	scryfallCards := make(map[string]cubes.ScryfallCard, len(response.Cards))
+	for _, scryfallCard := range response.Cards {
+		scryfallCards[scryfallCard.ID] = scryfallCard
+	}
+	var result batchResult
+	for _, id := range ids {
+		scryfallCard, ok := scryfallCards[id]
+		if !ok {
+			result.report.Missing = append(result.report.Missing, id)
+			continue
 		}
-		for _, card := range cardIDs {
-			if _, ok := foundCardIDs[card.ID]; !ok {
-				missingCards = append(missingCards, card.ID)
-			}
+		card, err := scryfallCard.ToCard()
+		if err != nil {
+			result.report.ConversionFailed = append(result.report.ConversionFailed, id)
+			continue
 		}
+		result.report.Found = append(result.report.Found, id)
+		result.cards = append(result.cards, card)
 	}
-	if len(missingCards) > 0 {
-		fmt.Printf(`Missing cards: %v\n`, missingCards)
+	if retries > 0 {
+		result.report.Retried = slices.Clone(ids)
 	}
-
-	// Final upsert
-	if err := f.storage.UpsertCards(ctx, allCards); err != nil {
-		return fmt.Errorf(`upsert cards: %w`, err)
-	}
-	return nil
+	return result, nil
 }
 
 type CubeLoader interface {
@@ -128,9 +242,39 @@ type CubeLoader interface {
 
 type CubeCobraLoader struct {
 	client           *http.Client
+	api              *apiClient
+	baseURL          string
+	maxRetries       int
 	storage          cubes.Storage
 	cardLoader       CardLoader
 	customCardReader CustomCardReader
+	partialPolicy    PartialLoadPolicy
+	imageSyncer      *images.Syncer
+	deduper          *CustomCardDeduper
+	noDedupe         bool
+	customReadCost   float64
+	// customMu serializes custom card handling across concurrent loads, and across loaders sharing it
+	customMu *sync.Mutex
+}
+
+// PartialLoadPolicy decides whether a cube can be stored when its card load was incomplete. Returning an error stops
+// the load before a new version is created.
+type PartialLoadPolicy func(report LoadReport) error
+
+// ErrPartialLoad is returned by RejectPartialLoads
+var ErrPartialLoad = errors.New("partial card load")
+
+// RejectPartialLoads refuses to store a cube unless every card loaded
+func RejectPartialLoads(report LoadReport) error {
+	if report.Complete() {
+		return nil
+	}
+	return fmt.Errorf(`%w: missing %v, conversion failed %v`, ErrPartialLoad, report.Missing, report.ConversionFailed)
+}
+
+// AllowPartialLoads stores the cube with whatever cards did load
+func AllowPartialLoads(LoadReport) error {
+	return nil
 }
 
 type CubeCobraLoaderOpts func(*CubeCobraLoader)
@@ -141,11 +285,69 @@ func CubeLoaderWithClient(client http.Client) CubeCobraLoaderOpts {
 	}
 }
 
+// CubeLoaderWithBaseURL points the loader at a CubeCobra mirror or stand-in instead of cubecobra.com
+func CubeLoaderWithBaseURL(baseURL string) CubeCobraLoaderOpts {
+	return func(c *CubeCobraLoader) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// CubeLoaderWithRetries sets how many times a rate limited, unavailable or failed request is retried
+func CubeLoaderWithRetries(maxRetries int) CubeCobraLoaderOpts {
+	return func(c *CubeCobraLoader) {
+		c.maxRetries = maxRetries
+	}
+}
+
+// CubeLoaderWithPartialLoadPolicy sets what happens when some of a cube's cards fail to load. The default is
+// RejectPartialLoads.
+func CubeLoaderWithPartialLoadPolicy(policy PartialLoadPolicy) CubeCobraLoaderOpts {
+	return func(c *CubeCobraLoader) {
+		c.partialPolicy = policy
+	}
+}
+
+// CubeLoaderWithImageSyncer stores the image of each newly read custom card as soon as it is read, since the hosts
+// custom images live on are the ones that disappear
+func CubeLoaderWithImageSyncer(syncer *images.Syncer) CubeCobraLoaderOpts {
+	return func(c *CubeCobraLoader) {
+		c.imageSyncer = syncer
+	}
+}
+
+// CubeLoaderWithDeduper sets how new custom card images are matched against ones already read. By default a
+// CustomCardDeduper with DefaultCustomCardDistance is used.
+func CubeLoaderWithDeduper(deduper *CustomCardDeduper) CubeCobraLoaderOpts {
+	return func(c *CubeCobraLoader) {
+		c.deduper = deduper
+	}
+}
+
+// CubeLoaderWithoutDedupe reads every custom card image with an unseen URL, even if it matches one already read
+func CubeLoaderWithoutDedupe() CubeCobraLoaderOpts {
+	return func(c *CubeCobraLoader) {
+		c.noDedupe = true
+	}
+}
+
+// CubeLoaderWithSharedCustomCards resolves custom cards under the same lock as other, so that a loader for another
+// source, such as the CSV loader's, doesn't read an image other is already reading and store it twice
+func CubeLoaderWithSharedCustomCards(other *CubeCobraLoader) CubeCobraLoaderOpts {
+	return func(c *CubeCobraLoader) {
+		c.customMu = other.customMu
+	}
+}
+
 func NewCubeCobraLoader(storage cubes.Storage, cardLoader CardLoader, customCardReader CustomCardReader, opts ...CubeCobraLoaderOpts) *CubeCobraLoader {
 	loader := &CubeCobraLoader{
+		customMu:         &sync.Mutex{},
+		baseURL:          DefaultCubeCobraBaseURL,
+		maxRetries:       defaultMaxRetries,
 		storage:          storage,
 		cardLoader:       cardLoader,
 		customCardReader: customCardReader,
+		partialPolicy:    RejectPartialLoads,
+		customReadCost:   DefaultCustomReadCost,
 	}
 	for _, opt := range opts {
 		opt(loader)
@@ -155,69 +357,90 @@ func NewCubeCobraLoader(storage cubes.Storage, cardLoader CardLoader, customCard
 			Timeout: 10 * time.Second,
 		}
 	}
+	loader.api = newAPIClient(loader.client, 0, loader.maxRetries)
+	if loader.noDedupe {
+		loader.deduper = nil
+	} else if loader.deduper == nil {
+		loader.deduper = NewCustomCardDeduper(storage, DeduperWithClient(*loader.client))
+	}
 	return loader
 }
 
 func (c *CubeCobraLoader) Load(ctx context.Context, cubeID string) error {
-	url := fmt.Sprintf(`https://cubecobra.com/cube/api/cubeJSON/%s`, cubeID)
-
-	rsp, err := c.client.Get(url)
-	if err != nil {
-		return fmt.Errorf(`get cube cobra: %w`, err)
-	}
-	bodyBytes, err := io.ReadAll(rsp.Body)
+	cubeCobraCube, err := c.fetchCube(ctx, cubeID)
 	if err != nil {
-		return fmt.Errorf(`read bytes: %w`, err)
+		return err
 	}
+	return c.loadCube(ctx, cubeID, cubeCobraCube)
+}
+
+func (c *CubeCobraLoader) fetchCube(ctx context.Context, cubeID string) (cubes.CubeCobraCube, error) {
+	url := fmt.Sprintf(`%s/cube/api/cubeJSON/%s`, c.baseURL, cubeID)
+
 	var cubeCobraCube cubes.CubeCobraCube
-	if err := json.Unmarshal(bodyBytes, &cubeCobraCube); err != nil {
-		return fmt.Errorf(`unmarshal: %w`, err)
+	if _, err := c.api.getJSON(ctx, url, &cubeCobraCube); err != nil {
+		return cubes.CubeCobraCube{}, fmt.Errorf(`get cube cobra: %w`, err)
 	}
+	return cubeCobraCube, nil
+}
 
-	customMappings, err := c.storage.GetAllCustomCardIDs(ctx)
+// loadCube resolves the cards on every board of a CubeCobra cube and stores a new version if anything changed. Cards
+// are fetched with the card loader unless it is nil, in which case they must already be stored.
+func (c *CubeCobraLoader) loadCube(ctx context.Context, cubeID string, cubeCobraCube cubes.CubeCobraCube) error {
+	resolved, err := c.resolveBoards(ctx, cubeCobraCube)
 	if err != nil {
-		return fmt.Errorf(`getting custom mappings: %w`, err)
+		return err
 	}
-
-	var cardIDs []string
-	var customCardIDs []string
-	for _, card := range cubeCobraCube.Cards.MainBoard {
-		if slices.Index(card.Tags, "custom") != -1 {
-			fmt.Printf("Loading custom card: %v\n", card)
-			customCard, err := c.handleCustom(ctx, card.ImageURL, customMappings)
-			if err != nil {
-				return fmt.Errorf(`handle custom: %w`, err)
-			}
-			customCardIDs = append(customCardIDs, customCard.ID)
-		} else {
-			cardIDs = append(cardIDs, card.ID)
+	if err := c.warnUnverified(ctx, resolved.customCardIDs); err != nil {
+		return fmt.Errorf(`warn unverified: %w`, err)
+	}
+	if c.cardLoader != nil {
+		report, err := c.cardLoader.LoadCards(ctx, resolved.cardIDs)
+		if err != nil {
+			return fmt.Errorf(`load cards: %w`, err)
+		}
+		if err := c.partialPolicy(report); err != nil {
+			return fmt.Errorf(`load cards: %w`, err)
 		}
 	}
-	err = c.cardLoader.LoadCards(ctx, cardIDs)
+
+	newCube := cubes.Cube{
+		ID:   cubeID,
+		Name: cubeCobraCube.Name,
+		Date: time.Now(),
+	}
+	// GetByIDs returns each card once, so copies are expanded from the board's own list of IDs
+	var allIDs []string
+	for _, ids := range resolved.boardCardIDs {
+		allIDs = append(allIDs, ids...)
+	}
+	stored, err := c.storage.GetByIDs(ctx, allIDs)
 	if err != nil {
-		return fmt.Errorf(`load cards: %w`, err)
+		return fmt.Errorf(`get cube cards: %w`, err)
+	}
+	cardsByID := make(map[string]cubes.Card, len(stored))
+	for _, card := range stored {
+		cardsByID[card.ID] = card
+	}
+	for board, ids := range resolved.boardCardIDs {
+		var cards []cubes.Card
+		for _, id := range ids {
+			if card, ok := cardsByID[id]; ok {
+				cards = append(cards, card)
+			}
+		}
+		setBoard(&newCube, board, cards, resolved.tags[board])
 	}
-	var allCardIDs []string
-	allCardIDs = append(cardIDs, customCardIDs...)
-	cards, err := c.storage.GetByIDs(ctx, allCardIDs)
 
-	currentCube, err := c.storage.GetCube(ctx, cubeID, nil)
+	currentCube, err := c.storage.GetCube(ctx, cubeID, nil, cubes.AllBoards)
 	if err != nil {
 		return fmt.Errorf(`get cube: %w`, err)
 	}
-	if currentCube != nil && !hasChanges(cards, currentCube.Cards) {
+	if currentCube != nil && !cubes.DiffCubes(*currentCube, newCube).HasChanges() {
 		return nil
 	}
-	var version int
 	if currentCube != nil {
-		version = currentCube.VersionNumber + 1
-	}
-	newCube := cubes.Cube{
-		ID:            cubeID,
-		Name:          cubeCobraCube.Name,
-		VersionNumber: version,
-		Cards:         cards,
-		Date:          time.Now(),
+		newCube.VersionNumber = currentCube.VersionNumber + 1
 	}
 	err = c.storage.UpdateCube(ctx, newCube)
 	if err != nil {
@@ -226,6 +449,82 @@ func (c *CubeCobraLoader) Load(ctx context.Context, cubeID string) error {
 	return nil
 }
 
+// resolvedBoards holds the card IDs on each board of a CubeCobra cube once custom cards have their own IDs
+type resolvedBoards struct {
+	cardIDs       []string
+	customCardIDs []string
+	boardCardIDs  map[string][]string
+	// tags maps board name to card ID to the tags the card has on that board
+	tags map[string]map[string][]string
+}
+
+// resolveBoards maps every card on the cube's boards to the ID it is stored under, reading custom cards that haven't
+// been seen before. Custom cards are resolved under customMu, so cubes loading at once, as the sync service does, see
+// each other's new custom cards rather than each paying to read the same image.
+func (c *CubeCobraLoader) resolveBoards(ctx context.Context, cubeCobraCube cubes.CubeCobraCube) (resolvedBoards, error) {
+	c.customMu.Lock()
+	defer c.customMu.Unlock()
+	customMappings, err := c.storage.GetAllCustomCardIDs(ctx)
+	if err != nil {
+		return resolvedBoards{}, fmt.Errorf(`getting custom mappings: %w`, err)
+	}
+
+	resolved := resolvedBoards{
+		boardCardIDs: make(map[string][]string),
+		tags:         make(map[string]map[string][]string),
+	}
+	for _, board := range sortedBoardNames(cubeCobraCube.Cards.Boards) {
+		for _, card := range cubeCobraCube.Cards.Boards[board] {
+			cardID := card.ID
+			if slices.Index(card.Tags, "custom") != -1 {
+				fmt.Printf("Loading custom card: %v\n", card)
+				customCard, err := c.handleCustom(ctx, card.ImageURL, customMappings)
+				if err != nil {
+					return resolvedBoards{}, fmt.Errorf(`handle custom: %w`, err)
+				}
+				cardID = customCard.ID
+				customMappings[card.ImageURL] = cardID
+				resolved.customCardIDs = append(resolved.customCardIDs, cardID)
+			} else {
+				resolved.cardIDs = append(resolved.cardIDs, cardID)
+			}
+			resolved.boardCardIDs[board] = append(resolved.boardCardIDs[board], cardID)
+			addTags(resolved.tags, board, cardID, card.Tags)
+		}
+	}
+	return resolved, nil
+}
+
+// addTags adds a card's tags on a board to tags, which maps board name to card ID to tags
+func addTags(tags map[string]map[string][]string, board, cardID string, cardTags []string) {
+	if len(cardTags) == 0 {
+		return
+	}
+	if tags[board] == nil {
+		tags[board] = make(map[string][]string)
+	}
+	tags[board][cardID] = append(tags[board][cardID], cardTags...)
+}
+
+// setBoard puts cards and their tags on the named board of a cube
+func setBoard(cube *cubes.Cube, board string, cards []cubes.Card, tags map[string][]string) {
+	if board == cubes.MainBoard {
+		cube.Cards = cards
+		cube.Tags = tags
+		return
+	}
+	if cube.Boards == nil {
+		cube.Boards = make(map[string][]cubes.Card)
+	}
+	cube.Boards[board] = cards
+	if len(tags) > 0 {
+		if cube.BoardTags == nil {
+			cube.BoardTags = make(map[string]map[string][]string)
+		}
+		cube.BoardTags[board] = tags
+	}
+}
+
 func (c *CubeCobraLoader) handleCustom(ctx context.Context, imageURL string, customMappings map[string]string) (cubes.Card, error) {
 	if cardID, ok := customMappings[imageURL]; ok {
 		cards, err := c.storage.GetByIDs(ctx, []string{cardID})
@@ -237,6 +536,20 @@ func (c *CubeCobraLoader) handleCustom(ctx context.Context, imageURL string, cus
 		}
 		return cards[0], nil
 	}
+
+	var phash *uint64
+	if c.deduper != nil {
+		var existing *cubes.CustomCard
+		var err error
+		existing, phash, err = c.findDuplicate(ctx, imageURL)
+		if err != nil {
+			return cubes.Card{}, err
+		}
+		if existing != nil {
+			return existing.Card, nil
+		}
+	}
+
 	card, err := c.customCardReader.ReadCard(ctx, imageURL)
 	if err != nil {
 		return cubes.Card{}, fmt.Errorf(`read card: %w`, err)
@@ -251,35 +564,93 @@ func (c *CubeCobraLoader) handleCustom(ctx context.Context, imageURL string, cus
 	if err != nil {
 		return cubes.Card{}, fmt.Errorf(`add custom card: %w`, err)
 	}
+	if phash != nil {
+		if err := c.storage.SetCustomCardPHash(ctx, imageURL, *phash); err != nil {
+			return cubes.Card{}, fmt.Errorf(`set phash: %w`, err)
+		}
+	}
 	err = c.storage.UpsertCards(ctx, []cubes.Card{card})
 	if err != nil {
 		return cubes.Card{}, fmt.Errorf(`upsert card: %w`, err)
 	}
+	if c.imageSyncer != nil {
+		report, err := c.imageSyncer.Sync(ctx, []cubes.Card{card})
+		if err != nil {
+			return cubes.Card{}, fmt.Errorf(`sync image: %w`, err)
+		}
+		if syncErr, ok := report.Failed[card.ID]; ok {
+			fmt.Printf("Warning: could not store image for %s: %v\n", card.Name, syncErr)
+		}
+	}
 
 	return card, nil
 }
 
-func hasChanges(a, b []cubes.Card) bool {
-	if len(a) != len(b) {
-		return true
+// findDuplicate hashes a new custom card image and, if it matches a custom card that was already read, maps the image
+// to that card and returns it. The hash is returned too so it can be stored with a newly read card. An image that
+// can't be hashed is only a warning; the card is read as if it were new.
+//
+// A match is only probable, so it is logged with the original card, image and distance, and the reused card is left
+// unverified until someone checks the new image with custom_cards.
+func (c *CubeCobraLoader) findDuplicate(ctx context.Context, imageURL string) (*cubes.CustomCard, *uint64, error) {
+	hash, err := c.deduper.HashImage(ctx, imageURL)
+	if err != nil {
+		fmt.Printf("Warning: could not hash %s: %v\n", imageURL, err)
+		return nil, nil, nil
+	}
+	existing, distance, err := c.deduper.FindSimilar(ctx, hash)
+	if err != nil {
+		return nil, nil, fmt.Errorf(`find similar custom card: %w`, err)
+	}
+	if existing == nil {
+		return nil, &hash, nil
+	}
+	fmt.Printf("Reusing custom card %s (%s) read from %s for %s, image distance %d; marked unverified for review\n",
+		existing.Card.Name, existing.Card.ID, existing.ImageURL, imageURL, distance)
+	if err := c.storage.AddCustomCard(ctx, imageURL, existing.Card.ID); err != nil {
+		return nil, nil, fmt.Errorf(`add custom card: %w`, err)
+	}
+	if err := c.storage.SetCustomCardPHash(ctx, imageURL, hash); err != nil {
+		return nil, nil, fmt.Errorf(`set phash: %w`, err)
+	}
+	if err := c.storage.SetCustomCardVerified(ctx, existing.Card.ID, false); err != nil {
+		return nil, nil, fmt.Errorf(`set verified: %w`, err)
+	}
+	return existing, &hash, nil
+}
+
+// warnUnverified prints any custom cards in the cube that a human has not yet checked against their image
+func (c *CubeCobraLoader) warnUnverified(ctx context.Context, customCardIDs []string) error {
+	if len(customCardIDs) == 0 {
+		return nil
 	}
-	aCards := make(map[string]struct{}, len(a))
-	for _, card := range a {
-		aCards[card.ID] = struct{}{}
+	customCards, err := c.storage.GetCustomCards(ctx)
+	if err != nil {
+		return fmt.Errorf(`get custom cards: %w`, err)
 	}
-	for _, card := range b {
-		if _, ok := aCards[card.ID]; !ok {
-			return true
+	var unverified []string
+	for _, customCard := range customCards {
+		if !customCard.Verified && slices.Contains(customCardIDs, customCard.Card.ID) {
+			unverified = append(unverified, fmt.Sprintf("%s (%s)", customCard.Card.Name, customCard.Card.ID))
 		}
 	}
-	bCards := make(map[string]struct{}, len(b))
-	for _, card := range b {
-		bCards[card.ID] = struct{}{}
+	if len(unverified) > 0 {
+		fmt.Printf("Warning: %d unverified custom cards: %v\n", len(unverified), unverified)
 	}
-	for _, card := range a {
-		if _, ok := bCards[card.ID]; !ok {
-			return true
+	return nil
+}
+
+// sortedBoardNames returns the board names with the mainboard first so its cards are handled before any candidates
+func sortedBoardNames[T any](boards map[string]T) []string {
+	names := make([]string, 0, len(boards))
+	for name := range boards {
+		if name != cubes.MainBoard {
+			names = append(names, name)
 		}
 	}
-	return false
+	slices.Sort(names)
+	if _, ok := boards[cubes.MainBoard]; ok {
+		names = append([]string{cubes.MainBoard}, names...)
+	}
+	return names
 }
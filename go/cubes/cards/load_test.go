@@ -0,0 +1,256 @@
+package cards
+
+import (
+	"context"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+	"github.com/mgdunn2/cube-datahub/cubes/cards/cardstest"
+)
+
+const customImageURL = "https://i.imgur.com/hcbSX8l.png"
+
+var fixtureCardIDs = map[string]string{
+	"77c6fa74-5543-42ac-9ead-0e890b188e99": "Lightning Bolt",
+	"1920dae4-fb92-4f19-ae4b-eb3276b8dac7": "Counterspell",
+	"11bf83bb-c95b-4b4f-9a56-ce7a1816307a": "Delver of Secrets",
+	"e2c4d7a6-5ad0-4d6e-a5ae-0a3a2b1f37b6": "Jace, the Mind Sculptor",
+	"69daba76-96e8-4bcc-ab79-2f00189ad8fb": "Tarmogoyf",
+	"3e3f0bcd-0796-494d-bf51-94b33c1671e9": "Scalding Tarn",
+}
+
+func newTestServer(t *testing.T) *cardstest.Server {
+	t.Helper()
+	srv, err := cardstest.NewServer()
+	if err != nil {
+		t.Fatalf("start server: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestScryfallLoadCards(t *testing.T) {
+	srv := newTestServer(t)
+	storage := newMemStorage()
+	loader := NewScryfallLoader(storage, ScryfallLoaderWithBaseURL(srv.URL))
+
+	const unknownID = "00000000-0000-0000-0000-000000000000"
+	ids := []string{unknownID}
+	for id := range fixtureCardIDs {
+		ids = append(ids, id)
+	}
+	report, err := loader.LoadCards(context.Background(), ids)
+	if err != nil {
+		t.Fatalf("load cards: %v", err)
+	}
+	if len(report.Found) != len(fixtureCardIDs) {
+		t.Errorf("found %d cards, want %d: %v", len(report.Found), len(fixtureCardIDs), report.Found)
+	}
+	if !slices.Equal(report.Missing, []string{unknownID}) {
+		t.Errorf("missing = %v, want [%s]", report.Missing, unknownID)
+	}
+	if report.Complete() {
+		t.Error("report with a missing card is complete")
+	}
+
+	for id, name := range fixtureCardIDs {
+		card, ok := storage.cards[id]
+		if !ok {
+			t.Errorf("%s (%s) not stored", name, id)
+			continue
+		}
+		if card.Name != name {
+			t.Errorf("stored %s as %q, want %q", id, card.Name, name)
+		}
+	}
+	if _, ok := storage.cards[unknownID]; ok {
+		t.Error("unknown card was stored")
+	}
+}
+
+func TestCubeCobraLoad(t *testing.T) {
+	srv := newTestServer(t)
+	storage := newMemStorage()
+	reader := &staticCardReader{card: cubes.Card{Name: "Fixture Custom Card", Type: "Creature"}}
+	loader := NewCubeCobraLoader(
+		storage,
+		NewScryfallLoader(storage, ScryfallLoaderWithBaseURL(srv.URL)),
+		reader,
+		CubeLoaderWithBaseURL(srv.URL),
+		CubeLoaderWithoutDedupe(),
+	)
+
+	ctx := context.Background()
+	if err := loader.Load(ctx, cardstest.FixtureCubeID); err != nil {
+		t.Fatalf("load cube: %v", err)
+	}
+	cube, err := storage.GetCube(ctx, cardstest.FixtureCubeID, nil, cubes.AllBoards)
+	if err != nil || cube == nil {
+		t.Fatalf("get cube: %v, %v", cube, err)
+	}
+	if cube.Name != "Fixture Cube" {
+		t.Errorf("name = %q, want Fixture Cube", cube.Name)
+	}
+	if cube.VersionNumber != 0 {
+		t.Errorf("version = %d, want 0", cube.VersionNumber)
+	}
+
+	var mainboard []string
+	var customID string
+	for _, card := range cube.Cards {
+		mainboard = append(mainboard, card.Name)
+		if card.Name == reader.card.Name {
+			customID = card.ID
+		}
+	}
+	slices.Sort(mainboard)
+	want := []string{
+		"Counterspell",
+		"Delver of Secrets",
+		"Fixture Custom Card",
+		"Jace, the Mind Sculptor",
+		"Lightning Bolt",
+		"Scalding Tarn",
+	}
+	if !slices.Equal(mainboard, want) {
+		t.Errorf("mainboard = %v, want %v", mainboard, want)
+	}
+	maybeboard := cube.Board(cubes.MaybeBoard)
+	if len(maybeboard) != 1 || maybeboard[0].Name != "Tarmogoyf" {
+		t.Errorf("maybeboard = %v, want [Tarmogoyf]", maybeboard)
+	}
+
+	if customID == "" {
+		t.Fatal("custom card not on the mainboard")
+	}
+	if got := storage.customCards[customImageURL]; got != customID {
+		t.Errorf("custom image mapped to %q, want %q", got, customID)
+	}
+	if !slices.Equal(cube.Tags["77c6fa74-5543-42ac-9ead-0e890b188e99"], []string{"removal", "burn"}) {
+		t.Errorf("Lightning Bolt tags = %v", cube.Tags["77c6fa74-5543-42ac-9ead-0e890b188e99"])
+	}
+	if !slices.Equal(cube.Tags[customID], []string{"custom"}) {
+		t.Errorf("custom card tags = %v", cube.Tags[customID])
+	}
+
+	// Loading the same cube again reuses the custom card and stores no new version
+	if err := loader.Load(ctx, cardstest.FixtureCubeID); err != nil {
+		t.Fatalf("reload cube: %v", err)
+	}
+	if reader.reads != 1 {
+		t.Errorf("custom card read %d times, want 1", reader.reads)
+	}
+	if versions := len(storage.cubes[cardstest.FixtureCubeID]); versions != 1 {
+		t.Errorf("stored %d versions, want 1", versions)
+	}
+}
+
+func TestCubeCobraLoadConcurrentCustomCards(t *testing.T) {
+	srv := newTestServer(t)
+	raw, err := os.ReadFile("cardstest/fixtures/cubecobra_fixture-cube.json")
+	if err != nil {
+		t.Fatalf("read cube fixture: %v", err)
+	}
+	const otherCubeID = "other-fixture-cube"
+	srv.AddCube(otherCubeID, raw)
+
+	storage := newMemStorage()
+	reader := &staticCardReader{card: cubes.Card{Name: "Fixture Custom Card", Type: "Creature"}}
+	loader := NewCubeCobraLoader(
+		storage,
+		NewScryfallLoader(storage, ScryfallLoaderWithBaseURL(srv.URL)),
+		reader,
+		CubeLoaderWithBaseURL(srv.URL),
+		CubeLoaderWithoutDedupe(),
+	)
+
+	// Both cubes share a custom card image, which should only be read once however the loads interleave
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, cubeID := range []string{cardstest.FixtureCubeID, otherCubeID} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = loader.Load(context.Background(), cubeID)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("load cube: %v", err)
+		}
+	}
+	if reader.reads != 1 {
+		t.Errorf("custom card read %d times, want 1", reader.reads)
+	}
+}
+
+func TestCubeCobraLoadTags(t *testing.T) {
+	ctx := context.Background()
+	storage := newMemStorage()
+	bolt := cubes.Card{ID: "77c6fa74-5543-42ac-9ead-0e890b188e99", Name: "Lightning Bolt", Type: "Instant"}
+	goyf := cubes.Card{ID: "69daba76-96e8-4bcc-ab79-2f00189ad8fb", Name: "Tarmogoyf", Type: "Creature"}
+	storage.UpsertCards(ctx, []cubes.Card{bolt, goyf})
+	loader := NewCubeCobraLoader(storage, nil, &staticCardReader{})
+
+	load := func(mainTags, maybeTags []string) cubes.Cube {
+		t.Helper()
+		cube := cubes.CubeCobraCube{
+			ID:   "tag-cube",
+			Name: "Tag Cube",
+			Cards: cubes.CubeCobraCards{Boards: map[string][]cubes.CubeCobraCard{
+				cubes.MainBoard:  {{ID: bolt.ID, Tags: mainTags}, {ID: goyf.ID}},
+				cubes.MaybeBoard: {{ID: bolt.ID, Tags: maybeTags}},
+			}},
+		}
+		if err := loader.loadCube(ctx, cube.ID, cube); err != nil {
+			t.Fatalf("load cube: %v", err)
+		}
+		versions := storage.cubes[cube.ID]
+		return versions[len(versions)-1]
+	}
+
+	// A card's tags on the maybeboard stay off its mainboard copy
+	cube := load([]string{"burn"}, []string{"cut"})
+	if got := cube.Tags[bolt.ID]; !slices.Equal(got, []string{"burn"}) {
+		t.Errorf("mainboard tags = %v, want [burn]", got)
+	}
+	if got := cube.TagsOn(cubes.MaybeBoard)[bolt.ID]; !slices.Equal(got, []string{"cut"}) {
+		t.Errorf("maybeboard tags = %v, want [cut]", got)
+	}
+	if got := cube.CardsWithTag("cut"); len(got) != 0 {
+		t.Errorf("mainboard cards tagged cut = %v, want none", got)
+	}
+
+	// Changing only tags stores a new version, whichever board the tags are on
+	tests := []struct {
+		name      string
+		mainTags  []string
+		maybeTags []string
+		board     string
+		added     []string
+	}{
+		{"mainboard", []string{"burn", "removal"}, []string{"cut"}, "", []string{"removal"}},
+		{"maybeboard", []string{"burn", "removal"}, []string{"cut", "red"}, cubes.MaybeBoard, []string{"red"}},
+	}
+	for i, tt := range tests {
+		cube := load(tt.mainTags, tt.maybeTags)
+		if cube.VersionNumber != i+1 {
+			t.Fatalf("%s: latest version = %d, want %d", tt.name, cube.VersionNumber, i+1)
+		}
+		previous := storage.cubes[cube.ID][i]
+		changes := cubes.DiffCubes(previous, cube).TagChanges
+		if len(changes) != 1 || changes[0].Card.ID != bolt.ID || changes[0].Board != tt.board || !slices.Equal(changes[0].Added, tt.added) {
+			t.Errorf("%s: tag changes = %+v, want Lightning Bolt gaining %v", tt.name, changes, tt.added)
+		}
+	}
+
+	// Nothing changed, so nothing is stored
+	load([]string{"burn", "removal"}, []string{"cut", "red"})
+	if versions := len(storage.cubes["tag-cube"]); versions != len(tests)+1 {
+		t.Errorf("stored %d versions, want %d", versions, len(tests)+1)
+	}
+}
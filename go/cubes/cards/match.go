@@ -0,0 +1,150 @@
+package cards
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+// maxSuggestions is how many near names are offered for a name that can't be matched
+const maxSuggestions = 3
+
+// nameFolds spells out letters that don't decompose into an ASCII letter plus accent
+var nameFolds = strings.NewReplacer("æ", "ae", "Æ", "ae", "œ", "oe", "ß", "ss")
+
+// accentFolds maps accented letters seen in card names to their base letter
+var accentFolds = map[rune]rune{
+	'á': 'a', 'à': 'a', 'â': 'a', 'ä': 'a', 'ã': 'a', 'å': 'a',
+	'é': 'e', 'è': 'e', 'ê': 'e', 'ë': 'e',
+	'í': 'i', 'ì': 'i', 'î': 'i', 'ï': 'i',
+	'ó': 'o', 'ò': 'o', 'ô': 'o', 'ö': 'o', 'õ': 'o',
+	'ú': 'u', 'ù': 'u', 'û': 'u', 'ü': 'u',
+	'ñ': 'n', 'ç': 'c',
+}
+
+// normalizeName reduces a card name to lower case letters, digits and single spaces so that case, accents,
+// punctuation and spacing differences don't stop a match.
+func normalizeName(name string) string {
+	name = nameFolds.Replace(strings.ToLower(name))
+	var sb strings.Builder
+	space := false
+	for _, r := range name {
+		if folded, ok := accentFolds[r]; ok {
+			r = folded
+		}
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && sb.Len() > 0 {
+				sb.WriteByte(' ')
+			}
+			space = false
+			sb.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-' || r == '_' || r == '/':
+			space = true
+		}
+		// Anything else, such as apostrophes and commas, is dropped without splitting the word
+	}
+	return sb.String()
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	curr := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		curr[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(br)]
+}
+
+// cardMatcher resolves card names typed by people against a fixed pool of cards, such as a cube version
+type cardMatcher struct {
+	byName   map[string][]cubes.Card
+	byMTGOID map[int]cubes.Card
+	names    []string
+}
+
+func newCardMatcher(pool []cubes.Card) *cardMatcher {
+	m := &cardMatcher{byName: make(map[string][]cubes.Card), byMTGOID: make(map[int]cubes.Card)}
+	for _, card := range pool {
+		if card.MTGOID != 0 {
+			m.byMTGOID[card.MTGOID] = card
+		}
+		// Split and double faced cards match on their full name or their front face
+		keys := []string{normalizeName(card.Name)}
+		if front := normalizeName(frontFace(card.Name)); front != keys[0] {
+			keys = append(keys, front)
+		}
+		for _, key := range keys {
+			if _, ok := m.byName[key]; !ok {
+				m.names = append(m.names, key)
+			}
+			m.byName[key] = append(m.byName[key], card)
+		}
+	}
+	slices.Sort(m.names)
+	return m
+}
+
+// exact returns the card whose normalised name is the same as name's, preferring the given set
+func (m *cardMatcher) exact(name, set string) (cubes.Card, bool) {
+	printings, ok := m.byName[normalizeName(name)]
+	if !ok {
+		return cubes.Card{}, false
+	}
+	card, _ := pickPrinting(printings, set, "")
+	return card, true
+}
+
+// match returns the card for name, preferring the given set when the pool has several printings. A name that isn't
+// an exact normalised match still matches if exactly one pool name is within a small edit distance of it. Otherwise
+// the closest names are returned as suggestions.
+func (m *cardMatcher) match(name, set string) (cubes.Card, []string, bool) {
+	if card, ok := m.exact(name, set); ok {
+		return card, nil, true
+	}
+	key := normalizeName(name)
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+	candidates := make([]candidate, 0, len(m.names))
+	for _, poolName := range m.names {
+		candidates = append(candidates, candidate{poolName, levenshtein(key, poolName)})
+	}
+	slices.SortStableFunc(candidates, func(a, b candidate) int { return a.distance - b.distance })
+
+	// Allow roughly one typo per five letters
+	threshold := max(1, len([]rune(key))/5)
+	if len(candidates) > 0 && candidates[0].distance <= threshold &&
+		(len(candidates) == 1 || candidates[1].distance > candidates[0].distance) {
+		card, _ := pickPrinting(m.byName[candidates[0].name], set, "")
+		return card, nil, true
+	}
+
+	var suggestions []string
+	for _, c := range candidates {
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+		if name := m.byName[c.name][0].Name; !slices.Contains(suggestions, name) {
+			suggestions = append(suggestions, name)
+		}
+	}
+	return cubes.Card{}, suggestions, false
+}
@@ -0,0 +1,191 @@
+package cards
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+// MTGJSONCardLoader loads cards from an MTGJSON AllPrintings file on disk. Its records carry Scryfall IDs so cubes
+// loaded from CubeCobra still resolve. AtomicCards is rejected: it has one record per card rather than per printing, so
+// it has no Scryfall IDs, sets or release dates to store. The file is streamed one set at a time.
+type MTGJSONCardLoader struct {
+	storage   cubes.Storage
+	path      string
+	batchSize int
+}
+
+type MTGJSONCardLoaderOpts func(*MTGJSONCardLoader)
+
+// MTGJSONLoaderWithBatchSize sets how many cards are upserted at a time
+func MTGJSONLoaderWithBatchSize(batchSize int) MTGJSONCardLoaderOpts {
+	return func(m *MTGJSONCardLoader) {
+		m.batchSize = batchSize
+	}
+}
+
+func NewMTGJSONLoader(storage cubes.Storage, path string, opts ...MTGJSONCardLoaderOpts) *MTGJSONCardLoader {
+	loader := &MTGJSONCardLoader{
+		storage:   storage,
+		path:      path,
+		batchSize: defaultBulkBatchSize,
+	}
+	for _, opt := range opts {
+		opt(loader)
+	}
+	return loader
+}
+
+// LoadCards loads only the cards with the given Scryfall IDs
+func (m *MTGJSONCardLoader) LoadCards(ctx context.Context, ids []string) (LoadReport, error) {
+	batch := newCardBatcher(m.storage, m.batchSize)
+	loaded, err := m.load(ctx, idFilter(ids), batch.add)
+	if err != nil {
+		return LoadReport{}, err
+	}
+	if err := batch.flush(ctx); err != nil {
+		return LoadReport{}, err
+	}
+	return loaded.report(ids), nil
+}
+
+// FetchCards reads the cards with the given IDs from the file without storing them
+func (m *MTGJSONCardLoader) FetchCards(ctx context.Context, ids []string) ([]cubes.Card, LoadReport, error) {
+	var cards []cubes.Card
+	loaded, err := m.load(ctx, idFilter(ids), collectCards(&cards))
+	if err != nil {
+		return nil, LoadReport{}, err
+	}
+	return cards, loaded.report(ids), nil
+}
+
+// FetchCardsByName reads every printing in the file of the named cards, matched ignoring case and by front face,
+// without storing them
+func (m *MTGJSONCardLoader) FetchCardsByName(ctx context.Context, names []string) ([]cubes.Card, error) {
+	var cards []cubes.Card
+	if _, err := m.load(ctx, nameFilter(names), collectCards(&cards)); err != nil {
+		return nil, err
+	}
+	return cards, nil
+}
+
+// LoadAll loads every card in the file. Cards without a Scryfall ID can't be stored and are skipped.
+func (m *MTGJSONCardLoader) LoadAll(ctx context.Context) (LoadReport, error) {
+	batch := newCardBatcher(m.storage, m.batchSize)
+	loaded, err := m.load(ctx, nil, batch.add)
+	if err != nil {
+		return LoadReport{}, err
+	}
+	if err := batch.flush(ctx); err != nil {
+		return LoadReport{}, err
+	}
+	return loaded.report(nil), nil
+}
+
+// mtgjsonSet is the part of an AllPrintings set we need
+type mtgjsonSet struct {
+	ReleaseDate string              `json:"releaseDate"`
+	Cards       []cubes.MTGJSONCard `json:"cards"`
+}
+
+func (m *MTGJSONCardLoader) load(ctx context.Context, filter cardFilter, addCard func(context.Context, cubes.Card) error) (*loadedIDs, error) {
+	f, err := os.Open(m.path)
+	if err != nil {
+		return nil, fmt.Errorf(`open mtgjson file: %w`, err)
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReaderSize(f, 1<<20))
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+
+	loaded := newLoadedIDs()
+	add := func(mtgjsonCard cubes.MTGJSONCard, releaseDate string) error {
+		// Later faces of multi-faced cards repeat the front face's IDs
+		if mtgjsonCard.Side != "" && mtgjsonCard.Side != "a" {
+			return nil
+		}
+		id := mtgjsonCard.CardID()
+		if id == "" {
+			return nil
+		}
+		if filter != nil && !filter(id, mtgjsonCard.Name) {
+			return nil
+		}
+		if loaded.seen(id) {
+			return nil
+		}
+		card, err := mtgjsonCard.ToCard(releaseDate)
+		if err != nil {
+			loaded.failed(id)
+			return nil
+		}
+		loaded.found(id)
+		return addCard(ctx, card)
+	}
+
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf(`read key: %w`, err)
+		}
+		if key != "data" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return nil, fmt.Errorf(`skip %v: %w`, key, err)
+			}
+			continue
+		}
+		if err := expectDelim(dec, '{'); err != nil {
+			return nil, err
+		}
+		for dec.More() {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			entryKey, err := dec.Token()
+			if err != nil {
+				return nil, fmt.Errorf(`read data key: %w`, err)
+			}
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil, fmt.Errorf(`decode %v: %w`, entryKey, err)
+			}
+			if len(raw) > 0 && raw[0] == '[' {
+				// AtomicCards maps card names to faces rather than set codes to sets
+				return nil, errors.New(`mtgjson file looks like AtomicCards, which has no printings; use AllPrintings`)
+			}
+			// Set code -> set
+			var set mtgjsonSet
+			if err := json.Unmarshal(raw, &set); err != nil {
+				return nil, fmt.Errorf(`decode set %v: %w`, entryKey, err)
+			}
+			for _, card := range set.Cards {
+				if err := add(card, set.ReleaseDate); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err := expectDelim(dec, '}'); err != nil {
+			return nil, err
+		}
+	}
+	return loaded, nil
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf(`read token: %w`, err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != want {
+		return fmt.Errorf(`expected %v, found %v`, want, tok)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package cards
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+// DefaultCustomReadCost is a rough cost in USD of reading one custom card image with gpt-4o
+const DefaultCustomReadCost = 0.01
+
+// pendingCardPrefix marks the ID of a placeholder for a custom card that would have to be read
+const pendingCardPrefix = "pending:"
+
+// CubePlanner works out what loading a cube would do without doing it
+type CubePlanner interface {
+	Plan(ctx context.Context, cubeID string) (*LoadPlan, error)
+}
+
+// LoadPlan is everything a load would change. Nothing is written and no custom cards are read to produce it.
+type LoadPlan struct {
+	CubeID string
+	// VersionNumber is the version that would be created; it is only meaningful when Diff has changes
+	VersionNumber int
+	NewCards      []cubes.Card
+	ChangedCards  []CardChange
+	// CustomReads are image URLs of custom cards that would be read by the LLM, at EstimatedCost in total
+	CustomReads   []string
+	EstimatedCost float64
+	// ReusedCustomCards are new custom card image URLs that match a card already read
+	ReusedCustomCards []ReusedCustomCard
+	Report            LoadReport
+	// Diff is against the current version. Custom cards still to be read appear as added cards with a "pending:" ID.
+	Diff cubes.CubeDiff
+}
+
+// CardChange is a stored card whose source data has changed
+type CardChange struct {
+	Card  cubes.Card
+	Diffs []FieldDiff
+}
+
+// ReusedCustomCard is a new custom card image that would be mapped to an existing custom card
+type ReusedCustomCard struct {
+	ImageURL string
+	Card     cubes.Card
+	Distance int
+}
+
+// CubeLoaderWithCustomReadCost sets the estimated cost in USD of reading one custom card, used by Plan
+func CubeLoaderWithCustomReadCost(cost float64) CubeCobraLoaderOpts {
+	return func(c *CubeCobraLoader) {
+		c.customReadCost = cost
+	}
+}
+
+// Plan fetches the cube and its cards and reports what Load would do
+func (c *CubeCobraLoader) Plan(ctx context.Context, cubeID string) (*LoadPlan, error) {
+	cubeCobraCube, err := c.fetchCube(ctx, cubeID)
+	if err != nil {
+		return nil, err
+	}
+	return c.planCube(ctx, cubeID, cubeCobraCube)
+}
+
+func (c *CubeCobraLoader) planCube(ctx context.Context, cubeID string, cubeCobraCube cubes.CubeCobraCube) (*LoadPlan, error) {
+	customMappings, err := c.storage.GetAllCustomCardIDs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf(`getting custom mappings: %w`, err)
+	}
+
+	plan := &LoadPlan{CubeID: cubeID}
+	var cardIDs, customCardIDs []string
+	pendingCards := make(map[string]cubes.Card)
+	boardCardIDs := make(map[string][]string)
+	tags := make(map[string]map[string][]string)
+	for _, board := range sortedBoardNames(cubeCobraCube.Cards.Boards) {
+		for _, card := range cubeCobraCube.Cards.Boards[board] {
+			cardID := card.ID
+			if slices.Index(card.Tags, "custom") != -1 {
+				cardID, err = c.planCustom(ctx, plan, card.ImageURL, customMappings, pendingCards)
+				if err != nil {
+					return nil, fmt.Errorf(`plan custom: %w`, err)
+				}
+				if _, ok := pendingCards[cardID]; !ok {
+					customCardIDs = append(customCardIDs, cardID)
+				}
+			} else {
+				cardIDs = append(cardIDs, cardID)
+			}
+			boardCardIDs[board] = append(boardCardIDs[board], cardID)
+			addTags(tags, board, cardID, card.Tags)
+		}
+	}
+	plan.EstimatedCost = float64(len(plan.CustomReads)) * c.customReadCost
+
+	stored, err := c.storage.GetByIDs(ctx, append(slices.Clone(cardIDs), customCardIDs...))
+	if err != nil {
+		return nil, fmt.Errorf(`get stored cards: %w`, err)
+	}
+	cardsByID := make(map[string]cubes.Card, len(stored)+len(pendingCards))
+	for _, card := range stored {
+		cardsByID[card.ID] = card
+	}
+	if c.cardLoader != nil {
+		fetcher, ok := c.cardLoader.(CardFetcher)
+		if !ok {
+			return nil, errors.New(`card loader can't fetch cards without storing them`)
+		}
+		fetched, report, err := fetcher.FetchCards(ctx, uniqueIDs(cardIDs))
+		if err != nil {
+			return nil, fmt.Errorf(`fetch cards: %w`, err)
+		}
+		plan.Report = report
+		for _, card := range fetched {
+			if old, ok := cardsByID[card.ID]; ok {
+				if diffs := DiffCard(old, card); len(diffs) > 0 {
+					plan.ChangedCards = append(plan.ChangedCards, CardChange{Card: card, Diffs: diffs})
+				}
+				// Storage keeps the local image reference across upserts
+				card.ImageRef = old.ImageRef
+			} else {
+				plan.NewCards = append(plan.NewCards, card)
+			}
+			cardsByID[card.ID] = card
+		}
+	}
+	for id, card := range pendingCards {
+		cardsByID[id] = card
+	}
+
+	newCube := cubes.Cube{
+		ID:   cubeID,
+		Name: cubeCobraCube.Name,
+		Date: time.Now(),
+	}
+	for board, ids := range boardCardIDs {
+		var boardCards []cubes.Card
+		for _, id := range ids {
+			if card, ok := cardsByID[id]; ok {
+				boardCards = append(boardCards, card)
+			}
+		}
+		setBoard(&newCube, board, boardCards, tags[board])
+	}
+
+	currentCube, err := c.storage.GetCube(ctx, cubeID, nil, cubes.AllBoards)
+	if err != nil {
+		return nil, fmt.Errorf(`get cube: %w`, err)
+	}
+	if currentCube == nil {
+		currentCube = &cubes.Cube{ID: cubeID}
+	} else {
+		plan.VersionNumber = currentCube.VersionNumber + 1
+	}
+	plan.Diff = cubes.DiffCubes(*currentCube, newCube)
+	return plan, nil
+}
+
+// planCustom works out which card a custom card image would resolve to. Images that would need an LLM read get a
+// placeholder card in pendingCards.
+func (c *CubeCobraLoader) planCustom(ctx context.Context, plan *LoadPlan, imageURL string, customMappings map[string]string, pendingCards map[string]cubes.Card) (string, error) {
+	if cardID, ok := customMappings[imageURL]; ok {
+		return cardID, nil
+	}
+	pendingID := pendingCardPrefix + imageURL
+	if _, ok := pendingCards[pendingID]; ok {
+		return pendingID, nil
+	}
+	for _, reused := range plan.ReusedCustomCards {
+		if reused.ImageURL == imageURL {
+			return reused.Card.ID, nil
+		}
+	}
+
+	if c.deduper != nil {
+		hash, err := c.deduper.HashImage(ctx, imageURL)
+		if err == nil {
+			existing, distance, err := c.deduper.FindSimilar(ctx, hash)
+			if err != nil {
+				return "", fmt.Errorf(`find similar custom card: %w`, err)
+			}
+			if existing != nil {
+				plan.ReusedCustomCards = append(plan.ReusedCustomCards, ReusedCustomCard{
+					ImageURL: imageURL,
+					Card:     existing.Card,
+					Distance: distance,
+				})
+				return existing.Card.ID, nil
+			}
+		}
+	}
+
+	plan.CustomReads = append(plan.CustomReads, imageURL)
+	pendingCards[pendingID] = cubes.Card{
+		ID:       pendingID,
+		Name:     "unread custom card",
+		Set:      cubes.CustomSet,
+		ImageURI: imageURL,
+	}
+	return pendingID, nil
+}
+
+func uniqueIDs(ids []string) []string {
+	seen := make(map[string]struct{}, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
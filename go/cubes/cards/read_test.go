@@ -0,0 +1,118 @@
+package cards
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"slices"
+	"testing"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+	"github.com/mgdunn2/cube-datahub/cubes/llm"
+	"github.com/mgdunn2/cube-datahub/cubes/llm/llmtest"
+)
+
+var recordLLM = flag.Bool("record-llm", false, "re-record the LLM fixtures in testdata/llm against the llmtest stand-in")
+
+const llmFixtureDir = "testdata/llm"
+
+// deckPhoto stands in for a photo; replay only needs its bytes to match the recording
+var deckPhoto = []byte("\x89PNG\r\n\x1a\nfixture deck photo")
+
+var deckPool = []cubes.Card{
+	{ID: "77c6fa74-5543-42ac-9ead-0e890b188e99", Name: "Lightning Bolt"},
+	{ID: "1920dae4-fb92-4f19-ae4b-eb3276b8dac7", Name: "Counterspell"},
+	{ID: "11bf83bb-c95b-4b4f-9a56-ce7a1816307a", Name: "Delver of Secrets"},
+	{ID: "69daba76-96e8-4bcc-ab79-2f00189ad8fb", Name: "Tarmogoyf"},
+}
+
+// fixtureResponses are what the stand-in answers with when the fixtures are recorded
+func fixtureResponses(_, tool string) (any, error) {
+	switch tool {
+	case "card":
+		manaCost := "{1}{U}"
+		return cubes.LLMCardSchema{
+			Name:       "Fixture Custom Card",
+			ManaCost:   &manaCost,
+			Cmc:        2,
+			TypeLine:   "Creature — Human Wizard",
+			OracleText: "Flying",
+			Power:      2,
+			Toughness:  1,
+			Colors:     []string{"U"},
+			Set:        cubes.CustomSet,
+			ReleasedAt: "2024-01-01",
+		}, nil
+	case "deck":
+		return cubes.LLMDeckSchema{CardNames: []string{"Lightning Bolt", "Delver of Secrets", "Not In The Cube"}}, nil
+	}
+	return nil, errors.New("unexpected tool " + tool)
+}
+
+// replayReader replays the fixtures in testdata/llm, or with -record-llm records them afresh from the stand-in
+func replayReader(t *testing.T) *llm.Recorder {
+	t.Helper()
+	if !*recordLLM {
+		recorder, err := llm.NewRecorder(nil, llmFixtureDir, llm.RecordModeReplay)
+		if err != nil {
+			t.Fatalf("new recorder: %v", err)
+		}
+		return recorder
+	}
+	srv := llmtest.NewAnthropicServer(fixtureResponses)
+	t.Cleanup(srv.Close)
+	recorder, err := llm.NewRecorder(llm.NewAnthropic("test-key", llm.AnthropicWithBaseURL(srv.URL)), llmFixtureDir, llm.RecordModeRecord)
+	if err != nil {
+		t.Fatalf("new recorder: %v", err)
+	}
+	return recorder
+}
+
+func TestLLMCustomCardReaderReplay(t *testing.T) {
+	reader := NewLLMCustomCardReader(replayReader(t))
+
+	card, err := reader.ReadCard(context.Background(), customImageURL)
+	if err != nil {
+		t.Fatalf("read card: %v", err)
+	}
+	if card.Name != "Fixture Custom Card" || card.ManaCost == nil || *card.ManaCost != "{1}{U}" {
+		t.Errorf("card = %s %v, want Fixture Custom Card {1}{U}", card.Name, card.ManaCost)
+	}
+	if card.Type != "Creature" || !slices.Equal(card.SubType, []string{"Human", "Wizard"}) {
+		t.Errorf("type = %s %v, want Creature [Human Wizard]", card.Type, card.SubType)
+	}
+	if card.ImageURI != customImageURL {
+		t.Errorf("image = %q, want %q", card.ImageURI, customImageURL)
+	}
+}
+
+func TestLLMDeckReaderReplay(t *testing.T) {
+	reader := NewLLMDeckReader(nil, replayReader(t))
+	deck := cubes.Deck{Event: cubes.Event{Cube: cubes.Cube{Cards: deckPool}}}
+
+	deck, err := reader.ReadDeck(context.Background(), deck, deckPhoto)
+	if err != nil {
+		t.Fatalf("read deck: %v", err)
+	}
+	var names []string
+	for _, card := range deck.Cards {
+		names = append(names, card.Name)
+	}
+	// Names outside the cube are dropped
+	if want := []string{"Lightning Bolt", "Delver of Secrets"}; !slices.Equal(names, want) {
+		t.Errorf("deck = %v, want %v", names, want)
+	}
+}
+
+func TestReplayUnknownRequest(t *testing.T) {
+	recorder, err := llm.NewRecorder(nil, llmFixtureDir, llm.RecordModeReplay)
+	if err != nil {
+		t.Fatalf("new recorder: %v", err)
+	}
+	reader := NewLLMCustomCardReader(recorder)
+
+	_, err = reader.ReadCard(context.Background(), "https://i.imgur.com/never-recorded.png")
+	if !errors.Is(err, llm.ErrNotRecorded) {
+		t.Fatalf("read card: got %v, want ErrNotRecorded", err)
+	}
+}
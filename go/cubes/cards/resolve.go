@@ -0,0 +1,194 @@
+package cards
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+// Resolution maps each identifier passed to ResolveCards to the card it resolved to. Identifiers whose fuzzy name
+// lookup matched several cards are listed in Ambiguous, identifiers that matched nothing are listed in Unresolved and
+// identifiers whose Scryfall card couldn't be converted are listed in ConversionFailed.
+type Resolution struct {
+	Cards            map[CardIdentifier]cubes.Card
+	Ambiguous        []CardIdentifier
+	Unresolved       []CardIdentifier
+	ConversionFailed []CardIdentifier
+}
+
+// ResolveCards resolves identifiers given by ID, name, name and set, or set and collector number. Names that
+// /cards/collection can't match exactly fall back to Scryfall's fuzzy name search. Resolved cards are upserted and
+// name-only lookups are cached in storage so later resolutions skip the API.
+func (f *ScryfallApiCardLoader) ResolveCards(ctx context.Context, identifiers []CardIdentifier) (Resolution, error) {
+	res := Resolution{Cards: make(map[CardIdentifier]cubes.Card, len(identifiers))}
+
+	pending, err := f.resolveCached(ctx, identifiers, res)
+	if err != nil {
+		return Resolution{}, err
+	}
+
+	const batchSize = 75
+	var fetched []cubes.Card
+	var notFound []CardIdentifier
+	for start := 0; start < len(pending); start += batchSize {
+		end := min(start+batchSize, len(pending))
+		batch := pending[start:end]
+
+		var response CollectionResponse
+		if _, err := f.api.postJSON(ctx, f.baseURL+"/cards/collection", CollectionRequest{Identifiers: batch}, &response); err != nil {
+			return Resolution{}, fmt.Errorf(`post scryfall collection: %w`, err)
+		}
+		for _, identifier := range batch {
+			scryfallCard, ok := matchIdentifier(identifier, response.Cards)
+			if !ok {
+				notFound = append(notFound, identifier)
+				continue
+			}
+			card, err := scryfallCard.ToCard()
+			if err != nil {
+				res.ConversionFailed = append(res.ConversionFailed, identifier)
+				continue
+			}
+			res.Cards[identifier] = card
+			fetched = append(fetched, card)
+		}
+	}
+
+	for _, identifier := range notFound {
+		if identifier.Name == "" {
+			res.Unresolved = append(res.Unresolved, identifier)
+			continue
+		}
+		scryfallCard, err := f.fuzzyNamed(ctx, identifier)
+		var statusErr *StatusError
+		switch {
+		case errors.As(err, &statusErr) && statusErr.Type == "ambiguous":
+			res.Ambiguous = append(res.Ambiguous, identifier)
+			continue
+		case errors.Is(err, ErrNotFound):
+			res.Unresolved = append(res.Unresolved, identifier)
+			continue
+		case err != nil:
+			return Resolution{}, fmt.Errorf(`fuzzy search %q: %w`, identifier.Name, err)
+		}
+		card, err := scryfallCard.ToCard()
+		if err != nil {
+			res.ConversionFailed = append(res.ConversionFailed, identifier)
+			continue
+		}
+		res.Cards[identifier] = card
+		fetched = append(fetched, card)
+	}
+
+	if err := f.storage.UpsertCards(ctx, fetched); err != nil {
+		return Resolution{}, fmt.Errorf(`upsert cards: %w`, err)
+	}
+	cache := make(map[string]string)
+	for identifier, card := range res.Cards {
+		if isNameOnly(identifier) {
+			cache[identifier.Name] = card.ID
+		}
+	}
+	if err := f.storage.CacheCardIDs(ctx, cache); err != nil {
+		return Resolution{}, fmt.Errorf(`cache card IDs: %w`, err)
+	}
+	return res, nil
+}
+
+// resolveCached fills res with name-only identifiers found in the name cache and returns the identifiers still to
+// resolve, without duplicates
+func (f *ScryfallApiCardLoader) resolveCached(ctx context.Context, identifiers []CardIdentifier, res Resolution) ([]CardIdentifier, error) {
+	var names []string
+	for _, identifier := range identifiers {
+		if isNameOnly(identifier) {
+			names = append(names, identifier.Name)
+		}
+	}
+	cachedIDs, err := f.storage.GetCachedCardIDs(ctx, names)
+	if err != nil {
+		return nil, fmt.Errorf(`get cached card IDs: %w`, err)
+	}
+	ids := make([]string, 0, len(cachedIDs))
+	for _, id := range cachedIDs {
+		ids = append(ids, id)
+	}
+	cached, err := f.storage.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf(`get cached cards: %w`, err)
+	}
+	cachedCards := make(map[string]cubes.Card, len(cached))
+	for _, card := range cached {
+		cachedCards[card.ID] = card
+	}
+
+	var pending []CardIdentifier
+	seen := make(map[CardIdentifier]struct{}, len(identifiers))
+	for _, identifier := range identifiers {
+		if _, ok := seen[identifier]; ok {
+			continue
+		}
+		seen[identifier] = struct{}{}
+		if isNameOnly(identifier) {
+			if card, ok := cachedCards[cachedIDs[strings.ToLower(identifier.Name)]]; ok {
+				res.Cards[identifier] = card
+				continue
+			}
+		}
+		pending = append(pending, identifier)
+	}
+	return pending, nil
+}
+
+func (f *ScryfallApiCardLoader) fuzzyNamed(ctx context.Context, identifier CardIdentifier) (cubes.ScryfallCard, error) {
+	query := url.Values{"fuzzy": {identifier.Name}}
+	if identifier.Set != "" {
+		query.Set("set", identifier.Set)
+	}
+	var scryfallCard cubes.ScryfallCard
+	if _, err := f.api.getJSON(ctx, f.baseURL+"/cards/named?"+query.Encode(), &scryfallCard); err != nil {
+		return cubes.ScryfallCard{}, err
+	}
+	return scryfallCard, nil
+}
+
+// matchIdentifier finds the card in a collection response that an identifier asked for. Scryfall doesn't return cards
+// in request order so they have to be matched up again.
+func matchIdentifier(identifier CardIdentifier, scryfallCards []cubes.ScryfallCard) (cubes.ScryfallCard, bool) {
+	for _, sc := range scryfallCards {
+		switch {
+		case identifier.ID != "":
+			if sc.ID == identifier.ID {
+				return sc, true
+			}
+		case identifier.CollectorNumber != "":
+			if strings.EqualFold(sc.Set, identifier.Set) && strings.EqualFold(sc.CollectorNumber, identifier.CollectorNumber) {
+				return sc, true
+			}
+		case identifier.Name != "":
+			if scryfallNameMatches(sc, identifier.Name) && (identifier.Set == "" || strings.EqualFold(sc.Set, identifier.Set)) {
+				return sc, true
+			}
+		}
+	}
+	return cubes.ScryfallCard{}, false
+}
+
+func scryfallNameMatches(sc cubes.ScryfallCard, name string) bool {
+	if strings.EqualFold(sc.Name, name) || strings.EqualFold(frontFace(sc.Name), name) {
+		return true
+	}
+	for _, face := range sc.CardFaces {
+		if strings.EqualFold(face.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNameOnly(identifier CardIdentifier) bool {
+	return identifier.Name != "" && identifier.ID == "" && identifier.Set == "" && identifier.CollectorNumber == ""
+}
@@ -0,0 +1,98 @@
+package cards
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestResolveCards(t *testing.T) {
+	ctx := context.Background()
+	srv := newTestServer(t)
+	// A card Scryfall returns but that can't be converted, as its release date doesn't parse
+	err := srv.AddCard(json.RawMessage(`{"id": "00000000-0000-0000-0000-00000000bad1", "name": "Unreleased Card", "set": "unk", "collector_number": "1", "type_line": "Instant", "released_at": "soon"}`))
+	if err != nil {
+		t.Fatalf("add card: %v", err)
+	}
+	storage := newMemStorage()
+	loader := NewScryfallLoader(storage, ScryfallLoaderWithBaseURL(srv.URL))
+
+	byID := CardIdentifier{ID: "69daba76-96e8-4bcc-ab79-2f00189ad8fb"}
+	byName := CardIdentifier{Name: "lightning bolt"}
+	byFace := CardIdentifier{Name: "Delver of Secrets"}
+	byNameAndSet := CardIdentifier{Name: "Counterspell", Set: "ema"}
+	bySetAndNumber := CardIdentifier{Set: "wwk", CollectorNumber: "31"}
+	fuzzy := CardIdentifier{Name: "Scalding"}
+	fuzzyInSet := CardIdentifier{Name: "Mind Sculptor", Set: "wwk"}
+	ambiguous := CardIdentifier{Name: "Tar"}
+	unknown := CardIdentifier{Name: "Black Lotus"}
+	wrongSet := CardIdentifier{Name: "Counterspell", Set: "a25"}
+	unknownNumber := CardIdentifier{Set: "wwk", CollectorNumber: "999"}
+	unconvertible := CardIdentifier{Name: "Unreleased Card"}
+
+	res, err := loader.ResolveCards(ctx, []CardIdentifier{
+		byID, byName, byFace, byNameAndSet, bySetAndNumber, fuzzy, fuzzyInSet, ambiguous, unknown, wrongSet,
+		unknownNumber, unconvertible, byName,
+	})
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	for identifier, want := range map[CardIdentifier]string{
+		byID:           "Tarmogoyf",
+		byName:         "Lightning Bolt",
+		byFace:         "Delver of Secrets",
+		byNameAndSet:   "Counterspell",
+		bySetAndNumber: "Jace, the Mind Sculptor",
+		fuzzy:          "Scalding Tarn",
+		fuzzyInSet:     "Jace, the Mind Sculptor",
+	} {
+		card, ok := res.Cards[identifier]
+		if !ok || card.Name != want {
+			t.Errorf("%+v resolved to %q, %v, want %s", identifier, card.Name, ok, want)
+		}
+		if _, ok := storage.cards[card.ID]; !ok {
+			t.Errorf("%s not stored", want)
+		}
+	}
+	if len(res.Cards) != 7 {
+		t.Errorf("resolved %d identifiers, want 7", len(res.Cards))
+	}
+	if !slices.Equal(res.Ambiguous, []CardIdentifier{ambiguous}) {
+		t.Errorf("ambiguous = %+v, want %+v", res.Ambiguous, ambiguous)
+	}
+	if want := []CardIdentifier{unknown, wrongSet, unknownNumber}; !slices.Equal(res.Unresolved, want) {
+		t.Errorf("unresolved = %+v, want %+v", res.Unresolved, want)
+	}
+	if !slices.Equal(res.ConversionFailed, []CardIdentifier{unconvertible}) {
+		t.Errorf("conversion failed = %+v, want %+v", res.ConversionFailed, unconvertible)
+	}
+
+	// Only name-only lookups are cached, fuzzy ones included
+	for name, want := range map[string]string{
+		"lightning bolt":    "77c6fa74-5543-42ac-9ead-0e890b188e99",
+		"delver of secrets": "11bf83bb-c95b-4b4f-9a56-ce7a1816307a",
+		"scalding":          "3e3f0bcd-0796-494d-bf51-94b33c1671e9",
+	} {
+		if got := storage.nameCache[name]; got != want {
+			t.Errorf("cached %q as %q, want %q", name, got, want)
+		}
+	}
+	if len(storage.nameCache) != 3 {
+		t.Errorf("cached %v, want 3 names", storage.nameCache)
+	}
+
+	// Cached names resolve without asking Scryfall, whatever their case
+	requests := srv.Requests()
+	shouted := CardIdentifier{Name: "SCALDING"}
+	res, err = loader.ResolveCards(ctx, []CardIdentifier{byName, shouted})
+	if err != nil {
+		t.Fatalf("resolve cached: %v", err)
+	}
+	if srv.Requests() != requests {
+		t.Errorf("made %d requests for cached names, want none", srv.Requests()-requests)
+	}
+	if res.Cards[byName].Name != "Lightning Bolt" || res.Cards[shouted].Name != "Scalding Tarn" {
+		t.Errorf("cached cards = %+v", res.Cards)
+	}
+}
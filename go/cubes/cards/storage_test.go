@@ -0,0 +1,185 @@
+package cards
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+// memStorage keeps the parts of cubes.Storage the loaders and readers use in memory. Other methods panic through the
+// nil embedded interface.
+type memStorage struct {
+	cubes.Storage
+
+	mu          sync.Mutex
+	cards       map[string]cubes.Card
+	customCards map[string]string
+	verified    map[string]bool
+	phashes     map[string]uint64
+	cubes       map[string][]cubes.Cube
+	nameCache   map[string]string
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{
+		cards:       make(map[string]cubes.Card),
+		customCards: make(map[string]string),
+		verified:    make(map[string]bool),
+		phashes:     make(map[string]uint64),
+		cubes:       make(map[string][]cubes.Cube),
+		nameCache:   make(map[string]string),
+	}
+}
+
+func (s *memStorage) UpsertCards(_ context.Context, cards []cubes.Card) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, card := range cards {
+		s.cards[card.ID] = card
+	}
+	return nil
+}
+
+func (s *memStorage) GetByIDs(_ context.Context, ids []string) ([]cubes.Card, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var cards []cubes.Card
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if card, ok := s.cards[id]; ok && !seen[id] {
+			seen[id] = true
+			cards = append(cards, card)
+		}
+	}
+	return cards, nil
+}
+
+func (s *memStorage) GetByNames(_ context.Context, names []string) ([]cubes.Card, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var cards []cubes.Card
+	for _, card := range s.cards {
+		if slices.Contains(names, card.Name) {
+			cards = append(cards, card)
+		}
+	}
+	return cards, nil
+}
+
+func (s *memStorage) GetCachedCardIDs(_ context.Context, names []string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cardIDs := make(map[string]string)
+	for _, name := range names {
+		if id, ok := s.nameCache[strings.ToLower(name)]; ok {
+			cardIDs[strings.ToLower(name)] = id
+		}
+	}
+	return cardIDs, nil
+}
+
+func (s *memStorage) CacheCardIDs(_ context.Context, cardIDsByName map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, id := range cardIDsByName {
+		s.nameCache[strings.ToLower(name)] = id
+	}
+	return nil
+}
+
+func (s *memStorage) AddCustomCard(_ context.Context, imageURL, cardID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.customCards[imageURL]; !ok {
+		s.customCards[imageURL] = cardID
+	}
+	return nil
+}
+
+func (s *memStorage) GetAllCustomCardIDs(context.Context) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make(map[string]string, len(s.customCards))
+	for imageURL, cardID := range s.customCards {
+		ids[imageURL] = cardID
+	}
+	return ids, nil
+}
+
+func (s *memStorage) GetCustomCards(context.Context) ([]cubes.CustomCard, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var customCards []cubes.CustomCard
+	for imageURL, cardID := range s.customCards {
+		customCard := cubes.CustomCard{
+			Card:     s.cards[cardID],
+			ImageURL: imageURL,
+			Verified: s.verified[cardID],
+		}
+		if phash, ok := s.phashes[imageURL]; ok {
+			customCard.PHash = &phash
+		}
+		customCards = append(customCards, customCard)
+	}
+	return customCards, nil
+}
+
+func (s *memStorage) SetCustomCardVerified(_ context.Context, cardID string, verified bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.verified[cardID] = verified
+	return nil
+}
+
+func (s *memStorage) SetCustomCardPHash(_ context.Context, imageURL string, phash uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.phashes[imageURL] = phash
+	return nil
+}
+
+func (s *memStorage) SetCardImageRef(_ context.Context, cardID, ref string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	card := s.cards[cardID]
+	card.ImageRef = ref
+	s.cards[cardID] = card
+	return nil
+}
+
+func (s *memStorage) UpdateCube(_ context.Context, cube cubes.Cube) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cubes[cube.ID] = append(s.cubes[cube.ID], cube)
+	return nil
+}
+
+func (s *memStorage) GetCube(_ context.Context, id string, version *int, _ ...string) (*cubes.Cube, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions := s.cubes[id]
+	for i := len(versions) - 1; i >= 0; i-- {
+		if version == nil || versions[i].VersionNumber == *version {
+			cube := versions[i]
+			return &cube, nil
+		}
+	}
+	return nil, nil
+}
+
+// staticCardReader reads every custom card image as the same card, taking delay over each read
+type staticCardReader struct {
+	card  cubes.Card
+	delay time.Duration
+	reads int
+}
+
+func (r *staticCardReader) ReadCard(context.Context, string) (cubes.Card, error) {
+	r.reads++
+	time.Sleep(r.delay)
+	return r.card, nil
+}
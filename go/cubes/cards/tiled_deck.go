@@ -0,0 +1,251 @@
+package cards
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"sort"
+	"sync"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+const (
+	defaultTileGridLong  = 3
+	defaultTileGridShort = 2
+	defaultTileOverlap   = 0.2
+	defaultTileWorkers   = 4
+	// tileJPEGQuality is the quality tiles of a JPEG photo are encoded at
+	tileJPEGQuality = 90
+)
+
+// TiledDeckReport compares a tiled read with the single whole-image pass LLMDeckReader makes
+type TiledDeckReport struct {
+	// Tiles is how many tiles were read, 0 if the image couldn't be decoded and only the whole image was read
+	Tiles int
+	// TileError says why the image couldn't be tiled, such as a format the standard library can't decode
+	TileError   string
+	FailedTiles int
+	// SinglePassError says why the whole-image pass failed when the tiles were read without it. SinglePass and
+	// Recovered are left empty then, as there is nothing to compare the tiles with.
+	SinglePassError string
+	// SinglePass is how many cards the whole-image pass found on its own
+	SinglePass int
+	// Total is how many cards the merged read found
+	Total int
+	// Recovered lists the cards the tiles found beyond the whole-image pass
+	Recovered []string
+	// Unmatched lists names the model returned that match no card in the cube
+	Unmatched []string
+}
+
+// TiledDeckReader reads a deck photo in several passes: the photo is split into a grid of overlapping tiles that are
+// read concurrently, the whole photo is read as well, and the results are merged. Small or glared card names that a
+// single pass over the whole photo misses are often legible within a tile.
+//
+// A card lying across a tile overlap is seen by both tiles, so the merged count of a card is the most copies any one
+// pass saw rather than the sum; copies that are only visible in different tiles are undercounted.
+type TiledDeckReader struct {
+	reader  *LLMDeckReader
+	cols    int
+	rows    int
+	overlap float64
+	workers int
+}
+
+type TiledDeckReaderOpts func(*TiledDeckReader)
+
+// TiledWithGrid splits the photo into cols by rows tiles. By default the longer side of the photo gets 3 tiles and the
+// shorter side 2.
+func TiledWithGrid(cols, rows int) TiledDeckReaderOpts {
+	return func(t *TiledDeckReader) {
+		t.cols = cols
+		t.rows = rows
+	}
+}
+
+// TiledWithOverlap extends each tile into its neighbours by this fraction of a tile, so a card cut by a tile edge is
+// whole in at least one tile
+func TiledWithOverlap(overlap float64) TiledDeckReaderOpts {
+	return func(t *TiledDeckReader) {
+		t.overlap = overlap
+	}
+}
+
+// TiledWithWorkers sets how many passes are read at once
+func TiledWithWorkers(workers int) TiledDeckReaderOpts {
+	return func(t *TiledDeckReader) {
+		t.workers = workers
+	}
+}
+
+func NewTiledDeckReader(reader *LLMDeckReader, opts ...TiledDeckReaderOpts) *TiledDeckReader {
+	t := &TiledDeckReader{reader: reader, overlap: defaultTileOverlap, workers: defaultTileWorkers}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+func (t *TiledDeckReader) ReadDeck(ctx context.Context, deck cubes.Deck, img []byte) (cubes.Deck, error) {
+	deck, _, err := t.ReadDeckWithReport(ctx, deck, img)
+	return deck, err
+}
+
+// ReadDeckWithReport reads the deck and reports how the tiles did against the whole-image pass. Images the standard
+// library can't decode, such as webp, are read in a single pass and the report's TileError says why.
+func (t *TiledDeckReader) ReadDeckWithReport(ctx context.Context, deck cubes.Deck, img []byte) (cubes.Deck, TiledDeckReport, error) {
+	var report TiledDeckReport
+	passes := []tilePass{{image: img}}
+	tiles, err := t.tiles(img)
+	if err != nil {
+		report.TileError = err.Error()
+	} else {
+		passes = append(passes, tiles...)
+		report.Tiles = len(tiles)
+	}
+
+	pool := deck.Event.Cube.Cards
+	results := make([][]string, len(passes))
+	errs := make([]error, len(passes))
+	sem := make(chan struct{}, max(1, t.workers))
+	var wg sync.WaitGroup
+	for i, pass := range passes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i], errs[i] = t.reader.readCardNames(ctx, pool, pass.image, pass.note)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs[1:] {
+		if err != nil {
+			report.FailedTiles++
+		}
+	}
+	if errs[0] != nil && report.FailedTiles == report.Tiles {
+		return cubes.Deck{}, report, fmt.Errorf(`read whole image: %w`, errors.Join(errs...))
+	}
+	if errs[0] != nil {
+		report.SinglePassError = errs[0].Error()
+	}
+
+	matcher := newCardMatcher(pool)
+	unmatched := make(map[string]bool)
+	// counts[i] holds how many copies of each card pass i saw
+	counts := make([]map[string]int, len(passes))
+	cardsByID := make(map[string]cubes.Card)
+	for i, names := range results {
+		counts[i] = make(map[string]int)
+		for _, name := range names {
+			card, _, ok := matcher.match(name, "")
+			if !ok {
+				unmatched[name] = true
+				continue
+			}
+			counts[i][card.ID]++
+			cardsByID[card.ID] = card
+		}
+	}
+
+	ids := make([]string, 0, len(cardsByID))
+	for id := range cardsByID {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return cardsByID[ids[i]].Name < cardsByID[ids[j]].Name })
+	for _, id := range ids {
+		copies := 0
+		for _, c := range counts {
+			copies = max(copies, c[id])
+		}
+		for range copies {
+			deck.Cards = append(deck.Cards, cardsByID[id])
+		}
+		if report.SinglePassError != "" {
+			continue
+		}
+		report.SinglePass += counts[0][id]
+		if extra := copies - counts[0][id]; extra > 0 {
+			for range extra {
+				report.Recovered = append(report.Recovered, cardsByID[id].Name)
+			}
+		}
+	}
+	report.Total = len(deck.Cards)
+	for name := range unmatched {
+		report.Unmatched = append(report.Unmatched, name)
+	}
+	sort.Strings(report.Unmatched)
+	return deck, report, nil
+}
+
+type tilePass struct {
+	image []byte
+	note  string
+}
+
+// tiles decodes img and cuts it into overlapping tiles. Tiles of a JPEG photo are encoded as JPEG, which keeps them
+// far smaller than PNG would, and tiles of any other photo as PNG.
+func (t *TiledDeckReader) tiles(img []byte) ([]tilePass, error) {
+	decoded, format, err := image.Decode(bytes.NewReader(img))
+	if err != nil {
+		return nil, fmt.Errorf(`decode image: %w`, err)
+	}
+	sub, ok := decoded.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if !ok {
+		return nil, errors.New("image can't be cropped")
+	}
+	bounds := decoded.Bounds()
+	cols, rows := t.cols, t.rows
+	if cols <= 0 || rows <= 0 {
+		cols, rows = defaultTileGridLong, defaultTileGridShort
+		if bounds.Dy() > bounds.Dx() {
+			cols, rows = rows, cols
+		}
+	}
+
+	var tiles []tilePass
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			x0, x1 := tileSpan(bounds.Min.X, bounds.Dx(), col, cols, t.overlap)
+			y0, y1 := tileSpan(bounds.Min.Y, bounds.Dy(), row, rows, t.overlap)
+			tile := sub.SubImage(image.Rect(x0, y0, x1, y1))
+			var buf bytes.Buffer
+			if format == "jpeg" {
+				err = jpeg.Encode(&buf, tile, &jpeg.Options{Quality: tileJPEGQuality})
+			} else {
+				err = png.Encode(&buf, tile)
+			}
+			if err != nil {
+				return nil, fmt.Errorf(`encode tile: %w`, err)
+			}
+			tiles = append(tiles, tilePass{
+				image: buf.Bytes(),
+				note: fmt.Sprintf(`
+This image is one tile of a larger photo, row %d of %d and column %d of %d. Cards cut off at its edges are read from
+other tiles, so only return cards whose name you can read.
+`, row+1, rows, col+1, cols),
+			})
+		}
+	}
+	return tiles, nil
+}
+
+// tileSpan returns the start and end of tile i of n along an axis, extended by overlap of a tile on each side
+func tileSpan(origin, length, i, n int, overlap float64) (int, int) {
+	size := float64(length) / float64(n)
+	pad := size * overlap
+	start := max(0, int(float64(i)*size-pad))
+	end := min(length, int(float64(i+1)*size+pad))
+	return origin + start, origin + end
+}
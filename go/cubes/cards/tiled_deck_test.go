@@ -0,0 +1,154 @@
+package cards
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+	"github.com/mgdunn2/cube-datahub/cubes/llm"
+)
+
+// deckNamesReader answers every deck request with the same card names
+type deckNamesReader struct {
+	calls atomic.Int32
+}
+
+func (r *deckNamesReader) Generate(context.Context, llm.Request) (string, error) {
+	r.calls.Add(1)
+	return `{"card_names": ["Lightning Bolt", "Counterspell"]}`, nil
+}
+
+func TestTiledDeckReader(t *testing.T) {
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 300, 200))); err != nil {
+		t.Fatalf("encode photo: %v", err)
+	}
+	tests := []struct {
+		name         string
+		image        []byte
+		wantTiles    int
+		wantTileErr  bool
+		wantRequests int32
+	}{
+		{"png", photo.Bytes(), 6, false, 7},
+		{"undecodable", []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), 0, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ir := &deckNamesReader{}
+			reader := NewTiledDeckReader(NewLLMDeckReader(nil, ir))
+			deck := cubes.Deck{Event: cubes.Event{Cube: cubes.Cube{Cards: deckPool}}}
+
+			deck, report, err := reader.ReadDeckWithReport(context.Background(), deck, tt.image)
+			if err != nil {
+				t.Fatalf("read deck: %v", err)
+			}
+			if report.Tiles != tt.wantTiles || (report.TileError != "") != tt.wantTileErr {
+				t.Errorf("report = %+v, want %d tiles and tile error %v", report, tt.wantTiles, tt.wantTileErr)
+			}
+			if got := ir.calls.Load(); got != tt.wantRequests {
+				t.Errorf("made %d requests, want %d", got, tt.wantRequests)
+			}
+			// Every pass saw one copy of each card, so the merge keeps one
+			var names []string
+			for _, card := range deck.Cards {
+				names = append(names, card.Name)
+			}
+			if want := []string{"Counterspell", "Lightning Bolt"}; !slices.Equal(names, want) {
+				t.Errorf("deck = %v, want %v", names, want)
+			}
+		})
+	}
+}
+
+// tileOnlyReader fails the whole-image pass and answers every tile, recording the format of each image it is sent
+type tileOnlyReader struct {
+	mu      sync.Mutex
+	formats []string
+}
+
+func (r *tileOnlyReader) Generate(_ context.Context, req llm.Request) (string, error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(req.ImageByes))
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	r.formats = append(r.formats, format)
+	r.mu.Unlock()
+	if !strings.Contains(req.Prompt, "one tile of a larger photo") {
+		return "", errors.New("whole image too large")
+	}
+	return `{"card_names": ["Lightning Bolt", "Counterspell"]}`, nil
+}
+
+func TestTiledDeckReaderWholeImageFails(t *testing.T) {
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 300, 200))); err != nil {
+		t.Fatalf("encode photo: %v", err)
+	}
+	reader := NewTiledDeckReader(NewLLMDeckReader(nil, &tileOnlyReader{}))
+	deck := cubes.Deck{Event: cubes.Event{Cube: cubes.Cube{Cards: deckPool}}}
+
+	deck, report, err := reader.ReadDeckWithReport(context.Background(), deck, photo.Bytes())
+	if err != nil {
+		t.Fatalf("read deck: %v", err)
+	}
+	if len(deck.Cards) != 2 || report.Total != 2 {
+		t.Errorf("read %d cards with a total of %d, want the 2 the tiles found", len(deck.Cards), report.Total)
+	}
+	// Without the whole-image pass there is nothing to compare the tiles with
+	if !strings.Contains(report.SinglePassError, "whole image too large") || report.SinglePass != 0 || len(report.Recovered) != 0 {
+		t.Errorf("report = %+v, want the whole-image error and no single-pass comparison", report)
+	}
+	if report.FailedTiles != 0 {
+		t.Errorf("%d tiles failed, want none", report.FailedTiles)
+	}
+}
+
+func TestTiledDeckReaderTileFormat(t *testing.T) {
+	photo := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	var pngPhoto, jpegPhoto bytes.Buffer
+	if err := png.Encode(&pngPhoto, photo); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	if err := jpeg.Encode(&jpegPhoto, photo, nil); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	tests := []struct {
+		name  string
+		photo []byte
+		want  string
+	}{
+		{"png", pngPhoto.Bytes(), "png"},
+		{"jpeg", jpegPhoto.Bytes(), "jpeg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ir := &tileOnlyReader{}
+			reader := NewTiledDeckReader(NewLLMDeckReader(nil, ir))
+			deck := cubes.Deck{Event: cubes.Event{Cube: cubes.Cube{Cards: deckPool}}}
+			if _, _, err := reader.ReadDeckWithReport(context.Background(), deck, tt.photo); err != nil {
+				t.Fatalf("read deck: %v", err)
+			}
+			// The whole photo and its 6 tiles all go out in the photo's own format
+			if len(ir.formats) != 7 {
+				t.Fatalf("sent %d images, want 7", len(ir.formats))
+			}
+			for _, format := range ir.formats {
+				if format != tt.want {
+					t.Errorf("sent formats %v, want every one %s", ir.formats, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package cards
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+	"github.com/mgdunn2/cube-datahub/cubes/images"
+)
+
+const (
+	// DefaultTTSCardBack is Scryfall's image of the standard Magic card back
+	DefaultTTSCardBack = "https://backs.scryfall.io/large/2/2/222b7a3b-2321-4d4c-af19-19338b134971.jpg"
+	// DefaultTTSMaxDeckSize is the most cards put in one deck object. Larger decks load slowly and become hard to
+	// search in Tabletop Simulator, so cubes are split into several.
+	DefaultTTSMaxDeckSize = 100
+	// ttsDeckSpacing is the distance between deck objects laid out side by side
+	ttsDeckSpacing = 3.0
+)
+
+// ttsSave is a Tabletop Simulator saved object, as found in the Saved Objects folder
+type ttsSave struct {
+	ObjectStates []ttsObject `json:"ObjectStates"`
+}
+
+// ttsObject is either a deck or, for a deck of one, a single card
+type ttsObject struct {
+	Name             string                `json:"Name"`
+	Transform        ttsTransform          `json:"Transform"`
+	Nickname         string                `json:"Nickname"`
+	Description      string                `json:"Description"`
+	CardID           int                   `json:"CardID,omitempty"`
+	DeckIDs          []int                 `json:"DeckIDs,omitempty"`
+	CustomDeck       map[string]ttsFaceSet `json:"CustomDeck"`
+	ContainedObjects []ttsObject           `json:"ContainedObjects,omitempty"`
+}
+
+type ttsTransform struct {
+	PosX   float64 `json:"posX"`
+	PosY   float64 `json:"posY"`
+	PosZ   float64 `json:"posZ"`
+	RotX   float64 `json:"rotX"`
+	RotY   float64 `json:"rotY"`
+	RotZ   float64 `json:"rotZ"`
+	ScaleX float64 `json:"scaleX"`
+	ScaleY float64 `json:"scaleY"`
+	ScaleZ float64 `json:"scaleZ"`
+}
+
+// ttsFaceSet is a sheet of card faces. Each card gets a sheet of its own so images can be used as they are.
+type ttsFaceSet struct {
+	FaceURL      string `json:"FaceURL"`
+	BackURL      string `json:"BackURL"`
+	NumWidth     int    `json:"NumWidth"`
+	NumHeight    int    `json:"NumHeight"`
+	BackIsHidden bool   `json:"BackIsHidden"`
+	UniqueBack   bool   `json:"UniqueBack"`
+	Type         int    `json:"Type"`
+}
+
+// TTSExporter writes decks and cubes as Tabletop Simulator saved objects
+type TTSExporter struct {
+	backURL     string
+	maxDeckSize int
+	imageStore  *images.FSStore
+}
+
+type TTSExporterOpts func(*TTSExporter)
+
+// TTSWithCardBack sets the image used for the back of every card
+func TTSWithCardBack(url string) TTSExporterOpts {
+	return func(t *TTSExporter) {
+		t.backURL = url
+	}
+}
+
+// TTSWithMaxDeckSize sets the most cards in one deck object, at least 1
+func TTSWithMaxDeckSize(size int) TTSExporterOpts {
+	return func(t *TTSExporter) {
+		t.maxDeckSize = max(size, 1)
+	}
+}
+
+// TTSWithImageStore uses the locally stored copy of a card's image when there is one, instead of its ImageURI. The
+// saved object then only works on machines with the same files.
+func TTSWithImageStore(store *images.FSStore) TTSExporterOpts {
+	return func(t *TTSExporter) {
+		t.imageStore = store
+	}
+}
+
+func NewTTSExporter(opts ...TTSExporterOpts) *TTSExporter {
+	exporter := &TTSExporter{
+		backURL:     DefaultTTSCardBack,
+		maxDeckSize: DefaultTTSMaxDeckSize,
+	}
+	for _, opt := range opts {
+		opt(exporter)
+	}
+	return exporter
+}
+
+// WriteDeck writes the maindeck and sideboard as deck objects side by side
+func (t *TTSExporter) WriteDeck(w io.Writer, deck cubes.Deck) error {
+	name := deck.Description
+	if name == "" {
+		name = "Deck"
+	}
+	var save ttsSave
+	sheets := 0
+	for _, part := range []struct {
+		name  string
+		cards []cubes.Card
+	}{
+		{name, deck.Cards},
+		{name + " sideboard", deck.Sideboard},
+	} {
+		objects, err := t.deckObjects(part.name, part.cards, len(save.ObjectStates), &sheets)
+		if err != nil {
+			return err
+		}
+		save.ObjectStates = append(save.ObjectStates, objects...)
+	}
+	return writeTTSSave(w, save)
+}
+
+// WriteCube writes the cube's mainboard, every copy of each card, split into as many deck objects as it needs
+func (t *TTSExporter) WriteCube(w io.Writer, cube cubes.Cube) error {
+	sheets := 0
+	objects, err := t.deckObjects(cube.Name, cube.Cards, 0, &sheets)
+	if err != nil {
+		return err
+	}
+	return writeTTSSave(w, ttsSave{ObjectStates: objects})
+}
+
+// deckObjects splits cards into deck objects of at most maxDeckSize, numbered in their nicknames when there are several.
+// Objects are laid out in a row starting at position offset. sheets counts the image sheets used so far in the save, so
+// that sheet IDs stay unique when decks are combined in game.
+func (t *TTSExporter) deckObjects(name string, cardList []cubes.Card, offset int, sheets *int) ([]ttsObject, error) {
+	var chunks [][]cubes.Card
+	for start := 0; start < len(cardList); start += t.maxDeckSize {
+		chunks = append(chunks, cardList[start:min(start+t.maxDeckSize, len(cardList))])
+	}
+	objects := make([]ttsObject, 0, len(chunks))
+	for i, chunk := range chunks {
+		nickname := name
+		if len(chunks) > 1 {
+			nickname = fmt.Sprintf("%s (%d/%d)", name, i+1, len(chunks))
+		}
+		transform := ttsFaceDown(float64(offset+i) * ttsDeckSpacing)
+
+		var cardObjects []ttsObject
+		for _, card := range chunk {
+			*sheets++
+			cardObject, err := t.cardObject(card, *sheets, transform)
+			if err != nil {
+				return nil, err
+			}
+			cardObjects = append(cardObjects, cardObject)
+		}
+		// Tabletop Simulator has no decks of one card
+		if len(cardObjects) == 1 {
+			objects = append(objects, cardObjects[0])
+			continue
+		}
+
+		deck := ttsObject{
+			Name:       "DeckCustom",
+			Transform:  transform,
+			Nickname:   nickname,
+			CustomDeck: make(map[string]ttsFaceSet, len(cardObjects)),
+		}
+		for _, cardObject := range cardObjects {
+			deck.DeckIDs = append(deck.DeckIDs, cardObject.CardID)
+			for id, faces := range cardObject.CustomDeck {
+				deck.CustomDeck[id] = faces
+			}
+		}
+		deck.ContainedObjects = cardObjects
+		objects = append(objects, deck)
+	}
+	return objects, nil
+}
+
+// cardObject is a card on its own one-image sheet, sheetID. Card IDs are the sheet ID times 100 plus the card's index
+// on the sheet, which is always 0.
+func (t *TTSExporter) cardObject(card cubes.Card, sheetID int, transform ttsTransform) (ttsObject, error) {
+	faceURL, err := t.faceURL(card)
+	if err != nil {
+		return ttsObject{}, err
+	}
+	description := card.TypeLine()
+	if card.TextBox != "" {
+		description += "\n" + card.TextBox
+	}
+	return ttsObject{
+		Name:        "Card",
+		Transform:   transform,
+		Nickname:    card.Name,
+		Description: description,
+		CardID:      sheetID * 100,
+		CustomDeck: map[string]ttsFaceSet{
+			fmt.Sprint(sheetID): {
+				FaceURL:      faceURL,
+				BackURL:      t.backURL,
+				NumWidth:     1,
+				NumHeight:    1,
+				BackIsHidden: true,
+			},
+		},
+	}, nil
+}
+
+// faceURL is the card's locally stored image if there is an image store holding it, otherwise its ImageURI
+func (t *TTSExporter) faceURL(card cubes.Card) (string, error) {
+	if t.imageStore != nil && card.ImageRef != "" {
+		path, err := t.imageStore.Path(card.ImageRef)
+		if err != nil {
+			return "", fmt.Errorf(`image path for %s: %w`, card.Name, err)
+		}
+		if _, err := os.Stat(path); err == nil {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return "", fmt.Errorf(`image path for %s: %w`, card.Name, err)
+			}
+			return "file:///" + strings.TrimPrefix(filepath.ToSlash(abs), "/"), nil
+		}
+	}
+	if card.ImageURI == "" {
+		return "", fmt.Errorf(`%s has no image`, card.Name)
+	}
+	return card.ImageURI, nil
+}
+
+// ttsFaceDown is the transform of an object lying face down at x
+func ttsFaceDown(x float64) ttsTransform {
+	return ttsTransform{PosX: x, PosY: 1, RotY: 180, RotZ: 180, ScaleX: 1, ScaleY: 1, ScaleZ: 1}
+}
+
+func writeTTSSave(w io.Writer, save ttsSave) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(save); err != nil {
+		return fmt.Errorf(`write saved object: %w`, err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package cards
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+// ttsCards returns n cards named Card 1 to Card n, each with its own image
+func ttsCards(n int) []cubes.Card {
+	var cardList []cubes.Card
+	for i := 1; i <= n; i++ {
+		cardList = append(cardList, cubes.Card{
+			ID:       fmt.Sprintf("card-%d", i),
+			Name:     fmt.Sprintf("Card %d", i),
+			Type:     "Instant",
+			ImageURI: fmt.Sprintf("https://img.test/%d.jpg", i),
+		})
+	}
+	return cardList
+}
+
+func readTTSSave(t *testing.T, buf *bytes.Buffer) ttsSave {
+	t.Helper()
+	var save ttsSave
+	if err := json.Unmarshal(buf.Bytes(), &save); err != nil {
+		t.Fatalf("unmarshal saved object: %v", err)
+	}
+	return save
+}
+
+func TestTTSWriteCubeChunks(t *testing.T) {
+	cube := cubes.Cube{Name: "Fixture Cube", Cards: ttsCards(5)}
+	var buf bytes.Buffer
+	if err := NewTTSExporter(TTSWithMaxDeckSize(2)).WriteCube(&buf, cube); err != nil {
+		t.Fatalf("write cube: %v", err)
+	}
+	save := readTTSSave(t, &buf)
+
+	if len(save.ObjectStates) != 3 {
+		t.Fatalf("wrote %d objects, want 3", len(save.ObjectStates))
+	}
+	wantNames := []string{"Fixture Cube (1/3)", "Fixture Cube (2/3)", "Card 5"}
+	wantIDs := [][]int{{100, 200}, {300, 400}, {500}}
+	for i, object := range save.ObjectStates {
+		if object.Nickname != wantNames[i] {
+			t.Errorf("object %d is %q, want %q", i, object.Nickname, wantNames[i])
+		}
+		if object.Transform.PosX != float64(i)*ttsDeckSpacing {
+			t.Errorf("object %d is at x %v, want %v", i, object.Transform.PosX, float64(i)*ttsDeckSpacing)
+		}
+		var ids []int
+		if object.Name == "DeckCustom" {
+			ids = object.DeckIDs
+			for j, card := range object.ContainedObjects {
+				if card.CardID != ids[j] {
+					t.Errorf("object %d card %d has ID %d, deck lists %d", i, j, card.CardID, ids[j])
+				}
+			}
+		} else {
+			ids = []int{object.CardID}
+		}
+		if !slices.Equal(ids, wantIDs[i]) {
+			t.Errorf("object %d card IDs = %v, want %v", i, ids, wantIDs[i])
+		}
+		// Every card ID refers to a face sheet of its own
+		for _, id := range ids {
+			faces, ok := object.CustomDeck[fmt.Sprint(id/100)]
+			if !ok || faces.NumWidth != 1 || faces.NumHeight != 1 || faces.BackURL != DefaultTTSCardBack {
+				t.Errorf("object %d sheet %d = %+v, %v", i, id/100, faces, ok)
+			}
+		}
+	}
+	// The last chunk is a lone card rather than a deck, which Tabletop Simulator doesn't allow
+	if last := save.ObjectStates[2]; last.Name != "Card" || len(last.ContainedObjects) != 0 {
+		t.Errorf("last object = %s with %d cards, want a single Card", last.Name, len(last.ContainedObjects))
+	}
+	if face := save.ObjectStates[2].CustomDeck["5"].FaceURL; face != "https://img.test/5.jpg" {
+		t.Errorf("face = %s, want the card's image", face)
+	}
+}
+
+func TestTTSWriteDeck(t *testing.T) {
+	cardList := ttsCards(4)
+	deck := cubes.Deck{Description: "Fixture Tempo", Cards: cardList[:3], Sideboard: cardList[3:]}
+	var buf bytes.Buffer
+	if err := NewTTSExporter(TTSWithCardBack("https://img.test/back.jpg")).WriteDeck(&buf, deck); err != nil {
+		t.Fatalf("write deck: %v", err)
+	}
+	save := readTTSSave(t, &buf)
+
+	if len(save.ObjectStates) != 2 {
+		t.Fatalf("wrote %d objects, want 2", len(save.ObjectStates))
+	}
+	main, side := save.ObjectStates[0], save.ObjectStates[1]
+	if main.Nickname != "Fixture Tempo" || !slices.Equal(main.DeckIDs, []int{100, 200, 300}) {
+		t.Errorf("main = %q %v, want Fixture Tempo [100 200 300]", main.Nickname, main.DeckIDs)
+	}
+	// Sheet IDs carry on into the sideboard so the two can be combined in game
+	if side.Name != "Card" || side.Nickname != "Card 4" || side.CardID != 400 {
+		t.Errorf("sideboard = %s %q %d, want Card 4 with ID 400", side.Name, side.Nickname, side.CardID)
+	}
+	if back := side.CustomDeck["4"].BackURL; back != "https://img.test/back.jpg" {
+		t.Errorf("back = %s, want the configured back", back)
+	}
+}
+
+func TestTTSMaxDeckSizeAtLeastOne(t *testing.T) {
+	cube := cubes.Cube{Name: "Fixture Cube", Cards: ttsCards(2)}
+	for _, size := range []int{0, -1} {
+		var buf bytes.Buffer
+		if err := NewTTSExporter(TTSWithMaxDeckSize(size)).WriteCube(&buf, cube); err != nil {
+			t.Fatalf("write cube with max deck size %d: %v", size, err)
+		}
+		if save := readTTSSave(t, &buf); len(save.ObjectStates) != 2 {
+			t.Errorf("max deck size %d wrote %d objects, want 2", size, len(save.ObjectStates))
+		}
+	}
+}
+
+func TestTTSCardWithoutImage(t *testing.T) {
+	cube := cubes.Cube{Name: "Fixture Cube", Cards: []cubes.Card{{ID: "blank", Name: "Blank"}}}
+	var buf bytes.Buffer
+	if err := NewTTSExporter().WriteCube(&buf, cube); err == nil {
+		t.Error("wrote a card without an image")
+	}
+}
@@ -0,0 +1,105 @@
+package cards
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+// 4 [A25:141] Lightning Bolt, SB: 1 [EMA:43] Counterspell, or without the printing: 4 Lightning Bolt
+var xmageLinePattern = regexp.MustCompile(`^(SB:\s*)?(\d+)\s+(?:\[([A-Za-z0-9]*):([^\]]*)\]\s*)?(.+)$`)
+
+// XMageFormat reads and writes XMage .dck files. Names are resolved the same way as CockatriceFormat. Storage may be
+// nil.
+type XMageFormat struct {
+	storage cubes.Storage
+}
+
+func NewXMageFormat(storage cubes.Storage) *XMageFormat {
+	return &XMageFormat{storage: storage}
+}
+
+// parseXMage reads the deck name and card lines. LAYOUT lines, which only position cards in XMage's deck editor, are
+// skipped.
+func parseXMage(r io.Reader) (string, []DecklistEntry, error) {
+	var name string
+	var entries []DecklistEntry
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "LAYOUT ") {
+			continue
+		}
+		if deckName, ok := strings.CutPrefix(line, "NAME:"); ok {
+			name = strings.TrimSpace(deckName)
+			continue
+		}
+		m := xmageLinePattern.FindStringSubmatch(line)
+		if m == nil {
+			return "", nil, fmt.Errorf(`line %d: unrecognised line %q`, lineNumber, line)
+		}
+		count, err := strconv.Atoi(m[2])
+		if err != nil {
+			return "", nil, fmt.Errorf(`line %d: bad count %q: %w`, lineNumber, m[2], err)
+		}
+		if count == 0 {
+			continue
+		}
+		entries = append(entries, DecklistEntry{
+			Line:            lineNumber,
+			Text:            line,
+			Count:           count,
+			Name:            strings.TrimSpace(m[5]),
+			Set:             strings.ToLower(m[3]),
+			CollectorNumber: m[4],
+			Sideboard:       m[1] != "",
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return "", nil, fmt.Errorf(`read dck: %w`, err)
+	}
+	return name, entries, nil
+}
+
+// ReadDeck resolves an XMage .dck file into the deck. The deck name becomes the description if the deck doesn't have
+// one.
+func (x *XMageFormat) ReadDeck(ctx context.Context, deck cubes.Deck, data []byte) (cubes.Deck, error) {
+	name, entries, err := parseXMage(bytes.NewReader(data))
+	if err != nil {
+		return cubes.Deck{}, err
+	}
+	if deck.Description == "" {
+		deck.Description = name
+	}
+	return resolveEntriesWithStorage(ctx, x.storage, deck, entries)
+}
+
+// WriteDeck writes the deck as an XMage .dck file. Cards are written with their printing as [SET:NUM]. XMage needs
+// both parts, so cards without a stored collector number, and custom cards which have no set, are written by name
+// alone and XMage resolves them to a printing itself.
+func (x *XMageFormat) WriteDeck(w io.Writer, deck cubes.Deck) error {
+	bw := bufio.NewWriter(w)
+	if deck.Description != "" {
+		fmt.Fprintf(bw, "NAME:%s\n", deck.Description)
+	}
+	for _, entry := range deckEntries(deck) {
+		if entry.Sideboard {
+			bw.WriteString("SB: ")
+		}
+		fmt.Fprintf(bw, "%d ", entry.Count)
+		if set := exportSet(entry.Set); set != "" && entry.CollectorNumber != "" {
+			fmt.Fprintf(bw, "[%s:%s] ", set, entry.CollectorNumber)
+		}
+		fmt.Fprintf(bw, "%s\n", entry.Name)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/mgdunn2/cube-datahub/cubes"
+	"github.com/mgdunn2/cube-datahub/cubes/cubedb"
+)
+
+// Prints the curve and colour balance of a cube's mainboard next to what it would be with a candidate board, such as
+// the maybeboard, added in.
+func main() {
+	cubeID := flag.String("cube", "da519447-9b91-4eac-a6d6-8a263f42e093", "cube ID")
+	version := flag.Int("version", -1, "cube version, defaults to the latest")
+	candidates := flag.String("board", cubes.MaybeBoard, "board holding the candidate cards")
+	tag := flag.String("tag", "", "only include cards with this tag")
+	flag.Parse()
+
+	ctx := context.Background()
+	db := mustDb("local")
+	s := cubedb.NewStorage(db)
+
+	var v *int
+	if *version >= 0 {
+		v = version
+	}
+	cube, err := s.GetCube(ctx, *cubeID, v, cubes.MainBoard, *candidates)
+	if err != nil {
+		log.Fatal(fmt.Errorf(`get cube: %w`, err))
+	}
+	if cube == nil {
+		log.Fatalf("cube %s not found", *cubeID)
+	}
+
+	mainboard := cube.Cards
+	candidateCards := cube.Board(*candidates)
+	if *tag != "" {
+		mainboard = cube.CardsWithTag(*tag)
+		candidateCards = cubes.Cube{Cards: candidateCards, Tags: cube.TagsOn(*candidates)}.CardsWithTag(*tag)
+	}
+	before := cubes.Stats(mainboard)
+	after := cubes.Stats(append(slices.Clone(mainboard), candidateCards...))
+
+	fmt.Printf("%s version %d: mainboard vs mainboard + %s (%d candidates)\n\n", cube.Name, cube.VersionNumber, *candidates, len(candidateCards))
+	printRow("Cards", before.Count, after.Count)
+	fmt.Println("\nCurve")
+	for _, mv := range keys(before.Curve, after.Curve) {
+		printRow(fmt.Sprintf("  %d", mv), before.Curve[mv], after.Curve[mv])
+	}
+	fmt.Println("\nColors")
+	for _, c := range []cubes.Color{cubes.White, cubes.Blue, cubes.Black, cubes.Red, cubes.Green} {
+		printRow("  "+string(c), before.Colors[c], after.Colors[c])
+	}
+	printRow("  Colorless", before.Colorless, after.Colorless)
+	printRow("  Multicolor", before.Multicolor, after.Multicolor)
+	fmt.Println("\nTypes")
+	for _, t := range keys(before.Types, after.Types) {
+		printRow("  "+t, before.Types[t], after.Types[t])
+	}
+}
+
+func printRow(label string, before, after int) {
+	fmt.Printf("%-14s %5d -> %5d (%+d)\n", label, before, after, after-before)
+}
+
+func keys[K int | string](a, b map[K]int) []K {
+	var ks []K
+	for k := range a {
+		ks = append(ks, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			ks = append(ks, k)
+		}
+	}
+	sort.Slice(ks, func(i, j int) bool { return ks[i] < ks[j] })
+	return ks
+}
+
+func mustDb(env string) *sqlx.DB {
+	var connectionString string
+	if env == "local" {
+		connectionString = "root@tcp(127.0.0.1:3306)/cubes?parseTime=true&loc=America%2FNew_York"
+	}
+	db, err := sqlx.Open("mysql", connectionString)
+	if err != nil {
+		log.Fatal(fmt.Errorf("connect MySQL: %w", err))
+	}
+	return db
+}
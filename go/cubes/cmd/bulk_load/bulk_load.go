@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/mgdunn2/cube-datahub/cubes/cards"
+	"github.com/mgdunn2/cube-datahub/cubes/cubedb"
+)
+
+// Seeds the cards table from a Scryfall bulk data file, e.g. default-cards or oracle-cards from
+// https://scryfall.com/docs/api/bulk-data, or from an MTGJSON AllPrintings file
+func main() {
+	file := flag.String("file", "", "path to a Scryfall bulk data or MTGJSON JSON file")
+	format := flag.String("format", "scryfall", "format of the file: scryfall or mtgjson")
+	batchSize := flag.Int("batch-size", 1000, "cards upserted per batch")
+	flag.Parse()
+	if *file == "" {
+		log.Fatal("-file is required")
+	}
+
+	ctx := context.Background()
+	db := mustDb("local")
+	storage := cubedb.NewStorage(db)
+	var loader interface {
+		LoadAll(ctx context.Context) (cards.LoadReport, error)
+	}
+	switch *format {
+	case "scryfall":
+		loader = cards.NewScryfallBulkLoader(storage, *file, cards.BulkLoaderWithBatchSize(*batchSize))
+	case "mtgjson":
+		loader = cards.NewMTGJSONLoader(storage, *file, cards.MTGJSONLoaderWithBatchSize(*batchSize))
+	default:
+		log.Fatalf("unknown format %q", *format)
+	}
+	report, err := loader.LoadAll(ctx)
+	if err != nil {
+		log.Fatal(fmt.Errorf(`load bulk file: %w`, err))
+	}
+	fmt.Printf("Loaded %d cards\n", len(report.Found))
+	if len(report.ConversionFailed) > 0 {
+		fmt.Printf("Failed to convert %d cards: %v\n", len(report.ConversionFailed), report.ConversionFailed)
+	}
+}
+
+func mustDb(env string) *sqlx.DB {
+	var connectionString string
+	if env == "local" {
+		connectionString = "root@tcp(127.0.0.1:3306)/cubes?parseTime=true&loc=America%2FNew_York"
+	}
+	db, err := sqlx.Open("mysql", connectionString)
+	if err != nil {
+		log.Fatal(fmt.Errorf("connect MySQL: %w", err))
+	}
+	return db
+}
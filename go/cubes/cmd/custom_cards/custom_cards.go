@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/mgdunn2/cube-datahub/cubes"
+	"github.com/mgdunn2/cube-datahub/cubes/cards"
+	"github.com/mgdunn2/cube-datahub/cubes/cubedb"
+	"github.com/mgdunn2/cube-datahub/cubes/llm"
+)
+
+const usage = `usage: custom_cards <command> [flags]
+
+commands:
+  list     list custom cards next to their source image
+  edit     edit fields of a custom card by hand
+  reread   re-run the LLM read for a custom card and accept or reject the changes
+  verify   mark a custom card as checked by a human
+  dedupe   find custom cards read from the same image and merge them`
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+	ctx := context.Background()
+	db := mustDb("local")
+	s := cubedb.NewStorage(db)
+
+	var err error
+	switch os.Args[1] {
+	case "list":
+		err = list(ctx, s, os.Args[2:])
+	case "edit":
+		err = edit(ctx, s, os.Args[2:])
+	case "reread":
+		err = reread(ctx, s, cubedb.NewLLMCacheStore(db), os.Args[2:])
+	case "verify":
+		err = verify(ctx, s, os.Args[2:])
+	case "dedupe":
+		err = dedupe(ctx, s, os.Args[2:])
+	default:
+		log.Fatal(usage)
+	}
+	if err != nil {
+		log.Fatal(fmt.Errorf(`%s: %w`, os.Args[1], err))
+	}
+}
+
+func list(ctx context.Context, s cubes.Storage, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	unverifiedOnly := fs.Bool("unverified", false, "only list cards that have not been verified")
+	_ = fs.Parse(args)
+
+	customCards, err := s.GetCustomCards(ctx)
+	if err != nil {
+		return fmt.Errorf(`get custom cards: %w`, err)
+	}
+	for _, customCard := range customCards {
+		if *unverifiedOnly && customCard.Verified {
+			continue
+		}
+		status := "unverified"
+		if customCard.Verified {
+			status = "verified"
+		}
+		card := customCard.Card
+		fmt.Printf("%s  %s  [%s]\n", card.ID, card.Name, status)
+		fmt.Printf("    %s  %s\n", manaCost(card), card.TypeLine())
+		fmt.Printf("    image: %s\n", customCard.ImageURL)
+	}
+	return nil
+}
+
+func edit(ctx context.Context, s cubes.Storage, args []string) error {
+	fs := flag.NewFlagSet("edit", flag.ExitOnError)
+	id := fs.String("id", "", "card ID of the custom card")
+	name := fs.String("name", "", "card name")
+	cost := fs.String("mana-cost", "", "mana cost, e.g. {2}{U}")
+	manaValue := fs.Int("mana-value", 0, "mana value")
+	typeLine := fs.String("type-line", "", "type line, e.g. \"Legendary Creature — Elf\"")
+	text := fs.String("text", "", "rules text")
+	power := fs.Int("power", 0, "power")
+	toughness := fs.Int("toughness", 0, "toughness")
+	loyalty := fs.Int("loyalty", 0, "loyalty")
+	defense := fs.Int("defense", 0, "defense")
+	colors := fs.String("colors", "", "colors as WUBRG letters, e.g. WU")
+	verified := fs.Bool("verified", false, "mark the card as checked against its image; otherwise the edit clears the flag")
+	_ = fs.Parse(args)
+
+	customCard, err := mustCustomCard(ctx, s, *id)
+	if err != nil {
+		return err
+	}
+	card := customCard.Card
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			card.Name = *name
+		case "mana-cost":
+			card.ManaCost = cost
+		case "mana-value":
+			card.ManaValue = *manaValue
+		case "type-line":
+			card.SuperType, card.Type, card.SubType = cubes.ParseTypeLine(*typeLine)
+		case "text":
+			card.TextBox = *text
+		case "power":
+			card.Power = *power
+		case "toughness":
+			card.Toughness = *toughness
+		case "loyalty":
+			card.Loyalty = *loyalty
+		case "defense":
+			card.Defense = *defense
+		case "colors":
+			card.Colors = nil
+			for _, c := range strings.ToUpper(*colors) {
+				card.Colors = append(card.Colors, cubes.Color(c))
+			}
+		}
+	})
+
+	diffs := cards.DiffCard(customCard.Card, card)
+	if len(diffs) == 0 {
+		fmt.Println("No changes")
+		return nil
+	}
+	printDiffs(diffs)
+	if err := s.UpsertCards(ctx, []cubes.Card{card}); err != nil {
+		return fmt.Errorf(`upsert card: %w`, err)
+	}
+	// fixing some fields isn't checking the whole card, so the edit only counts as a check when asked to
+	if err := s.SetCustomCardVerified(ctx, card.ID, *verified); err != nil {
+		return fmt.Errorf(`set verified: %w`, err)
+	}
+	fmt.Printf("Saved, verified=%v\n", *verified)
+	return nil
+}
+
+func reread(ctx context.Context, s cubes.Storage, cacheStore llm.CacheStore, args []string) error {
+	fs := flag.NewFlagSet("reread", flag.ExitOnError)
+	id := fs.String("id", "", "card ID of the custom card")
+	yes := fs.Bool("yes", false, "accept the new read without prompting")
+	_ = fs.Parse(args)
+
+	customCard, err := mustCustomCard(ctx, s, *id)
+	if err != nil {
+		return err
+	}
+	// a cached response would just repeat the stored read
+	cfg, err := llm.ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	cfg.CacheStore = cacheStore
+	cfg.CacheBypass = true
+	imageReader, err := llm.New(cfg)
+	if err != nil {
+		return err
+	}
+	ccr := cards.NewLLMCustomCardReader(imageReader)
+	card, err := ccr.ReadCard(ctx, customCard.ImageURL)
+	if err != nil {
+		return fmt.Errorf(`read card: %w`, err)
+	}
+	card.ID = customCard.Card.ID
+
+	diffs := cards.DiffCard(customCard.Card, card)
+	if len(diffs) == 0 {
+		fmt.Println("New read matches the stored card")
+		return nil
+	}
+	printDiffs(diffs)
+	if !*yes && !confirm("Accept new read?") {
+		fmt.Println("Discarded")
+		return nil
+	}
+	if err := s.UpsertCards(ctx, []cubes.Card{card}); err != nil {
+		return fmt.Errorf(`upsert card: %w`, err)
+	}
+	// nobody has checked the new read against the image
+	if err := s.SetCustomCardVerified(ctx, card.ID, false); err != nil {
+		return fmt.Errorf(`clear verified: %w`, err)
+	}
+	fmt.Println("Saved, verified=false")
+	return nil
+}
+
+func verify(ctx context.Context, s cubes.Storage, args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	id := fs.String("id", "", "card ID of the custom card")
+	unverify := fs.Bool("unverify", false, "clear the verified flag instead of setting it")
+	_ = fs.Parse(args)
+
+	customCard, err := mustCustomCard(ctx, s, *id)
+	if err != nil {
+		return err
+	}
+	if err := s.SetCustomCardVerified(ctx, customCard.Card.ID, !*unverify); err != nil {
+		return fmt.Errorf(`set verified: %w`, err)
+	}
+	fmt.Printf("%s verified=%v\n", customCard.Card.Name, !*unverify)
+	return nil
+}
+
+func dedupe(ctx context.Context, s cubes.Storage, args []string) error {
+	fs := flag.NewFlagSet("dedupe", flag.ExitOnError)
+	distance := fs.Int("distance", cards.DefaultCustomCardDistance, "max differing hash bits for two images to match")
+	merge := fs.Bool("merge", false, "merge each group into its kept card after confirming")
+	_ = fs.Parse(args)
+
+	deduper := cards.NewCustomCardDeduper(s, cards.DeduperWithMaxDistance(*distance))
+	hashed, failed, err := deduper.HashMissing(ctx)
+	if err != nil {
+		return fmt.Errorf(`hash images: %w`, err)
+	}
+	if hashed > 0 {
+		fmt.Printf("Hashed %d images\n", hashed)
+	}
+	for imageURL, err := range failed {
+		fmt.Printf("Could not hash %s: %v\n", imageURL, err)
+	}
+
+	groups, err := deduper.FindDuplicates(ctx)
+	if err != nil {
+		return fmt.Errorf(`find duplicates: %w`, err)
+	}
+	if len(groups) == 0 {
+		fmt.Println("No duplicates found")
+		return nil
+	}
+	for _, group := range groups {
+		fmt.Printf("keep   %s  %s  %s\n", group.Keep.Card.ID, group.Keep.Card.Name, group.Keep.ImageURL)
+		mergeIDs := make([]string, 0, len(group.Duplicates))
+		for _, duplicate := range group.Duplicates {
+			fmt.Printf("merge  %s  %s  %s\n", duplicate.Card.ID, duplicate.Card.Name, duplicate.ImageURL)
+			printDiffs(cards.DiffCard(group.Keep.Card, duplicate.Card))
+			mergeIDs = append(mergeIDs, duplicate.Card.ID)
+		}
+		if *merge && confirm("Merge?") {
+			if err := s.MergeCustomCards(ctx, group.Keep.Card.ID, mergeIDs); err != nil {
+				return fmt.Errorf(`merge custom cards: %w`, err)
+			}
+			fmt.Printf("Merged %d cards into %s\n", len(mergeIDs), group.Keep.Card.ID)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func mustCustomCard(ctx context.Context, s cubes.Storage, id string) (*cubes.CustomCard, error) {
+	if id == "" {
+		return nil, fmt.Errorf(`-id is required`)
+	}
+	customCard, err := s.GetCustomCard(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf(`get custom card: %w`, err)
+	}
+	if customCard == nil {
+		return nil, fmt.Errorf(`custom card %s not found`, id)
+	}
+	return customCard, nil
+}
+
+func printDiffs(diffs []cards.FieldDiff) {
+	for _, d := range diffs {
+		fmt.Printf("%s:\n  - %q\n  + %q\n", d.Field, d.Old, d.New)
+	}
+}
+
+// stdin is shared by every prompt so piped answers buffered by one aren't lost to the next
+var stdin = bufio.NewReader(os.Stdin)
+
+func confirm(prompt string) bool {
+	fmt.Printf("%s [y/N] ", prompt)
+	answer, _ := stdin.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func manaCost(card cubes.Card) string {
+	if card.ManaCost == nil {
+		return "-"
+	}
+	return *card.ManaCost
+}
+
+func mustDb(env string) *sqlx.DB {
+	var connectionString string
+	if env == "local" {
+		connectionString = "root@tcp(127.0.0.1:3306)/cubes?parseTime=true&loc=America%2FNew_York"
+	}
+	db, err := sqlx.Open("mysql", connectionString)
+	if err != nil {
+		log.Fatal(fmt.Errorf("connect MySQL: %w", err))
+	}
+	return db
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/mgdunn2/cube-datahub/cubes"
+	"github.com/mgdunn2/cube-datahub/cubes/cubedb"
+)
+
+func main() {
+	cubeID := flag.String("cube", "da519447-9b91-4eac-a6d6-8a263f42e093", "cube ID")
+	to := flag.Int("to", -1, "version to compare to, defaults to the latest")
+	from := flag.Int("from", -1, "version to compare from, defaults to the version before -to")
+	flag.Parse()
+
+	ctx := context.Background()
+	db := mustDb("local")
+	s := cubedb.NewStorage(db)
+
+	var toVersion *int
+	if *to >= 0 {
+		toVersion = to
+	}
+	newCube, err := s.GetCube(ctx, *cubeID, toVersion, cubes.AllBoards)
+	if err != nil {
+		log.Fatal(fmt.Errorf(`get cube: %w`, err))
+	}
+	if newCube == nil {
+		log.Fatalf("cube %s not found", *cubeID)
+	}
+	fromVersion := newCube.VersionNumber - 1
+	if *from >= 0 {
+		fromVersion = *from
+	}
+	if fromVersion < 0 {
+		log.Fatalf("cube %s has no version before %d", *cubeID, newCube.VersionNumber)
+	}
+	oldCube, err := s.GetCube(ctx, *cubeID, &fromVersion, cubes.AllBoards)
+	if err != nil {
+		log.Fatal(fmt.Errorf(`get cube: %w`, err))
+	}
+
+	diff := cubes.DiffCubes(*oldCube, *newCube)
+	fmt.Printf("%s: version %d -> %d\n", newCube.Name, oldCube.VersionNumber, newCube.VersionNumber)
+	if !diff.HasChanges() {
+		fmt.Println("No changes")
+		return
+	}
+	cubes.PrintCubeDiff(os.Stdout, diff)
+}
+
+func mustDb(env string) *sqlx.DB {
+	var connectionString string
+	if env == "local" {
+		connectionString = "root@tcp(127.0.0.1:3306)/cubes?parseTime=true&loc=America%2FNew_York"
+	}
+	db, err := sqlx.Open("mysql", connectionString)
+	if err != nil {
+		log.Fatal(fmt.Errorf("connect MySQL: %w", err))
+	}
+	return db
+}
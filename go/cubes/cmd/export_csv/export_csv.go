@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/mgdunn2/cube-datahub/cubes"
+	"github.com/mgdunn2/cube-datahub/cubes/cards"
+	"github.com/mgdunn2/cube-datahub/cubes/cubedb"
+)
+
+func main() {
+	cubeID := flag.String("cube", "da519447-9b91-4eac-a6d6-8a263f42e093", "cube ID")
+	version := flag.Int("version", -1, "cube version, defaults to the latest")
+	out := flag.String("out", "", "file to write, defaults to stdout")
+	flag.Parse()
+
+	ctx := context.Background()
+	db := mustDb("local")
+	s := cubedb.NewStorage(db)
+
+	var v *int
+	if *version >= 0 {
+		v = version
+	}
+	cube, err := s.GetCube(ctx, *cubeID, v, cubes.MainBoard, cubes.MaybeBoard)
+	if err != nil {
+		log.Fatal(fmt.Errorf(`get cube: %w`, err))
+	}
+	if cube == nil {
+		log.Fatalf("cube %s not found", *cubeID)
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(fmt.Errorf(`create %s: %w`, *out, err))
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := cards.WriteCubeCobraCSV(w, *cube); err != nil {
+		log.Fatal(fmt.Errorf(`write csv: %w`, err))
+	}
+}
+
+func mustDb(env string) *sqlx.DB {
+	var connectionString string
+	if env == "local" {
+		connectionString = "root@tcp(127.0.0.1:3306)/cubes?parseTime=true&loc=America%2FNew_York"
+	}
+	db, err := sqlx.Open("mysql", connectionString)
+	if err != nil {
+		log.Fatal(fmt.Errorf("connect MySQL: %w", err))
+	}
+	return db
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/mgdunn2/cube-datahub/cubes/cards"
+	"github.com/mgdunn2/cube-datahub/cubes/cubedb"
+	"github.com/mgdunn2/cube-datahub/cubes/images"
+)
+
+// Writes a stored cube version in a format that lets it be drafted or played outside the database
+func main() {
+	cubeID := flag.String("cube", "", "cube ID")
+	version := flag.Int("version", -1, "cube version, defaults to the latest")
+	format := flag.String("format", "cockatrice", "cockatrice, draftmancer or tts")
+	out := flag.String("out", "", "file to write, defaults to stdout")
+	includeReal := flag.Bool("include-real", false, "cockatrice: include real cards as well as custom ones")
+	packSize := flag.Int("pack-size", cards.DefaultDraftmancerPackSize, "draftmancer: cards per pack when there are no slots")
+	settingsPath := flag.String("settings", "", "draftmancer: JSON file of slots, layouts and other settings")
+	imageDir := flag.String("image-dir", "", "tts: use images synced to this directory where there are any")
+	maxDeckSize := flag.Int("max-deck-size", cards.DefaultTTSMaxDeckSize, "tts: most cards in one deck object")
+	cardBack := flag.String("card-back", cards.DefaultTTSCardBack, "tts: card back image URL")
+	flag.Parse()
+	if *cubeID == "" {
+		log.Fatal("-cube is required")
+	}
+	if *maxDeckSize <= 0 {
+		log.Fatal("-max-deck-size must be at least 1")
+	}
+
+	ctx := context.Background()
+	db := mustDb("local")
+	s := cubedb.NewStorage(db)
+
+	var v *int
+	if *version >= 0 {
+		v = version
+	}
+	cube, err := s.GetCube(ctx, *cubeID, v)
+	if err != nil {
+		log.Fatal(fmt.Errorf(`get cube: %w`, err))
+	}
+	if cube == nil {
+		log.Fatalf("cube %s not found", *cubeID)
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(fmt.Errorf(`create %s: %w`, *out, err))
+		}
+		defer f.Close()
+		w = f
+	}
+	switch *format {
+	case "cockatrice":
+		err = cards.WriteCockatriceCardDatabase(w, *cube, *includeReal)
+	case "draftmancer":
+		settings := cards.DraftmancerSettings{PackSize: *packSize}
+		if *settingsPath != "" {
+			raw, err := os.ReadFile(*settingsPath)
+			if err != nil {
+				log.Fatal(fmt.Errorf(`read settings: %w`, err))
+			}
+			if err := json.Unmarshal(raw, &settings); err != nil {
+				log.Fatal(fmt.Errorf(`parse settings: %w`, err))
+			}
+		}
+		err = cards.WriteDraftmancerCube(w, *cube, settings)
+	case "tts":
+		opts := []cards.TTSExporterOpts{cards.TTSWithMaxDeckSize(*maxDeckSize), cards.TTSWithCardBack(*cardBack)}
+		if *imageDir != "" {
+			store, err := images.NewFSStore(*imageDir)
+			if err != nil {
+				log.Fatal(err)
+			}
+			opts = append(opts, cards.TTSWithImageStore(store))
+		}
+		err = cards.NewTTSExporter(opts...).WriteCube(w, *cube)
+	default:
+		log.Fatalf("unknown format %q", *format)
+	}
+	if err != nil {
+		log.Fatal(fmt.Errorf(`write %s: %w`, *format, err))
+	}
+}
+
+func mustDb(env string) *sqlx.DB {
+	var connectionString string
+	if env == "local" {
+		connectionString = "root@tcp(127.0.0.1:3306)/cubes?parseTime=true&loc=America%2FNew_York"
+	}
+	db, err := sqlx.Open("mysql", connectionString)
+	if err != nil {
+		log.Fatal(fmt.Errorf("connect MySQL: %w", err))
+	}
+	return db
+}
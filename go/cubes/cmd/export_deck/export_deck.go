@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/mgdunn2/cube-datahub/cubes/cards"
+	"github.com/mgdunn2/cube-datahub/cubes/cubedb"
+)
+
+// Writes a stored deck in a format other clients can import
+func main() {
+	deckID := flag.String("deck", "", "deck to export")
+	format := flag.String("format", "dek", "dek, cockatrice, xmage, forge or tts")
+	out := flag.String("out", "-", "file to write, - for stdout")
+	flag.Parse()
+	if *deckID == "" {
+		log.Fatal("-deck is required")
+	}
+
+	ctx := context.Background()
+	db := mustDb("local")
+	s := cubedb.NewStorage(db)
+	writers := map[string]cards.DeckWriter{
+		"dek":        cards.NewDekFormat(),
+		"cockatrice": cards.NewCockatriceFormat(s),
+		"xmage":      cards.NewXMageFormat(s),
+		"forge":      cards.NewForgeFormat(s),
+		"tts":        cards.NewTTSExporter(),
+	}
+	writer, ok := writers[*format]
+	if !ok {
+		log.Fatalf("unknown format %q", *format)
+	}
+	deck, err := s.GetDeck(ctx, *deckID)
+	if err != nil {
+		log.Fatal(fmt.Errorf(`get deck: %w`, err))
+	}
+	if deck == nil {
+		log.Fatalf("deck %s not found", *deckID)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(fmt.Errorf(`create output: %w`, err))
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := writer.WriteDeck(w, *deck); err != nil {
+		log.Fatal(fmt.Errorf(`write deck: %w`, err))
+	}
+}
+
+func mustDb(env string) *sqlx.DB {
+	var connectionString string
+	if env == "local" {
+		connectionString = "root@tcp(127.0.0.1:3306)/cubes?parseTime=true&loc=America%2FNew_York"
+	}
+	db, err := sqlx.Open("mysql", connectionString)
+	if err != nil {
+		log.Fatal(fmt.Errorf("connect MySQL: %w", err))
+	}
+	return db
+}
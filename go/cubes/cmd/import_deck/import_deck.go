@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/mgdunn2/cube-datahub/cubes"
+	"github.com/mgdunn2/cube-datahub/cubes/cards"
+	"github.com/mgdunn2/cube-datahub/cubes/cubedb"
+)
+
+// Reads a decklist and resolves it against a cube version. The format is picked from the file extension unless -format
+// is given. Unresolved lines are printed with suggestions. With -record the deck is stored for the player, under a new
+// event unless -event is given.
+func main() {
+	cubeID := flag.String("cube", "", "cube the deck was drafted from")
+	version := flag.Int("version", 0, "cube version, defaults to the latest")
+	file := flag.String("file", "-", "decklist file, - for stdin")
+	format := flag.String("format", "", "text, dek, cockatrice, xmage or forge, defaults to the file extension")
+	record := flag.Bool("record", false, "store the deck")
+	playerID := flag.String("player", "", "player the deck belongs to, needed with -record")
+	eventID := flag.String("event", "", "event to record the deck under, defaults to a new event")
+	description := flag.String("description", "", "deck description")
+	flag.Parse()
+	if *cubeID == "" {
+		log.Fatal("-cube is required")
+	}
+	if *record && *playerID == "" {
+		log.Fatal("-player is required with -record")
+	}
+
+	var text []byte
+	var err error
+	if *file == "-" {
+		text, err = io.ReadAll(os.Stdin)
+	} else {
+		text, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		log.Fatal(fmt.Errorf(`read decklist: %w`, err))
+	}
+
+	ctx := context.Background()
+	db := mustDb("local")
+	s := cubedb.NewStorage(db)
+
+	readers := map[string]cards.DeckReader{
+		"text":       cards.NewTextDeckReader(),
+		"dek":        cards.NewDekFormat(),
+		"cockatrice": cards.NewCockatriceFormat(s),
+		"xmage":      cards.NewXMageFormat(s),
+		"forge":      cards.NewForgeFormat(s),
+	}
+	if *format == "" {
+		switch filepath.Ext(*file) {
+		case ".dek":
+			*format = "dek"
+		case ".cod":
+			*format = "cockatrice"
+		case ".dck":
+			*format = "xmage"
+			if cards.IsForgeDeck(text) {
+				*format = "forge"
+			}
+		default:
+			*format = "text"
+		}
+	}
+	reader, ok := readers[*format]
+	if !ok {
+		log.Fatalf("unknown format %q", *format)
+	}
+	var versionNumber *int
+	if *version > 0 {
+		versionNumber = version
+	}
+	cube, err := s.GetCube(ctx, *cubeID, versionNumber, cubes.AllBoards)
+	if err != nil {
+		log.Fatal(fmt.Errorf(`get cube: %w`, err))
+	}
+	if cube == nil {
+		log.Fatalf("cube %s not found", *cubeID)
+	}
+
+	deck := cubes.Deck{
+		ID:          uuid.NewString(),
+		PlayerID:    *playerID,
+		Event:       cubes.Event{ID: *eventID, Cube: *cube, Date: time.Now()},
+		Description: *description,
+	}
+	deck, err = reader.ReadDeck(ctx, deck, text)
+	var unresolved *cards.UnresolvedLinesError
+	if errors.As(err, &unresolved) {
+		for _, line := range unresolved.Lines {
+			fmt.Printf("Line %d unresolved: %s\n", line.Entry.Line, line.Entry.Text)
+			for _, suggestion := range line.Suggestions {
+				fmt.Printf("  did you mean %s?\n", suggestion)
+			}
+		}
+	} else if err != nil {
+		log.Fatal(fmt.Errorf(`read deck: %w`, err))
+	}
+
+	fmt.Printf("Maindeck (%d)\n", len(deck.Cards))
+	for _, card := range deck.Cards {
+		fmt.Println(card.Name)
+	}
+	fmt.Printf("\nSideboard (%d)\n", len(deck.Sideboard))
+	for _, card := range deck.Sideboard {
+		fmt.Println(card.Name)
+	}
+
+	if !*record {
+		return
+	}
+	if unresolved != nil {
+		log.Fatal("not recording a deck with unresolved lines")
+	}
+	if deck.Event.ID == "" {
+		deck.Event.ID = uuid.NewString()
+		if err := s.RecordEvent(ctx, deck.Event); err != nil {
+			log.Fatal(fmt.Errorf(`record event: %w`, err))
+		}
+	}
+	if err := s.RecordDeck(ctx, deck); err != nil {
+		log.Fatal(fmt.Errorf(`record deck: %w`, err))
+	}
+	fmt.Printf("Recorded deck %s\n", deck.ID)
+}
+
+func mustDb(env string) *sqlx.DB {
+	var connectionString string
+	if env == "local" {
+		connectionString = "root@tcp(127.0.0.1:3306)/cubes?parseTime=true&loc=America%2FNew_York"
+	}
+	db, err := sqlx.Open("mysql", connectionString)
+	if err != nil {
+		log.Fatal(fmt.Errorf("connect MySQL: %w", err))
+	}
+	return db
+}
@@ -3,34 +3,149 @@ package main
 import (
 	"context"
 	_ "embed"
+	"flag"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
+	"github.com/mgdunn2/cube-datahub/cubes"
 	"github.com/mgdunn2/cube-datahub/cubes/cards"
 	"github.com/mgdunn2/cube-datahub/cubes/cubedb"
+	"github.com/mgdunn2/cube-datahub/cubes/images"
 	"github.com/mgdunn2/cube-datahub/cubes/llm"
-	"github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
 	"log"
 	"os"
 )
 
 func main() {
+	cubeID := flag.String("cube", "da519447-9b91-4eac-a6d6-8a263f42e093", "CubeCobra cube ID")
+	csvDir := flag.String("csv-dir", "", "load from <csv-dir>/<cube>.csv instead of the CubeCobra API")
+	bulkFile := flag.String("scryfall-bulk", "", "resolve cards from a Scryfall bulk data file instead of the Scryfall API")
+	mtgjsonFile := flag.String("mtgjson", "", "resolve cards from an MTGJSON AllPrintings file instead of the Scryfall API")
+	concurrency := flag.Int("concurrency", 4, "Scryfall batches fetched at once")
+	imageDir := flag.String("image-dir", "", "store newly read custom card images in this directory")
+	noDedupe := flag.Bool("no-dedupe", false, "read new custom card images without comparing them to cards already read")
+	allowPartial := flag.Bool("allow-partial", false, "store the cube even if some cards could not be loaded")
+	planOnly := flag.Bool("plan", false, "print what the load would change without writing anything or reading custom cards")
+	flag.Parse()
+
 	ctx := context.Background()
 	db := mustDb("local")
 	storage := cubedb.NewStorage(db)
-	openAiApiKey := os.Getenv("OPENAI_API_KEY")
-	client := openai.NewClient(option.WithAPIKey(openAiApiKey))
-	imageReader := llm.NewOpenAi(client)
+	imageReader, err := llm.NewFromEnv(cubedb.NewLLMCacheStore(db))
+	if err != nil {
+		log.Fatal(err)
+	}
 	ccr := cards.NewLLMCustomCardReader(imageReader)
-	cardLoader := cards.NewScryfallLoader(storage)
-	cubeLoader := cards.NewCubeCobraLoader(storage, cardLoader, ccr)
-	err := cubeLoader.Load(ctx, "da519447-9b91-4eac-a6d6-8a263f42e093")
+	var cubeLoader interface {
+		cards.CubeLoader
+		cards.CubePlanner
+	}
+	if *csvDir != "" && flagSet("concurrency") {
+		log.Fatal(`-concurrency sets how many Scryfall batches are fetched at once; -csv-dir loads don't call Scryfall`)
+	}
+	loaderOpts := []cards.CubeCobraLoaderOpts{cards.CubeLoaderWithPartialLoadPolicy(func(report cards.LoadReport) error {
+		if len(report.Retried) > 0 {
+			fmt.Printf("Retried %d cards\n", len(report.Retried))
+		}
+		if report.Complete() {
+			return nil
+		}
+		fmt.Printf("Missing cards: %v\nFailed to convert: %v\n", report.Missing, report.ConversionFailed)
+		if *allowPartial {
+			return nil
+		}
+		return cards.RejectPartialLoads(report)
+	})}
+	if *imageDir != "" {
+		store, err := images.NewFSStore(*imageDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		loaderOpts = append(loaderOpts, cards.CubeLoaderWithImageSyncer(images.NewSyncer(store, storage)))
+	}
+	if *noDedupe {
+		loaderOpts = append(loaderOpts, cards.CubeLoaderWithoutDedupe())
+	}
+	if *csvDir != "" {
+		opts := []cards.CubeCobraCSVLoaderOpts{cards.CSVLoaderWithCubeCobraOpts(loaderOpts...)}
+		switch {
+		case *bulkFile != "":
+			opts = append(opts, cards.CSVLoaderWithCardNameLoader(cards.NewScryfallBulkLoader(storage, *bulkFile)))
+		case *mtgjsonFile != "":
+			opts = append(opts, cards.CSVLoaderWithCardNameLoader(cards.NewMTGJSONLoader(storage, *mtgjsonFile)))
+		}
+		cubeLoader = cards.NewCubeCobraCSVLoader(storage, ccr, *csvDir, opts...)
+	} else {
+		var cardLoader cards.CardLoader = cards.NewScryfallLoader(storage, cards.ScryfallLoaderWithConcurrency(*concurrency))
+		switch {
+		case *bulkFile != "":
+			cardLoader = cards.NewScryfallBulkLoader(storage, *bulkFile)
+		case *mtgjsonFile != "":
+			cardLoader = cards.NewMTGJSONLoader(storage, *mtgjsonFile)
+		}
+		cubeLoader = cards.NewCubeCobraLoader(storage, cardLoader, ccr, loaderOpts...)
+	}
+	if *planOnly {
+		plan, err := cubeLoader.Plan(ctx, *cubeID)
+		if err != nil {
+			log.Fatal(fmt.Errorf(`plan load: %w`, err))
+		}
+		printPlan(plan)
+		return
+	}
+	err = cubeLoader.Load(ctx, *cubeID)
 	if err != nil {
 		log.Fatal(fmt.Errorf(`load cube: %w`, err))
 	}
 
 	fmt.Println(`Loaded Cube!`)
+	if cache, ok := imageReader.(*llm.Cache); ok {
+		fmt.Println(cache.Stats())
+	}
+}
+
+// flagSet reports whether the named flag was given on the command line
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func printPlan(plan *cards.LoadPlan) {
+	fmt.Printf("Plan for %s\n", plan.CubeID)
+	fmt.Printf("%d new cards, %d changed cards\n", len(plan.NewCards), len(plan.ChangedCards))
+	for _, card := range plan.NewCards {
+		fmt.Printf("  new %s (%s)\n", card.Name, card.Set)
+	}
+	for _, change := range plan.ChangedCards {
+		fmt.Printf("  changed %s\n", change.Card.Name)
+		for _, d := range change.Diffs {
+			fmt.Printf("    %s: %q -> %q\n", d.Field, d.Old, d.New)
+		}
+	}
+	if !plan.Report.Complete() {
+		fmt.Printf("Missing cards: %v\nFailed to convert: %v\n", plan.Report.Missing, plan.Report.ConversionFailed)
+	}
+
+	fmt.Printf("%d custom cards to read, estimated cost $%.2f\n", len(plan.CustomReads), plan.EstimatedCost)
+	for _, imageURL := range plan.CustomReads {
+		fmt.Printf("  read %s\n", imageURL)
+	}
+	for _, reused := range plan.ReusedCustomCards {
+		fmt.Printf("  reuse %s for %s (distance %d)\n", reused.Card.Name, reused.ImageURL, reused.Distance)
+	}
+
+	diff := plan.Diff
+	if !diff.HasChanges() {
+		fmt.Println("No cube changes")
+		return
+	}
+	fmt.Printf("Version %d would be created\n", plan.VersionNumber)
+	cubes.PrintCubeDiff(os.Stdout, diff)
 }
 
 func mustDb(env string) *sqlx.DB {
@@ -5,19 +5,20 @@ import (
 	_ "embed"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
 	"github.com/mgdunn2/cube-datahub/cubes/cards"
+	"github.com/mgdunn2/cube-datahub/cubes/cubedb"
 	"github.com/mgdunn2/cube-datahub/cubes/llm"
-	"github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
 	"log"
-	"os"
 )
 
 func main() {
 	ctx := context.Background()
-	openAiApiKey := os.Getenv("OPENAI_API_KEY")
-	client := openai.NewClient(option.WithAPIKey(openAiApiKey))
-	imageReader := llm.NewOpenAi(client)
+	db := mustDb("local")
+	imageReader, err := llm.NewFromEnv(cubedb.NewLLMCacheStore(db))
+	if err != nil {
+		log.Panicln(err)
+	}
 	ccr := cards.NewLLMCustomCardReader(imageReader)
 	card, err := ccr.ReadCard(ctx, `https://i.imgur.com/hcbSX8l.png`)
 	if err != nil {
@@ -26,4 +27,19 @@ func main() {
 	fmt.Println(card)
 
 	fmt.Println(`Read Card!`)
+	if cache, ok := imageReader.(*llm.Cache); ok {
+		fmt.Println(cache.Stats())
+	}
+}
+
+func mustDb(env string) *sqlx.DB {
+	var connectionString string
+	if env == "local" {
+		connectionString = "root@tcp(127.0.0.1:3306)/cubes?parseTime=true&loc=America%2FNew_York"
+	}
+	db, err := sqlx.Open("mysql", connectionString)
+	if err != nil {
+		log.Fatal(fmt.Errorf("connect MySQL: %w", err))
+	}
+	return db
 }
@@ -10,24 +10,24 @@ import (
 	"github.com/mgdunn2/cube-datahub/cubes/cards"
 	"github.com/mgdunn2/cube-datahub/cubes/cubedb"
 	"github.com/mgdunn2/cube-datahub/cubes/llm"
-	"github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
 	"io"
 	"log"
 	"net/http"
-	"os"
+	"strings"
 )
 
 func main() {
 	ctx := context.Background()
 	db := mustDb("local")
 	s := cubedb.NewStorage(db)
-	openAiApiKey := os.Getenv("OPENAI_API_KEY")
-	client := openai.NewClient(option.WithAPIKey(openAiApiKey))
-	imageReader := llm.NewOpenAi(client)
-	dr := cards.NewLLMDeckReader(s, imageReader)
+	imageReader, err := llm.NewFromEnv(cubedb.NewLLMCacheStore(db))
+	if err != nil {
+		log.Panicln(err)
+	}
+	dr := cards.NewTiledDeckReader(cards.NewLLMDeckReader(s, imageReader))
 	httpClient := http.Client{}
-	rsp, err := httpClient.Get(`https://media.discordapp.net/attachments/1372322448720007320/1382329190925467729/IMG_0831.jpg?ex=686a65e1&is=68691461&hm=d4abffbdb1ab5392bd1a66f729539fbc8a610a8a2801d246dc58b6b4dcdc90db&=&format=webp&width=1852&height=1390`)
+	// Without a format the proxy serves the original JPEG; webp can't be decoded to tile the photo
+	rsp, err := httpClient.Get(`https://media.discordapp.net/attachments/1372322448720007320/1382329190925467729/IMG_0831.jpg?ex=686a65e1&is=68691461&hm=d4abffbdb1ab5392bd1a66f729539fbc8a610a8a2801d246dc58b6b4dcdc90db&=&width=1852&height=1390`)
 	if err != nil {
 		log.Panicln(fmt.Errorf(`get deck: %w`, err))
 	}
@@ -45,7 +45,7 @@ func main() {
 	d := cubes.Deck{
 		Event: e,
 	}
-	d, err = dr.ReadDeck(ctx, d, bytes)
+	d, report, err := dr.ReadDeckWithReport(ctx, d, bytes)
 	if err != nil {
 		log.Panicln(fmt.Errorf(`read deck: %w`, err))
 	}
@@ -53,7 +53,26 @@ func main() {
 	for _, c := range d.Cards {
 		fmt.Println(c.Name)
 	}
+	if report.TileError != "" {
+		fmt.Printf("Could not tile the photo, read it in a single pass: %s\n", report.TileError)
+	}
+	if report.SinglePassError != "" {
+		fmt.Printf("Read %d tiles (%d failed): %d cards, the whole image failed so there's nothing to compare: %s\n",
+			report.Tiles, report.FailedTiles, report.Total, report.SinglePassError)
+	} else {
+		fmt.Printf("Read %d tiles (%d failed): %d cards, %d from the whole image alone\n",
+			report.Tiles, report.FailedTiles, report.Total, report.SinglePass)
+	}
+	if len(report.Recovered) > 0 {
+		fmt.Printf("Recovered by tiles: %s\n", strings.Join(report.Recovered, ", "))
+	}
+	if len(report.Unmatched) > 0 {
+		fmt.Printf("Not in the cube: %s\n", strings.Join(report.Unmatched, ", "))
+	}
 	fmt.Println("Tada!")
+	if cache, ok := imageReader.(*llm.Cache); ok {
+		fmt.Println(cache.Stats())
+	}
 }
 
 func mustDb(env string) *sqlx.DB {
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/mgdunn2/cube-datahub/cubes/cards"
+	"github.com/mgdunn2/cube-datahub/cubes/cubedb"
+	"github.com/mgdunn2/cube-datahub/cubes/cubesync"
+	"github.com/mgdunn2/cube-datahub/cubes/llm"
+)
+
+// Keeps the cubes listed in a config file up to date until interrupted
+func main() {
+	configPath := flag.String("config", "sync.json", "path to the sync config")
+	flag.Parse()
+
+	cfg, err := cubesync.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db := mustDb("local")
+	storage := cubedb.NewStorage(db)
+	imageReader, err := llm.NewFromEnv(cubedb.NewLLMCacheStore(db))
+	if err != nil {
+		log.Fatal(err)
+	}
+	ccr := cards.NewLLMCustomCardReader(imageReader)
+	cubeCobraLoader := cards.NewCubeCobraLoader(storage, cards.NewScryfallLoader(storage), ccr)
+	loaders := map[string]cards.CubeLoader{
+		cubesync.SourceCubeCobra: cubeCobraLoader,
+	}
+	if cfg.CSVDir != "" {
+		// CSV and CubeCobra cubes can share custom cards, so a new image must only be read once across both
+		loaders[cubesync.SourceCSV] = cards.NewCubeCobraCSVLoader(storage, ccr, cfg.CSVDir,
+			cards.CSVLoaderWithCubeCobraOpts(cards.CubeLoaderWithSharedCustomCards(cubeCobraLoader)))
+	}
+	service, err := cubesync.NewService(storage, loaders, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("syncing %d cubes", len(cfg.Cubes))
+	service.Run(ctx)
+	log.Println("stopped")
+}
+
+func mustDb(env string) *sqlx.DB {
+	var connectionString string
+	if env == "local" {
+		connectionString = "root@tcp(127.0.0.1:3306)/cubes?parseTime=true&loc=America%2FNew_York"
+	}
+	db, err := sqlx.Open("mysql", connectionString)
+	if err != nil {
+		log.Fatal(fmt.Errorf("connect MySQL: %w", err))
+	}
+	return db
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/mgdunn2/cube-datahub/cubes"
+	"github.com/mgdunn2/cube-datahub/cubes/cubedb"
+	"github.com/mgdunn2/cube-datahub/cubes/images"
+)
+
+// Downloads card images into a local content-addressed store. With -cube only that cube's cards are synced, and images
+// missing from the store are fetched again; otherwise every stored card without an image is synced.
+func main() {
+	dir := flag.String("dir", "images", "directory to store images in")
+	cubeID := flag.String("cube", "", "only sync the cards in this cube's latest version")
+	concurrency := flag.Int("concurrency", 4, "images downloaded at once")
+	flag.Parse()
+
+	ctx := context.Background()
+	db := mustDb("local")
+	storage := cubedb.NewStorage(db)
+	store, err := images.NewFSStore(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	syncer := images.NewSyncer(store, storage, images.SyncerWithConcurrency(*concurrency))
+
+	var synced, alreadySynced, failed int
+	sync := func(cards []cubes.Card) {
+		report, err := syncer.Sync(ctx, cards)
+		if err != nil {
+			log.Fatal(fmt.Errorf(`sync images: %w`, err))
+		}
+		synced += len(report.Synced)
+		alreadySynced += len(report.AlreadySynced)
+		failed += len(report.Failed)
+		for id, err := range report.Failed {
+			fmt.Printf("Failed %s: %v\n", id, err)
+		}
+	}
+
+	if *cubeID != "" {
+		cube, err := storage.GetCube(ctx, *cubeID, nil, cubes.AllBoards)
+		if err != nil {
+			log.Fatal(fmt.Errorf(`get cube: %w`, err))
+		}
+		if cube == nil {
+			log.Fatalf("cube %s not found", *cubeID)
+		}
+		cards := cube.Cards
+		for _, board := range cube.Boards {
+			cards = append(cards, board...)
+		}
+		sync(cards)
+	} else {
+		const pageSize = 500
+		afterID := ""
+		for {
+			cards, err := storage.GetCardsMissingImageRef(ctx, afterID, pageSize)
+			if err != nil {
+				log.Fatal(fmt.Errorf(`get cards: %w`, err))
+			}
+			if len(cards) == 0 {
+				break
+			}
+			sync(cards)
+			afterID = cards[len(cards)-1].ID
+		}
+	}
+	fmt.Printf("Synced %d images, %d already stored, %d failed\n", synced, alreadySynced, failed)
+}
+
+func mustDb(env string) *sqlx.DB {
+	var connectionString string
+	if env == "local" {
+		connectionString = "root@tcp(127.0.0.1:3306)/cubes?parseTime=true&loc=America%2FNew_York"
+	}
+	db, err := sqlx.Open("mysql", connectionString)
+	if err != nil {
+		log.Fatal(fmt.Errorf("connect MySQL: %w", err))
+	}
+	return db
+}
@@ -0,0 +1,68 @@
+package cubedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mgdunn2/cube-datahub/cubes/llm"
+)
+
+// LLMCacheStore keeps cached LLM responses in the llm_cache table
+type LLMCacheStore struct {
+	db *sqlx.DB
+}
+
+func NewLLMCacheStore(db *sqlx.DB) *LLMCacheStore {
+	return &LLMCacheStore{db: db}
+}
+
+func (s *LLMCacheStore) GetCacheEntry(ctx context.Context, key string) (*llm.CacheEntry, error) {
+	var row struct {
+		Key       string    `db:"key"`
+		Model     string    `db:"model"`
+		Tool      string    `db:"tool"`
+		Response  string    `db:"response"`
+		CreatedAt time.Time `db:"createdAt"`
+		Hits      int       `db:"hits"`
+		Misses    int       `db:"misses"`
+	}
+	query := "SELECT `key`, model, tool, response, createdAt, hits, misses FROM llm_cache WHERE `key` = ?"
+	if err := s.db.GetContext(ctx, &row, query, key); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf(`get llm cache entry: %w`, err)
+	}
+	return &llm.CacheEntry{
+		Key:       row.Key,
+		Model:     row.Model,
+		Tool:      row.Tool,
+		Response:  row.Response,
+		CreatedAt: row.CreatedAt,
+		Hits:      row.Hits,
+		Misses:    row.Misses,
+	}, nil
+}
+
+func (s *LLMCacheStore) PutCacheEntry(ctx context.Context, entry llm.CacheEntry) error {
+	query := "INSERT INTO llm_cache (`key`, model, tool, response, createdAt, hits, misses) VALUES (?, ?, ?, ?, ?, 0, ?)" + `
+ON DUPLICATE KEY UPDATE model=VALUES(model), tool=VALUES(tool), response=VALUES(response), createdAt=VALUES(createdAt),
+	hits=0, lastHitAt=NULL, misses=misses+VALUES(misses)`
+	_, err := s.db.ExecContext(ctx, query, entry.Key, entry.Model, entry.Tool, entry.Response, entry.CreatedAt, entry.Misses)
+	if err != nil {
+		return fmt.Errorf(`insert llm cache entry: %w`, err)
+	}
+	return nil
+}
+
+func (s *LLMCacheStore) RecordCacheHit(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE llm_cache SET hits = hits + 1, lastHitAt = ? WHERE `key` = ?", time.Now(), key)
+	if err != nil {
+		return fmt.Errorf(`record llm cache hit: %w`, err)
+	}
+	return nil
+}
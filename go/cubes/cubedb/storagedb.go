@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -22,22 +23,25 @@ func NewStorage(db *sqlx.DB) cubes.Storage {
 }
 
 type dbCard struct {
-	ID          string         `db:"id"`
-	Name        string         `db:"name"`
-	ManaCost    sql.NullString `db:"mana_cost"`
-	ManaValue   sql.NullInt64  `db:"mana_value"`
-	Type        string         `db:"type"`
-	SuperType   sql.NullString `db:"super_type"`
-	SubType     sql.NullString `db:"sub_type"`
-	TextBox     string         `db:"text_box"`
-	Power       sql.NullInt64  `db:"power"`
-	Toughness   sql.NullInt64  `db:"toughness"`
-	Loyalty     sql.NullInt64  `db:"loyalty"`
-	Defense     sql.NullInt64  `db:"defense"`
-	Colors      sql.NullString `db:"colors"`
-	Set         string         `db:"exp"`
-	ReleaseDate time.Time      `db:"release_date"`
-	ImageURL    string         `db:"image_url"`
+	ID              string         `db:"id"`
+	Name            string         `db:"name"`
+	ManaCost        sql.NullString `db:"mana_cost"`
+	ManaValue       sql.NullInt64  `db:"mana_value"`
+	Type            string         `db:"type"`
+	SuperType       sql.NullString `db:"super_type"`
+	SubType         sql.NullString `db:"sub_type"`
+	TextBox         string         `db:"text_box"`
+	Power           sql.NullInt64  `db:"power"`
+	Toughness       sql.NullInt64  `db:"toughness"`
+	Loyalty         sql.NullInt64  `db:"loyalty"`
+	Defense         sql.NullInt64  `db:"defense"`
+	Colors          sql.NullString `db:"colors"`
+	Set             string         `db:"exp"`
+	ReleaseDate     time.Time      `db:"release_date"`
+	ImageURL        string         `db:"image_url"`
+	ImageRef        sql.NullString `db:"image_ref"`
+	MTGOID          sql.NullInt64  `db:"mtgo_id"`
+	CollectorNumber sql.NullString `db:"collector_number"`
 }
 
 type dbPlayer struct {
@@ -60,7 +64,17 @@ type dbCubeVersion struct {
 type dbCubeCard struct {
 	CubeID        string `db:"cubeId"`
 	VersionNumber int    `db:"versionNumber"`
+	Board         string `db:"board"`
 	CardID        string `db:"cardId"`
+	Count         int    `db:"count"`
+}
+
+type dbCubeCardTag struct {
+	CubeID        string `db:"cubeId"`
+	VersionNumber int    `db:"versionNumber"`
+	Board         string `db:"board"`
+	CardID        string `db:"cardId"`
+	Tag           string `db:"tag"`
 }
 
 type dbDeck struct {
@@ -72,12 +86,23 @@ type dbDeck struct {
 
 type dbDeckCard struct {
 	DeckID string `db:"deckId"`
+	Board  string `db:"board"`
 	CardID string `db:"cardId"`
+	Count  int    `db:"count"`
+}
+
+type dbEvent struct {
+	ID            string    `db:"id"`
+	CubeID        string    `db:"cubeId"`
+	VersionNumber int       `db:"versionNumber"`
+	EventDate     time.Time `db:"eventDate"`
 }
 
 type dbCustomCard struct {
-	ImageURL string `db:"imageUrl"`
-	CardID   string `db:"cardId"`
+	ImageURL string           `db:"imageUrl"`
+	CardID   string           `db:"cardId"`
+	Verified bool             `db:"verified"`
+	PHash    sql.Null[uint64] `db:"phash"`
 }
 
 // --- Conversion Helpers ---
@@ -88,22 +113,25 @@ func cardToDB(c cubes.Card) (*dbCard, error) {
 	colors, _ := json.Marshal(c.Colors)
 
 	return &dbCard{
-		ID:          c.ID,
-		Name:        c.Name,
-		ManaCost:    nullString(c.ManaCost),
-		ManaValue:   nullInt(c.ManaValue),
-		Type:        c.Type,
-		SuperType:   nullJSONString(superType),
-		SubType:     nullJSONString(subType),
-		TextBox:     c.TextBox,
-		Power:       nullInt(c.Power),
-		Toughness:   nullInt(c.Toughness),
-		Loyalty:     nullInt(c.Loyalty),
-		Defense:     nullInt(c.Defense),
-		Colors:      nullJSONString(colors),
-		Set:         c.Set,
-		ReleaseDate: c.ReleaseDate,
-		ImageURL:    c.ImageURI,
+		ID:              c.ID,
+		Name:            c.Name,
+		ManaCost:        nullString(c.ManaCost),
+		ManaValue:       nullInt(c.ManaValue),
+		Type:            c.Type,
+		SuperType:       nullJSONString(superType),
+		SubType:         nullJSONString(subType),
+		TextBox:         c.TextBox,
+		Power:           nullInt(c.Power),
+		Toughness:       nullInt(c.Toughness),
+		Loyalty:         nullInt(c.Loyalty),
+		Defense:         nullInt(c.Defense),
+		Colors:          nullJSONString(colors),
+		Set:             c.Set,
+		ReleaseDate:     c.ReleaseDate,
+		ImageURL:        c.ImageURI,
+		ImageRef:        sql.NullString{Valid: c.ImageRef != "", String: c.ImageRef},
+		MTGOID:          sql.NullInt64{Valid: c.MTGOID != 0, Int64: int64(c.MTGOID)},
+		CollectorNumber: sql.NullString{Valid: c.CollectorNumber != "", String: c.CollectorNumber},
 	}, nil
 }
 
@@ -120,22 +148,25 @@ func dbToCard(c dbCard) (cubes.Card, error) {
 	}
 
 	return cubes.Card{
-		ID:          c.ID,
-		Name:        c.Name,
-		ManaCost:    manaCost,
-		ManaValue:   intOrZero(c.ManaValue),
-		Type:        c.Type,
-		SuperType:   superType,
-		SubType:     subType,
-		TextBox:     c.TextBox,
-		Power:       intOrZero(c.Power),
-		Toughness:   intOrZero(c.Toughness),
-		Loyalty:     intOrZero(c.Loyalty),
-		Defense:     intOrZero(c.Defense),
-		Colors:      colors,
-		Set:         c.Set,
-		ReleaseDate: c.ReleaseDate,
-		ImageURI:    c.ImageURL,
+		ID:              c.ID,
+		Name:            c.Name,
+		ManaCost:        manaCost,
+		ManaValue:       intOrZero(c.ManaValue),
+		Type:            c.Type,
+		SuperType:       superType,
+		SubType:         subType,
+		TextBox:         c.TextBox,
+		Power:           intOrZero(c.Power),
+		Toughness:       intOrZero(c.Toughness),
+		Loyalty:         intOrZero(c.Loyalty),
+		Defense:         intOrZero(c.Defense),
+		Colors:          colors,
+		Set:             c.Set,
+		ReleaseDate:     c.ReleaseDate,
+		ImageURI:        c.ImageURL,
+		ImageRef:        c.ImageRef.String,
+		MTGOID:          intOrZero(c.MTGOID),
+		CollectorNumber: c.CollectorNumber.String,
 	}, nil
 }
 
@@ -277,7 +308,7 @@ func (s *storage) upsertCardBatch(ctx context.Context, tx *sql.Tx, cards []cubes
 		}
 
 		// Append placeholders for one row
-		valueStrings = append(valueStrings, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		valueStrings = append(valueStrings, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
 
 		// Add all fields in order
 		args = append(args,
@@ -297,26 +328,103 @@ func (s *storage) upsertCardBatch(ctx context.Context, tx *sql.Tx, cards []cubes
 			dbCard.Set,
 			dbCard.ReleaseDate,
 			dbCard.ImageURL,
+			dbCard.ImageRef,
+			dbCard.MTGOID,
+			dbCard.CollectorNumber,
 		)
 	}
 
+	// A stored image is kept unless the card's image URL changed, in which case it is stale. MySQL assigns left to right,
+	// so image_ref has to be compared against the old image_url before image_url is updated.
 	stmt := `
 INSERT INTO cards (
 	id, name, mana_cost, mana_value, type, super_type, sub_type, text_box,
-	power, toughness, loyalty, defense, colors, exp, release_date, image_url
+	power, toughness, loyalty, defense, colors, exp, release_date, image_url, image_ref, mtgo_id,
+	collector_number
 ) VALUES ` + strings.Join(valueStrings, ",") + `
 ON DUPLICATE KEY UPDATE
 	name=VALUES(name), mana_cost=VALUES(mana_cost), mana_value=VALUES(mana_value),
 	type=VALUES(type), super_type=VALUES(super_type), sub_type=VALUES(sub_type), text_box=VALUES(text_box),
 	power=VALUES(power), toughness=VALUES(toughness), loyalty=VALUES(loyalty),
 	defense=VALUES(defense), colors=VALUES(colors), exp=VALUES(exp), release_date=VALUES(release_date),
-    image_url=VALUES(image_url)
+	image_ref=IF(VALUES(image_url) <=> image_url, COALESCE(VALUES(image_ref), image_ref), VALUES(image_ref)),
+	image_url=VALUES(image_url), mtgo_id=VALUES(mtgo_id), collector_number=VALUES(collector_number)
 `
 
 	_, err := tx.ExecContext(ctx, stmt, args...)
 	return err
 }
 
+func (s *storage) GetCardsMissingImageRef(ctx context.Context, afterID string, limit int) ([]cubes.Card, error) {
+	var dbs []dbCard
+	err := s.db.SelectContext(ctx, &dbs,
+		`SELECT * FROM cards WHERE image_ref IS NULL AND image_url != '' AND id > ? ORDER BY id LIMIT ?`,
+		afterID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf(`select cards: %w`, err)
+	}
+	cards := make([]cubes.Card, 0, len(dbs))
+	for _, dc := range dbs {
+		card, err := dbToCard(dc)
+		if err != nil {
+			return nil, fmt.Errorf("dbToCard: %w", err)
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+func (s *storage) SetCardImageRef(ctx context.Context, cardID, imageRef string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE cards SET image_ref = ? WHERE id = ?`, imageRef, cardID)
+	return err
+}
+
+func (s *storage) GetCachedCardIDs(ctx context.Context, names []string) (map[string]string, error) {
+	cardIDs := make(map[string]string)
+	if len(names) == 0 {
+		return cardIDs, nil
+	}
+	lowered := make([]string, 0, len(names))
+	for _, name := range names {
+		lowered = append(lowered, strings.ToLower(name))
+	}
+	query, args, err := sqlx.In(`SELECT name, cardId FROM card_name_cache WHERE name IN (?)`, lowered)
+	if err != nil {
+		return nil, err
+	}
+	query = s.db.Rebind(query)
+	var rows []struct {
+		Name   string `db:"name"`
+		CardID string `db:"cardId"`
+	}
+	if err := s.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf(`select card name cache: %w`, err)
+	}
+	for _, r := range rows {
+		cardIDs[r.Name] = r.CardID
+	}
+	return cardIDs, nil
+}
+
+func (s *storage) CacheCardIDs(ctx context.Context, cardIDsByName map[string]string) error {
+	if len(cardIDsByName) == 0 {
+		return nil
+	}
+	valueStrings := make([]string, 0, len(cardIDsByName))
+	args := make([]interface{}, 0, len(cardIDsByName)*2)
+	for name, cardID := range cardIDsByName {
+		valueStrings = append(valueStrings, "(?, ?)")
+		args = append(args, strings.ToLower(name), cardID)
+	}
+	stmt := `INSERT INTO card_name_cache (name, cardId) VALUES ` + strings.Join(valueStrings, ",") + `
+ON DUPLICATE KEY UPDATE cardId=VALUES(cardId)`
+	if _, err := s.db.ExecContext(ctx, stmt, args...); err != nil {
+		return fmt.Errorf(`insert card name cache: %w`, err)
+	}
+	return nil
+}
+
 func (s *storage) AddCustomCard(ctx context.Context, imageURL, cardID string) error {
 	query := `
 INSERT IGNORE INTO custom_cards (imageUrl, cardId) VALUES (?, ?)`
@@ -346,6 +454,147 @@ SELECT imageUrl, cardId FROM custom_cards`
 	return customMapping, nil
 }
 
+func (s *storage) GetCustomCards(ctx context.Context) ([]cubes.CustomCard, error) {
+	var rows []dbCustomCard
+	err := s.db.SelectContext(ctx, &rows, `SELECT imageUrl, cardId, verified, phash FROM custom_cards ORDER BY cardId`)
+	if err != nil {
+		return nil, fmt.Errorf(`select custom cards: %w`, err)
+	}
+	return s.toCustomCards(ctx, rows)
+}
+
+func (s *storage) GetCustomCard(ctx context.Context, cardID string) (*cubes.CustomCard, error) {
+	var rows []dbCustomCard
+	err := s.db.SelectContext(ctx, &rows, `SELECT imageUrl, cardId, verified, phash FROM custom_cards WHERE cardId = ? LIMIT 1`, cardID)
+	if err != nil {
+		return nil, fmt.Errorf(`select custom card: %w`, err)
+	}
+	customCards, err := s.toCustomCards(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(customCards) == 0 {
+		return nil, nil
+	}
+	return &customCards[0], nil
+}
+
+func (s *storage) toCustomCards(ctx context.Context, rows []dbCustomCard) ([]cubes.CustomCard, error) {
+	cardIDs := make([]string, 0, len(rows))
+	for _, r := range rows {
+		cardIDs = append(cardIDs, r.CardID)
+	}
+	cards, err := s.GetByIDs(ctx, cardIDs)
+	if err != nil {
+		return nil, fmt.Errorf(`get custom card details: %w`, err)
+	}
+	cardsByID := make(map[string]cubes.Card, len(cards))
+	for _, card := range cards {
+		cardsByID[card.ID] = card
+	}
+	customCards := make([]cubes.CustomCard, 0, len(rows))
+	for _, r := range rows {
+		card, ok := cardsByID[r.CardID]
+		if !ok {
+			continue
+		}
+		customCard := cubes.CustomCard{
+			ImageURL: r.ImageURL,
+			Card:     card,
+			Verified: r.Verified,
+		}
+		if r.PHash.Valid {
+			customCard.PHash = &r.PHash.V
+		}
+		customCards = append(customCards, customCard)
+	}
+	return customCards, nil
+}
+
+func (s *storage) SetCustomCardVerified(ctx context.Context, cardID string, verified bool) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE custom_cards SET verified = ? WHERE cardId = ?`, verified, cardID)
+	if err != nil {
+		return fmt.Errorf(`update custom card verified: %w`, err)
+	}
+	return nil
+}
+
+func (s *storage) SetCustomCardPHash(ctx context.Context, imageURL string, phash uint64) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE custom_cards SET phash = ? WHERE imageUrl = ?`, phash, imageURL)
+	if err != nil {
+		return fmt.Errorf(`update custom card phash: %w`, err)
+	}
+	return nil
+}
+
+func (s *storage) MergeCustomCards(ctx context.Context, keepID string, mergeIDs []string) error {
+	mergeIDs = slices.DeleteFunc(slices.Clone(mergeIDs), func(id string) bool { return id == keepID })
+	if len(mergeIDs) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf(`begin txn: %w`, err)
+	}
+	defer tx.Rollback()
+
+	exec := func(query string, args ...any) error {
+		query, args, err := sqlx.In(query, args...)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, tx.Rebind(query), args...)
+		return err
+	}
+
+	// The merged card is verified if any of its copies were
+	var verified bool
+	query, args, err := sqlx.In(`SELECT COALESCE(MAX(verified), 0) FROM custom_cards WHERE cardId IN (?)`, append([]string{keepID}, mergeIDs...))
+	if err != nil {
+		return err
+	}
+	if err := tx.GetContext(ctx, &verified, tx.Rebind(query), args...); err != nil {
+		return fmt.Errorf(`select custom card verified: %w`, err)
+	}
+
+	steps := []struct {
+		name  string
+		query string
+		args  []any
+	}{
+		{"custom cards", `UPDATE custom_cards SET cardId = ? WHERE cardId IN (?)`, []any{keepID, mergeIDs}},
+		{"custom cards verified", `UPDATE custom_cards SET verified = ? WHERE cardId = ?`, []any{verified, keepID}},
+		// A cube version holding both copies ends up with one entry whose count is their sum
+		{"cube cards", `
+INSERT INTO cube_cards (cubeId, versionNumber, board, cardId, count)
+SELECT cubeId, versionNumber, board, ?, SUM(count) FROM cube_cards WHERE cardId IN (?)
+GROUP BY cubeId, versionNumber, board
+ON DUPLICATE KEY UPDATE count = count + VALUES(count)`, []any{keepID, mergeIDs}},
+		{"old cube cards", `DELETE FROM cube_cards WHERE cardId IN (?)`, []any{mergeIDs}},
+		{"cube card tags", `
+INSERT IGNORE INTO cube_card_tags (cubeId, versionNumber, board, cardId, tag)
+SELECT cubeId, versionNumber, board, ?, tag FROM cube_card_tags WHERE cardId IN (?)`, []any{keepID, mergeIDs}},
+		{"old cube card tags", `DELETE FROM cube_card_tags WHERE cardId IN (?)`, []any{mergeIDs}},
+		{"deck cards", `
+INSERT INTO deck_cards (deckId, board, cardId, count)
+SELECT deckId, board, ?, SUM(count) FROM deck_cards WHERE cardId IN (?)
+GROUP BY deckId, board
+ON DUPLICATE KEY UPDATE count = count + VALUES(count)`, []any{keepID, mergeIDs}},
+		{"old deck cards", `DELETE FROM deck_cards WHERE cardId IN (?)`, []any{mergeIDs}},
+		{"card name cache", `UPDATE card_name_cache SET cardId = ? WHERE cardId IN (?)`, []any{keepID, mergeIDs}},
+		{"cards", `DELETE FROM cards WHERE id IN (?)`, []any{mergeIDs}},
+	}
+	for _, step := range steps {
+		if err := exec(step.query, step.args...); err != nil {
+			return fmt.Errorf(`merge %s: %w`, step.name, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf(`commit txn: %w`, err)
+	}
+	return nil
+}
+
 func (s *storage) UpdateCube(ctx context.Context, cube cubes.Cube) error {
 	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
@@ -367,28 +616,59 @@ func (s *storage) UpdateCube(ctx context.Context, cube cubes.Cube) error {
 		return fmt.Errorf(`insert cube version: %w`, err)
 	}
 
-	if len(cube.Cards) > 0 {
+	boards := map[string][]cubes.Card{cubes.MainBoard: cube.Cards}
+	for board, cards := range cube.Boards {
+		if board != cubes.MainBoard {
+			boards[board] = cards
+		}
+	}
+	var valueStrings []string
+	var args []interface{}
+	for board, cards := range boards {
 		counts := make(map[string]int)
-		for _, card := range cube.Cards {
+		for _, card := range cards {
 			counts[card.ID]++
 		}
-
-		valueStrings := make([]string, 0, len(counts))
-		args := make([]interface{}, 0, len(counts)*4)
 		for cardID, count := range counts {
-			valueStrings = append(valueStrings, "(?, ?, ?, ?)")
-			args = append(args, cube.ID, cube.VersionNumber, cardID, count)
+			valueStrings = append(valueStrings, "(?, ?, ?, ?, ?)")
+			args = append(args, cube.ID, cube.VersionNumber, board, cardID, count)
 		}
-
-		stmt := `INSERT INTO cube_cards (cubeId, versionNumber, cardId, count) VALUES ` + strings.Join(valueStrings, ",")
+	}
+	if len(valueStrings) > 0 {
+		stmt := `INSERT INTO cube_cards (cubeId, versionNumber, board, cardId, count) VALUES ` + strings.Join(valueStrings, ",")
 		if _, err := tx.ExecContext(ctx, stmt, args...); err != nil {
 			return fmt.Errorf(`insert cube cards batch: %w`, err)
 		}
 	}
+
+	var tagValueStrings []string
+	var tagArgs []interface{}
+	for board := range boards {
+		for cardID, tags := range cube.TagsOn(board) {
+			seen := make(map[string]struct{}, len(tags))
+			for _, tag := range tags {
+				if _, ok := seen[tag]; ok {
+					continue
+				}
+				seen[tag] = struct{}{}
+				tagValueStrings = append(tagValueStrings, "(?, ?, ?, ?, ?)")
+				tagArgs = append(tagArgs, cube.ID, cube.VersionNumber, board, cardID, tag)
+			}
+		}
+	}
+	if len(tagValueStrings) > 0 {
+		stmt := `INSERT INTO cube_card_tags (cubeId, versionNumber, board, cardId, tag) VALUES ` + strings.Join(tagValueStrings, ",")
+		if _, err := tx.ExecContext(ctx, stmt, tagArgs...); err != nil {
+			return fmt.Errorf(`insert cube card tags batch: %w`, err)
+		}
+	}
 	return tx.Commit()
 }
 
-func (s *storage) GetCube(ctx context.Context, id string, version *int) (*cubes.Cube, error) {
+func (s *storage) GetCube(ctx context.Context, id string, version *int, boards ...string) (*cubes.Cube, error) {
+	if len(boards) == 0 {
+		boards = []string{cubes.MainBoard}
+	}
 	var v int
 	if version == nil {
 		err := s.db.GetContext(ctx, &v, `SELECT maxVersion FROM cubes WHERE id = ?`, id)
@@ -413,16 +693,81 @@ func (s *storage) GetCube(ctx context.Context, id string, version *int) (*cubes.
 		return nil, fmt.Errorf(`get cube: %w`, err)
 	}
 
-	var cardIDs []string
-	err = s.db.SelectContext(ctx, &cardIDs, `SELECT cardId FROM cube_cards WHERE cubeId = ? AND versionNumber = ?`, id, *version)
+	var cubeCards []dbCubeCard
+	query := `SELECT cubeId, versionNumber, board, cardId, count FROM cube_cards WHERE cubeId = ? AND versionNumber = ?`
+	args := []any{id, *version}
+	if !slices.Contains(boards, cubes.AllBoards) {
+		query, args, err = sqlx.In(query+` AND board IN (?)`, id, *version, boards)
+		if err != nil {
+			return nil, fmt.Errorf(`build cube card query: %w`, err)
+		}
+		query = s.db.Rebind(query)
+	}
+	err = s.db.SelectContext(ctx, &cubeCards, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf(`get cube card IDs: %w`, err)
 	}
 
-	cards, err := s.GetByIDs(ctx, cardIDs)
+	var cardIDs []string
+	for _, cc := range cubeCards {
+		cardIDs = append(cardIDs, cc.CardID)
+	}
+	cardList, err := s.GetByIDs(ctx, cardIDs)
 	if err != nil {
 		return nil, fmt.Errorf(`get cards: %w`, err)
 	}
+	cardsByID := make(map[string]cubes.Card, len(cardList))
+	for _, card := range cardList {
+		cardsByID[card.ID] = card
+	}
+	var cards []cubes.Card
+	var otherBoards map[string][]cubes.Card
+	for _, cc := range cubeCards {
+		card, ok := cardsByID[cc.CardID]
+		if !ok {
+			continue
+		}
+		for range cc.Count {
+			if cc.Board == cubes.MainBoard {
+				cards = append(cards, card)
+				continue
+			}
+			if otherBoards == nil {
+				otherBoards = make(map[string][]cubes.Card)
+			}
+			otherBoards[cc.Board] = append(otherBoards[cc.Board], card)
+		}
+	}
+
+	var tagRows []dbCubeCardTag
+	query = `SELECT * FROM cube_card_tags WHERE cubeId = ? AND versionNumber = ?`
+	args = []any{id, *version}
+	if !slices.Contains(boards, cubes.AllBoards) {
+		query, args, err = sqlx.In(query+` AND board IN (?)`, id, *version, boards)
+		if err != nil {
+			return nil, fmt.Errorf(`build cube card tag query: %w`, err)
+		}
+		query = s.db.Rebind(query)
+	}
+	err = s.db.SelectContext(ctx, &tagRows, query+` ORDER BY tag`, args...)
+	if err != nil {
+		return nil, fmt.Errorf(`get cube card tags: %w`, err)
+	}
+	tags := make(map[string][]string)
+	var boardTags map[string]map[string][]string
+	for _, r := range tagRows {
+		if r.Board == cubes.MainBoard {
+			tags[r.CardID] = append(tags[r.CardID], r.Tag)
+			continue
+		}
+		if boardTags == nil {
+			boardTags = make(map[string]map[string][]string)
+		}
+		if boardTags[r.Board] == nil {
+			boardTags[r.Board] = make(map[string][]string)
+		}
+		boardTags[r.Board][r.CardID] = append(boardTags[r.Board][r.CardID], r.Tag)
+	}
 
 	return &cubes.Cube{
 		ID:            id,
@@ -430,9 +775,42 @@ func (s *storage) GetCube(ctx context.Context, id string, version *int) (*cubes.
 		VersionNumber: *version,
 		Date:          cv.Date,
 		Cards:         cards,
+		Boards:        otherBoards,
+		Tags:          tags,
+		BoardTags:     boardTags,
 	}, nil
 }
 
+func (s *storage) GetLatestCubeVersion(ctx context.Context, cubeID string) (*int, error) {
+	var version sql.NullInt64
+	err := s.db.GetContext(ctx, &version, `SELECT MAX(versionNumber) FROM cube_versions WHERE cubeId = ?`, cubeID)
+	if err != nil {
+		return nil, fmt.Errorf(`select latest version: %w`, err)
+	}
+	if !version.Valid {
+		return nil, nil
+	}
+	v := int(version.Int64)
+	return &v, nil
+}
+
+func (s *storage) RecordSyncRun(ctx context.Context, run cubes.SyncRun) error {
+	query := `
+INSERT INTO sync_runs (id, cubeId, source, startedAt, finishedAt, outcome, versionNumber, error)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	var version sql.NullInt64
+	if run.VersionNumber != nil {
+		version = sql.NullInt64{Valid: true, Int64: int64(*run.VersionNumber)}
+	}
+	_, err := s.db.ExecContext(ctx, query,
+		run.ID, run.CubeID, run.Source, run.StartedAt, run.FinishedAt, run.Outcome, version, run.Error,
+	)
+	if err != nil {
+		return fmt.Errorf(`insert sync run: %w`, err)
+	}
+	return nil
+}
+
 func (s *storage) RecordEvent(ctx context.Context, event cubes.Event) error {
 	query := `INSERT IGNORE INTO events (id, cubeId, versionNumber, eventDate) VALUES (?, ?, ?, ?)`
 	_, err := s.db.ExecContext(ctx, query, event.ID, event.Cube.ID, event.Cube.VersionNumber, event.Date)
@@ -442,6 +820,69 @@ func (s *storage) RecordEvent(ctx context.Context, event cubes.Event) error {
 	return nil
 }
 
+func (s *storage) GetDeck(ctx context.Context, id string) (*cubes.Deck, error) {
+	var d dbDeck
+	err := s.db.GetContext(ctx, &d, `SELECT id, playerId, eventId, description FROM decks WHERE id = ?`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf(`get deck: %w`, err)
+	}
+
+	var e dbEvent
+	err = s.db.GetContext(ctx, &e, `SELECT * FROM events WHERE id = ?`, d.EventID)
+	if err != nil {
+		return nil, fmt.Errorf(`get event: %w`, err)
+	}
+	cube, err := s.GetCube(ctx, e.CubeID, &e.VersionNumber, cubes.AllBoards)
+	if err != nil {
+		return nil, fmt.Errorf(`get event cube: %w`, err)
+	}
+	if cube == nil {
+		return nil, fmt.Errorf(`cube %s of event %s not found`, e.CubeID, e.ID)
+	}
+
+	var deckCards []dbDeckCard
+	err = s.db.SelectContext(ctx, &deckCards, `SELECT * FROM deck_cards WHERE deckId = ? ORDER BY board, cardId`, id)
+	if err != nil {
+		return nil, fmt.Errorf(`get deck cards: %w`, err)
+	}
+	var cardIDs []string
+	for _, dc := range deckCards {
+		cardIDs = append(cardIDs, dc.CardID)
+	}
+	cardList, err := s.GetByIDs(ctx, cardIDs)
+	if err != nil {
+		return nil, fmt.Errorf(`get cards: %w`, err)
+	}
+	cardsByID := make(map[string]cubes.Card, len(cardList))
+	for _, card := range cardList {
+		cardsByID[card.ID] = card
+	}
+
+	deck := &cubes.Deck{
+		ID:          d.ID,
+		PlayerID:    d.PlayerID,
+		Event:       cubes.Event{ID: e.ID, Cube: *cube, Date: e.EventDate},
+		Description: d.Description,
+	}
+	for _, dc := range deckCards {
+		card, ok := cardsByID[dc.CardID]
+		if !ok {
+			continue
+		}
+		for range dc.Count {
+			if dc.Board == cubes.DeckSide {
+				deck.Sideboard = append(deck.Sideboard, card)
+			} else {
+				deck.Cards = append(deck.Cards, card)
+			}
+		}
+	}
+	return deck, nil
+}
+
 func (s *storage) RecordDeck(ctx context.Context, deck cubes.Deck) error {
 	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
@@ -456,12 +897,28 @@ func (s *storage) RecordDeck(ctx context.Context, deck cubes.Deck) error {
 		return err
 	}
 
-	for _, card := range deck.Cards {
-		_, err = tx.ExecContext(ctx,
-			`INSERT INTO deck_cards (deckId, cardId) VALUES (?, ?)`,
-			deck.ID, card.ID)
-		if err != nil {
-			return err
+	for _, board := range []struct {
+		name  string
+		cards []cubes.Card
+	}{
+		{cubes.DeckMain, deck.Cards},
+		{cubes.DeckSide, deck.Sideboard},
+	} {
+		counts := make(map[string]int)
+		var cardIDs []string
+		for _, card := range board.cards {
+			if counts[card.ID] == 0 {
+				cardIDs = append(cardIDs, card.ID)
+			}
+			counts[card.ID]++
+		}
+		for _, cardID := range cardIDs {
+			_, err = tx.ExecContext(ctx,
+				`INSERT INTO deck_cards (deckId, board, cardId, count) VALUES (?, ?, ?, ?)`,
+				deck.ID, board.name, cardID, counts[cardID])
+			if err != nil {
+				return err
+			}
 		}
 	}
 
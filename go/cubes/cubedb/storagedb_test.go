@@ -0,0 +1,118 @@
+package cubedb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+// schemaDir holds the skeema table definitions the tests create
+const schemaDir = "../../../db/cubes"
+
+// newTestDB creates a scratch database from the schema on the MySQL server at $CUBES_TEST_MYSQL, a DSN without a
+// database such as root@tcp(127.0.0.1:3306)/, and drops it when the test ends. Tests are skipped without a server.
+func newTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	dsn := os.Getenv("CUBES_TEST_MYSQL")
+	if dsn == "" {
+		t.Skip("CUBES_TEST_MYSQL not set")
+	}
+	server, err := sqlx.Open("mysql", dsn)
+	if err != nil {
+		t.Fatalf("connect MySQL: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	name := fmt.Sprintf("cubes_test_%d", time.Now().UnixNano())
+	if _, err := server.Exec("CREATE DATABASE " + name); err != nil {
+		t.Fatalf("create database: %v", err)
+	}
+	t.Cleanup(func() { server.Exec("DROP DATABASE " + name) })
+
+	db, err := sqlx.Open("mysql", dsn+name+"?parseTime=true")
+	if err != nil {
+		t.Fatalf("connect %s: %v", name, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	files, err := filepath.Glob(filepath.Join(schemaDir, "*.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("schema files: %v, %v", files, err)
+	}
+	for _, file := range files {
+		schema, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read %s: %v", file, err)
+		}
+		if _, err := db.Exec(string(schema)); err != nil {
+			t.Fatalf("create %s: %v", filepath.Base(file), err)
+		}
+	}
+	return db
+}
+
+func TestCubeRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	storage := NewStorage(newTestDB(t))
+	bolt := cubes.Card{ID: "77c6fa74-5543-42ac-9ead-0e890b188e99", Name: "Lightning Bolt", Type: "Instant", Set: "m11"}
+	counterspell := cubes.Card{ID: "1920dae4-fb92-4f19-ae4b-eb3276b8dac7", Name: "Counterspell", Type: "Instant", Set: "tmp"}
+	if err := storage.UpsertCards(ctx, []cubes.Card{bolt, counterspell}); err != nil {
+		t.Fatalf("upsert cards: %v", err)
+	}
+
+	cube := cubes.Cube{
+		ID:            "round-trip",
+		Name:          "Round Trip",
+		VersionNumber: 1,
+		Date:          time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Cards:         []cubes.Card{bolt, counterspell, bolt, bolt},
+		Boards:        map[string][]cubes.Card{cubes.MaybeBoard: {counterspell, counterspell, bolt}},
+		Tags:          map[string][]string{bolt.ID: {"burn", "removal"}},
+		BoardTags:     map[string]map[string][]string{cubes.MaybeBoard: {bolt.ID: {"cut"}, counterspell.ID: {"counter"}}},
+	}
+	if err := storage.UpdateCube(ctx, cube); err != nil {
+		t.Fatalf("update cube: %v", err)
+	}
+
+	got, err := storage.GetCube(ctx, cube.ID, nil, cubes.AllBoards)
+	if err != nil || got == nil {
+		t.Fatalf("get cube: %v, %v", got, err)
+	}
+	if got.Name != cube.Name || got.VersionNumber != cube.VersionNumber {
+		t.Errorf("cube = %s v%d, want %s v%d", got.Name, got.VersionNumber, cube.Name, cube.VersionNumber)
+	}
+	counts := func(cards []cubes.Card) map[string]int {
+		counts := make(map[string]int)
+		for _, card := range cards {
+			counts[card.Name]++
+		}
+		return counts
+	}
+	if main := counts(got.Cards); main["Lightning Bolt"] != 3 || main["Counterspell"] != 1 || len(got.Cards) != 4 {
+		t.Errorf("mainboard = %v, want 3 Lightning Bolt and 1 Counterspell", main)
+	}
+	if maybe := counts(got.Board(cubes.MaybeBoard)); maybe["Counterspell"] != 2 || maybe["Lightning Bolt"] != 1 || len(maybe) != 2 {
+		t.Errorf("maybeboard = %v, want 2 Counterspell and 1 Lightning Bolt", maybe)
+	}
+	if tags := got.Tags[bolt.ID]; !slices.Equal(tags, []string{"burn", "removal"}) || len(got.Tags) != 1 {
+		t.Errorf("mainboard tags = %v, want Lightning Bolt tagged [burn removal]", got.Tags)
+	}
+	maybeTags := got.TagsOn(cubes.MaybeBoard)
+	if !slices.Equal(maybeTags[bolt.ID], []string{"cut"}) || !slices.Equal(maybeTags[counterspell.ID], []string{"counter"}) {
+		t.Errorf("maybeboard tags = %v, want Lightning Bolt [cut] and Counterspell [counter]", maybeTags)
+	}
+
+	mainOnly, err := storage.GetCube(ctx, cube.ID, nil)
+	if err != nil || mainOnly == nil {
+		t.Fatalf("get mainboard: %v, %v", mainOnly, err)
+	}
+	if len(mainOnly.Cards) != 4 || len(mainOnly.Boards) != 0 || len(mainOnly.BoardTags) != 0 {
+		t.Errorf("mainboard only = %d cards, boards %v and board tags %v, want 4 cards and no other boards", len(mainOnly.Cards), mainOnly.Boards, mainOnly.BoardTags)
+	}
+}
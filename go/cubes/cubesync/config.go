@@ -0,0 +1,101 @@
+// Package cubesync keeps a set of cubes up to date by loading each on its own schedule
+package cubesync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Sources a cube can be synced from
+const (
+	SourceCubeCobra = "cubecobra"
+	SourceCSV       = "csv"
+)
+
+// Config lists the cubes to sync. CSVDir is where cubes with the csv source are read from.
+//
+//	{
+//	  "csvDir": "exports",
+//	  "cubes": [
+//	    {"id": "da519447-9b91-4eac-a6d6-8a263f42e093", "source": "cubecobra", "interval": "6h"}
+//	  ]
+//	}
+type Config struct {
+	CSVDir string       `json:"csvDir"`
+	Cubes  []CubeConfig `json:"cubes"`
+}
+
+type CubeConfig struct {
+	ID       string   `json:"id"`
+	Source   string   `json:"source"`
+	Interval Duration `json:"interval"`
+}
+
+// Duration is a time.Duration written in JSON as a string such as "30m"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf(`duration should be a string: %w`, err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LoadConfig reads and validates a JSON config file
+func LoadConfig(path string) (Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf(`read config: %w`, err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return Config{}, fmt.Errorf(`parse config: %w`, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Validate checks every cube has an ID, a known source and a positive interval
+func (c Config) Validate() error {
+	if len(c.Cubes) == 0 {
+		return errors.New(`config has no cubes`)
+	}
+	seen := make(map[string]bool, len(c.Cubes))
+	for i, cube := range c.Cubes {
+		if cube.ID == "" {
+			return fmt.Errorf(`cube %d has no id`, i)
+		}
+		if seen[cube.ID] {
+			return fmt.Errorf(`cube %s is listed twice`, cube.ID)
+		}
+		seen[cube.ID] = true
+		switch cube.Source {
+		case SourceCubeCobra:
+		case SourceCSV:
+			if c.CSVDir == "" {
+				return fmt.Errorf(`cube %s uses the csv source but csvDir is not set`, cube.ID)
+			}
+		default:
+			return fmt.Errorf(`cube %s has unknown source %q`, cube.ID, cube.Source)
+		}
+		if cube.Interval <= 0 {
+			return fmt.Errorf(`cube %s needs a positive interval`, cube.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package cubesync
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mgdunn2/cube-datahub/cubes"
+	"github.com/mgdunn2/cube-datahub/cubes/cards"
+)
+
+const (
+	// defaultJitter spreads polls by up to this fraction of the interval either way
+	defaultJitter = 0.1
+	// defaultBaseBackoff is the wait after the first failure; it doubles with each failure in a row up to the interval
+	defaultBaseBackoff = time.Minute
+	// recordTimeout bounds recording a run that was interrupted by shutdown
+	recordTimeout = 5 * time.Second
+)
+
+// Service polls each configured cube on its own schedule and records the outcome of every load
+type Service struct {
+	storage     cubes.Storage
+	loaders     map[string]cards.CubeLoader
+	cubes       []CubeConfig
+	jitter      float64
+	baseBackoff time.Duration
+}
+
+type ServiceOpts func(*Service)
+
+// ServiceWithJitter sets the fraction of the interval polls are randomly moved by, so cubes don't all load together
+func ServiceWithJitter(jitter float64) ServiceOpts {
+	return func(s *Service) {
+		s.jitter = jitter
+	}
+}
+
+// ServiceWithBackoff sets the wait after the first failed load
+func ServiceWithBackoff(baseBackoff time.Duration) ServiceOpts {
+	return func(s *Service) {
+		s.baseBackoff = baseBackoff
+	}
+}
+
+// NewService syncs the cubes in cfg. loaders maps each source in use to the loader for it.
+func NewService(storage cubes.Storage, loaders map[string]cards.CubeLoader, cfg Config, opts ...ServiceOpts) (*Service, error) {
+	for _, cube := range cfg.Cubes {
+		if _, ok := loaders[cube.Source]; !ok {
+			return nil, fmt.Errorf(`no loader for source %q of cube %s`, cube.Source, cube.ID)
+		}
+	}
+	service := &Service{
+		storage:     storage,
+		loaders:     loaders,
+		cubes:       cfg.Cubes,
+		jitter:      defaultJitter,
+		baseBackoff: defaultBaseBackoff,
+	}
+	for _, opt := range opts {
+		opt(service)
+	}
+	return service, nil
+}
+
+// Run syncs every cube until ctx is cancelled, then waits for in-flight loads to stop before returning
+func (s *Service) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, cube := range s.cubes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.poll(ctx, cube)
+		}()
+	}
+	wg.Wait()
+}
+
+// poll syncs one cube on its schedule. The first sync happens soon after start, staggered so cubes started together
+// spread out.
+func (s *Service) poll(ctx context.Context, cube CubeConfig) {
+	interval := time.Duration(cube.Interval)
+	delay := time.Duration(rand.Float64() * s.jitter * float64(interval))
+	failures := 0
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		run := s.SyncOnce(ctx, cube)
+		log.Printf("sync %s: %s %s", cube.ID, run.Outcome, run.Error)
+		if run.Outcome == cubes.SyncFailed {
+			failures++
+			delay = s.backoff(failures, interval)
+		} else {
+			failures = 0
+			delay = s.jittered(interval)
+		}
+	}
+}
+
+// SyncOnce loads a cube and records the outcome
+func (s *Service) SyncOnce(ctx context.Context, cube CubeConfig) cubes.SyncRun {
+	runID, err := uuid.NewV7()
+	if err != nil {
+		return cubes.SyncRun{CubeID: cube.ID, Source: cube.Source, Outcome: cubes.SyncFailed, Error: fmt.Sprintf(`new run id: %v`, err)}
+	}
+	run := cubes.SyncRun{
+		ID:        runID.String(),
+		CubeID:    cube.ID,
+		Source:    cube.Source,
+		StartedAt: time.Now(),
+	}
+	before, err := s.storage.GetLatestCubeVersion(ctx, cube.ID)
+	if err == nil {
+		err = s.loaders[cube.Source].Load(ctx, cube.ID)
+	}
+	var after *int
+	if err == nil {
+		after, err = s.storage.GetLatestCubeVersion(ctx, cube.ID)
+	}
+	run.FinishedAt = time.Now()
+	switch {
+	case err != nil:
+		run.Outcome = cubes.SyncFailed
+		run.Error = err.Error()
+		run.VersionNumber = before
+	case before == nil && after != nil, before != nil && after != nil && *after > *before:
+		run.Outcome = cubes.SyncNewVersion
+		run.VersionNumber = after
+	default:
+		run.Outcome = cubes.SyncUnchanged
+		run.VersionNumber = after
+	}
+
+	// Still record runs cut short by shutdown
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+	if err := s.storage.RecordSyncRun(recordCtx, run); err != nil {
+		log.Printf("record sync run for %s: %v", cube.ID, err)
+	}
+	return run
+}
+
+// jittered returns the interval moved randomly by up to the jitter fraction either way
+func (s *Service) jittered(interval time.Duration) time.Duration {
+	spread := s.jitter * float64(interval)
+	return interval + time.Duration((rand.Float64()*2-1)*spread)
+}
+
+// backoff doubles from the base backoff with each failure in a row, capped at the interval
+func (s *Service) backoff(failures int, interval time.Duration) time.Duration {
+	delay := s.baseBackoff << (failures - 1)
+	if delay <= 0 || delay > interval {
+		delay = interval
+	}
+	return s.jittered(delay)
+}
@@ -0,0 +1,180 @@
+package cubes
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// CubeDiff describes what changed between two versions of a cube. Added and Removed cover the mainboard and Boards
+// covers every other board.
+type CubeDiff struct {
+	Added      []Card               `json:"added"`
+	Removed    []Card               `json:"removed"`
+	Boards     map[string]BoardDiff `json:"boards,omitempty"`
+	TagChanges []TagChange          `json:"tag_changes"`
+}
+
+// BoardDiff lists the cards added to and removed from a single board
+type BoardDiff struct {
+	Added   []Card `json:"added"`
+	Removed []Card `json:"removed"`
+}
+
+// TagChange lists the tags added to and removed from a card that is on the same board in both versions. Board is
+// empty for the mainboard.
+type TagChange struct {
+	Card    Card     `json:"card"`
+	Board   string   `json:"board,omitempty"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// HasChanges reports whether the two versions differ in cards or tags
+func (d CubeDiff) HasChanges() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Boards) > 0 || len(d.TagChanges) > 0
+}
+
+// DiffCubes compares two versions of a cube. Cards are compared by ID so duplicate copies are ignored.
+func DiffCubes(old, new Cube) CubeDiff {
+	var diff CubeDiff
+	diff.Added, diff.Removed = diffBoard(old.Cards, new.Cards)
+
+	diff.TagChanges = diffBoardTags("", old.Cards, new.Cards, old.Tags, new.Tags)
+
+	boardNames := make(map[string]struct{})
+	for name := range old.Boards {
+		boardNames[name] = struct{}{}
+	}
+	for name := range new.Boards {
+		boardNames[name] = struct{}{}
+	}
+	names := make([]string, 0, len(boardNames))
+	for name := range boardNames {
+		if name != MainBoard {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		added, removed := diffBoard(old.Boards[name], new.Boards[name])
+		if len(added) > 0 || len(removed) > 0 {
+			if diff.Boards == nil {
+				diff.Boards = make(map[string]BoardDiff)
+			}
+			diff.Boards[name] = BoardDiff{Added: added, Removed: removed}
+		}
+		changes := diffBoardTags(name, old.Boards[name], new.Boards[name], old.BoardTags[name], new.BoardTags[name])
+		diff.TagChanges = append(diff.TagChanges, changes...)
+	}
+	return diff
+}
+
+// diffBoardTags compares the tags of the cards that are on a board in both versions
+func diffBoardTags(board string, old, new []Card, oldTags, newTags map[string][]string) []TagChange {
+	var changes []TagChange
+	oldCards := cardsByID(old)
+	for _, card := range uniqueCards(new) {
+		if _, ok := oldCards[card.ID]; !ok {
+			continue
+		}
+		added, removed := diffTags(oldTags[card.ID], newTags[card.ID])
+		if len(added) > 0 || len(removed) > 0 {
+			changes = append(changes, TagChange{Card: card, Board: board, Added: added, Removed: removed})
+		}
+	}
+	return changes
+}
+
+func diffBoard(old, new []Card) (added, removed []Card) {
+	oldCards := cardsByID(old)
+	newCards := cardsByID(new)
+	for _, card := range uniqueCards(new) {
+		if _, ok := oldCards[card.ID]; !ok {
+			added = append(added, card)
+		}
+	}
+	for _, card := range uniqueCards(old) {
+		if _, ok := newCards[card.ID]; !ok {
+			removed = append(removed, card)
+		}
+	}
+	return added, removed
+}
+
+func diffTags(old, new []string) (added, removed []string) {
+	for _, tag := range new {
+		if !slices.Contains(old, tag) && !slices.Contains(added, tag) {
+			added = append(added, tag)
+		}
+	}
+	for _, tag := range old {
+		if !slices.Contains(new, tag) && !slices.Contains(removed, tag) {
+			removed = append(removed, tag)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// PrintCubeDiff writes a diff one card per line: + for added, - for removed with the board when it isn't the mainboard,
+// and ~ for tag changes
+func PrintCubeDiff(w io.Writer, diff CubeDiff) {
+	for _, card := range diff.Added {
+		fmt.Fprintf(w, "+ %s\n", card.Name)
+	}
+	for _, card := range diff.Removed {
+		fmt.Fprintf(w, "- %s\n", card.Name)
+	}
+	boards := make([]string, 0, len(diff.Boards))
+	for board := range diff.Boards {
+		boards = append(boards, board)
+	}
+	sort.Strings(boards)
+	for _, board := range boards {
+		for _, card := range diff.Boards[board].Added {
+			fmt.Fprintf(w, "+ %s (%s)\n", card.Name, board)
+		}
+		for _, card := range diff.Boards[board].Removed {
+			fmt.Fprintf(w, "- %s (%s)\n", card.Name, board)
+		}
+	}
+	for _, change := range diff.TagChanges {
+		var parts []string
+		for _, tag := range change.Added {
+			parts = append(parts, "+"+tag)
+		}
+		for _, tag := range change.Removed {
+			parts = append(parts, "-"+tag)
+		}
+		if change.Board != "" {
+			fmt.Fprintf(w, "~ %s (%s) tags: %s\n", change.Card.Name, change.Board, strings.Join(parts, " "))
+			continue
+		}
+		fmt.Fprintf(w, "~ %s tags: %s\n", change.Card.Name, strings.Join(parts, " "))
+	}
+}
+
+func cardsByID(cards []Card) map[string]Card {
+	m := make(map[string]Card, len(cards))
+	for _, card := range cards {
+		m[card.ID] = card
+	}
+	return m
+}
+
+func uniqueCards(cards []Card) []Card {
+	seen := make(map[string]struct{}, len(cards))
+	unique := make([]Card, 0, len(cards))
+	for _, card := range cards {
+		if _, ok := seen[card.ID]; ok {
+			continue
+		}
+		seen[card.ID] = struct{}{}
+		unique = append(unique, card)
+	}
+	return unique
+}
@@ -0,0 +1,75 @@
+package images
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math/bits"
+)
+
+// dHash compares each cell of a 9x8 grid to its right hand neighbour, giving 64 bits
+const (
+	dHashWidth  = 9
+	dHashHeight = 8
+)
+
+// DHash returns the difference hash of an image. Re-encoded or resized copies of the same image hash to values a
+// small Hamming distance apart; see Distance.
+func DHash(img image.Image) uint64 {
+	grid := shrinkGray(img, dHashWidth, dHashHeight)
+	var hash uint64
+	for y := 0; y < dHashHeight; y++ {
+		for x := 0; x < dHashWidth-1; x++ {
+			hash <<= 1
+			if grid[y][x] > grid[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// DecodeDHash decodes a JPEG, PNG or GIF image and returns its difference hash
+func DecodeDHash(r io.Reader) (uint64, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return 0, fmt.Errorf(`decode image: %w`, err)
+	}
+	return DHash(img), nil
+}
+
+// Distance returns the number of bits that differ between two hashes
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// shrinkGray averages the luminance of the image over a width x height grid of cells
+func shrinkGray(img image.Image, width, height int) [][]float64 {
+	bounds := img.Bounds()
+	sums := make([][]float64, height)
+	counts := make([][]int, height)
+	for y := range sums {
+		sums[y] = make([]float64, width)
+		counts[y] = make([]int, width)
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		cy := (y - bounds.Min.Y) * height / bounds.Dy()
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			cx := (x - bounds.Min.X) * width / bounds.Dx()
+			r, g, b, _ := img.At(x, y).RGBA()
+			sums[cy][cx] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			counts[cy][cx]++
+		}
+	}
+	for y := range sums {
+		for x := range sums[y] {
+			if counts[y][x] > 0 {
+				sums[y][x] /= float64(counts[y][x])
+			}
+		}
+	}
+	return sums
+}
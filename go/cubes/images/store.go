@@ -0,0 +1,145 @@
+// Package images keeps local copies of card images so they survive their source disappearing. Images are addressed
+// by the sha256 of their content, so the same image fetched from two URLs is only stored once.
+package images
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned when a store has no image for a ref
+var ErrNotFound = errors.New("image not found")
+
+const refPrefix = "sha256:"
+
+// Store keeps images addressed by content. Refs have the form sha256:<hex digest>.
+type Store interface {
+	// Put stores the image read from r and returns its ref
+	Put(ctx context.Context, r io.Reader) (string, error)
+
+	// Open returns the image for ref or ErrNotFound
+	Open(ctx context.Context, ref string) (io.ReadCloser, error)
+
+	// Has reports whether the store has the image for ref
+	Has(ctx context.Context, ref string) (bool, error)
+}
+
+// Ref returns the ref for the given image content
+func Ref(data []byte) string {
+	sum := sha256.Sum256(data)
+	return refPrefix + hex.EncodeToString(sum[:])
+}
+
+// digest returns the hex digest of a ref after checking it is well formed
+func digest(ref string) (string, error) {
+	hexDigest, ok := strings.CutPrefix(ref, refPrefix)
+	if !ok || len(hexDigest) != sha256.Size*2 {
+		return "", fmt.Errorf(`invalid image ref %q`, ref)
+	}
+	if _, err := hex.DecodeString(hexDigest); err != nil {
+		return "", fmt.Errorf(`invalid image ref %q: %w`, ref, err)
+	}
+	return hexDigest, nil
+}
+
+// FSStore stores images as files under a directory, fanned out by the first two characters of their digest
+type FSStore struct {
+	dir string
+}
+
+func NewFSStore(dir string) (*FSStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf(`create image dir: %w`, err)
+	}
+	return &FSStore{dir: dir}, nil
+}
+
+// Path returns where the image for ref is kept on disk, whether or not it has been stored yet
+func (s *FSStore) Path(ref string) (string, error) {
+	hexDigest, err := digest(ref)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, hexDigest[:2], hexDigest), nil
+}
+
+func (s *FSStore) Put(ctx context.Context, r io.Reader) (string, error) {
+	// Write to a temp file while hashing, then move it into place so readers never see a partial image
+	tmp, err := os.CreateTemp(s.dir, "put-*")
+	if err != nil {
+		return "", fmt.Errorf(`create temp file: %w`, err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), contextReader{ctx: ctx, r: r}); err != nil {
+		return "", fmt.Errorf(`write image: %w`, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf(`close temp file: %w`, err)
+	}
+
+	ref := refPrefix + hex.EncodeToString(hash.Sum(nil))
+	path, err := s.Path(ref)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf(`create image dir: %w`, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf(`move image into place: %w`, err)
+	}
+	return ref, nil
+}
+
+func (s *FSStore) Open(_ context.Context, ref string) (io.ReadCloser, error) {
+	path, err := s.Path(ref)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf(`%w: %s`, ErrNotFound, ref)
+	}
+	if err != nil {
+		return nil, fmt.Errorf(`open image: %w`, err)
+	}
+	return f, nil
+}
+
+func (s *FSStore) Has(_ context.Context, ref string) (bool, error) {
+	path, err := s.Path(ref)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf(`stat image: %w`, err)
+	}
+	return true, nil
+}
+
+// contextReader stops a copy when its context is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
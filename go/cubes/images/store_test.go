@@ -0,0 +1,86 @@
+package images_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mgdunn2/cube-datahub/cubes/images"
+)
+
+var (
+	boltImage         = []byte("\x89PNG\r\n\x1a\nlightning bolt")
+	counterspellImage = []byte("\x89PNG\r\n\x1a\ncounterspell")
+)
+
+func newStore(t *testing.T) (*images.FSStore, string) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "images")
+	store, err := images.NewFSStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	return store, dir
+}
+
+func TestFSStore(t *testing.T) {
+	ctx := context.Background()
+	store, dir := newStore(t)
+
+	ref, err := store.Put(ctx, bytes.NewReader(boltImage))
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if ref != images.Ref(boltImage) {
+		t.Errorf("ref = %s, want %s", ref, images.Ref(boltImage))
+	}
+	path, err := store.Path(ref)
+	if err != nil {
+		t.Fatalf("path: %v", err)
+	}
+	digest := ref[len("sha256:"):]
+	if want := filepath.Join(dir, digest[:2], digest); path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+	if has, err := store.Has(ctx, ref); err != nil || !has {
+		t.Errorf("has = %v, %v, want true", has, err)
+	}
+	r, err := store.Open(ctx, ref)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || !bytes.Equal(data, boltImage) {
+		t.Errorf("read %q, %v, want the stored image", data, err)
+	}
+
+	// The same content is stored once and nothing is left behind from the writes
+	if again, err := store.Put(ctx, bytes.NewReader(boltImage)); err != nil || again != ref {
+		t.Errorf("second put = %s, %v, want %s", again, err, ref)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != digest[:2] {
+		t.Errorf("image dir holds %v, want only %s", entries, digest[:2])
+	}
+
+	missing := images.Ref(counterspellImage)
+	if has, err := store.Has(ctx, missing); err != nil || has {
+		t.Errorf("has missing = %v, %v, want false", has, err)
+	}
+	if _, err := store.Open(ctx, missing); !errors.Is(err, images.ErrNotFound) {
+		t.Errorf("open missing: got %v, want ErrNotFound", err)
+	}
+	for _, bad := range []string{"", "md5:abc", "sha256:xyz", "sha256:" + digest[:10]} {
+		if _, err := store.Has(ctx, bad); err == nil {
+			t.Errorf("has %q succeeded, want an invalid ref error", bad)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+)
+
+const defaultSyncConcurrency = 4
+
+// maxImageSize guards against a misbehaving host streaming forever
+const maxImageSize = 32 << 20
+
+// Syncer downloads card images into a Store and records each card's ImageRef in storage
+type Syncer struct {
+	store       Store
+	storage     cubes.Storage
+	client      *http.Client
+	concurrency int
+}
+
+type SyncerOpts func(*Syncer)
+
+func SyncerWithClient(client http.Client) SyncerOpts {
+	return func(s *Syncer) {
+		s.client = &client
+	}
+}
+
+// SyncerWithConcurrency sets how many images are downloaded at once
+func SyncerWithConcurrency(concurrency int) SyncerOpts {
+	return func(s *Syncer) {
+		s.concurrency = max(concurrency, 1)
+	}
+}
+
+func NewSyncer(store Store, storage cubes.Storage, opts ...SyncerOpts) *Syncer {
+	syncer := &Syncer{
+		store:       store,
+		storage:     storage,
+		concurrency: defaultSyncConcurrency,
+	}
+	for _, opt := range opts {
+		opt(syncer)
+	}
+	if syncer.client == nil {
+		syncer.client = &http.Client{
+			Timeout: 30 * time.Second,
+		}
+	}
+	return syncer
+}
+
+// SyncReport lists what happened to each card passed to Sync, by card ID. Cards without an image URI are left out.
+type SyncReport struct {
+	Synced        []string
+	AlreadySynced []string
+	Failed        map[string]error
+}
+
+// Sync stores the image of every card that doesn't already have one in the store. A failed download is recorded in
+// the report rather than stopping the sync; only storage errors are returned.
+func (s *Syncer) Sync(ctx context.Context, cards []cubes.Card) (SyncReport, error) {
+	report := SyncReport{Failed: make(map[string]error)}
+	jobs := make(chan cubes.Card, len(cards))
+	for _, card := range cards {
+		if card.ImageURI != "" {
+			jobs <- card
+		}
+	}
+	close(jobs)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	for range min(s.concurrency, len(jobs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for card := range jobs {
+				if ctx.Err() != nil {
+					return
+				}
+				synced, syncErr, err := s.syncCard(ctx, card)
+				mu.Lock()
+				switch {
+				case err != nil:
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+				case syncErr != nil:
+					report.Failed[card.ID] = syncErr
+				case synced:
+					report.Synced = append(report.Synced, card.ID)
+				default:
+					report.AlreadySynced = append(report.AlreadySynced, card.ID)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return SyncReport{}, firstErr
+	}
+	return report, nil
+}
+
+// syncCard stores a single card's image. syncErr is a failure to fetch or store this image; err is a storage failure
+// that should stop the sync.
+func (s *Syncer) syncCard(ctx context.Context, card cubes.Card) (synced bool, syncErr error, err error) {
+	if card.ImageRef != "" {
+		has, err := s.store.Has(ctx, card.ImageRef)
+		if err != nil {
+			return false, err, nil
+		}
+		if has {
+			return false, nil, nil
+		}
+	}
+	ref, err := s.download(ctx, card.ImageURI)
+	if err != nil {
+		return false, err, nil
+	}
+	if err := s.storage.SetCardImageRef(ctx, card.ID, ref); err != nil {
+		return false, nil, fmt.Errorf(`set image ref for %s: %w`, card.ID, err)
+	}
+	return true, nil, nil
+}
+
+func (s *Syncer) download(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf(`new request: %w`, err)
+	}
+	req.Header.Set("User-Agent", "cube-datahub/1.0")
+	rsp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf(`get %s: %w`, url, err)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf(`get %s: %s`, url, rsp.Status)
+	}
+	ref, err := s.store.Put(ctx, http.MaxBytesReader(nil, rsp.Body, maxImageSize))
+	if err != nil {
+		return "", fmt.Errorf(`store %s: %w`, url, err)
+	}
+	return ref, nil
+}
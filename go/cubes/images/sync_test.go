@@ -0,0 +1,112 @@
+package images_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/mgdunn2/cube-datahub/cubes"
+	"github.com/mgdunn2/cube-datahub/cubes/images"
+)
+
+// refStorage records the image refs the syncer sets. Other methods panic through the nil embedded interface.
+type refStorage struct {
+	cubes.Storage
+
+	mu   sync.Mutex
+	refs map[string]string
+	err  error
+}
+
+func (s *refStorage) SetCardImageRef(_ context.Context, cardID, ref string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.refs[cardID] = ref
+	return nil
+}
+
+func TestSyncer(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	var fetched []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched = append(fetched, r.URL.Path)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/bolt.png", "/bolt-mirror.png":
+			w.Write(boltImage)
+		case "/counterspell.png":
+			w.Write(counterspellImage)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	store, _ := newStore(t)
+	storage := &refStorage{refs: make(map[string]string)}
+	syncer := images.NewSyncer(store, storage, images.SyncerWithConcurrency(2))
+
+	storedRef, err := store.Put(ctx, bytes.NewReader(counterspellImage))
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	cardList := []cubes.Card{
+		{ID: "bolt", ImageURI: srv.URL + "/bolt.png"},
+		{ID: "bolt-mirror", ImageURI: srv.URL + "/bolt-mirror.png"},
+		{ID: "counterspell", ImageURI: srv.URL + "/counterspell.png", ImageRef: storedRef},
+		{ID: "stale", ImageURI: srv.URL + "/counterspell.png", ImageRef: images.Ref([]byte("gone"))},
+		{ID: "missing", ImageURI: srv.URL + "/missing.png"},
+		{ID: "no-image"},
+	}
+	report, err := syncer.Sync(ctx, cardList)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	slices.Sort(report.Synced)
+	if want := []string{"bolt", "bolt-mirror", "stale"}; !slices.Equal(report.Synced, want) {
+		t.Errorf("synced = %v, want %v", report.Synced, want)
+	}
+	if !slices.Equal(report.AlreadySynced, []string{"counterspell"}) {
+		t.Errorf("already synced = %v, want [counterspell]", report.AlreadySynced)
+	}
+	if _, ok := report.Failed["missing"]; !ok || len(report.Failed) != 1 {
+		t.Errorf("failed = %v, want only missing", report.Failed)
+	}
+	if ref := images.Ref(boltImage); storage.refs["bolt"] != ref || storage.refs["bolt-mirror"] != ref {
+		t.Errorf("bolt refs = %s and %s, want %s for both", storage.refs["bolt"], storage.refs["bolt-mirror"], ref)
+	}
+	if storage.refs["stale"] != storedRef {
+		t.Errorf("stale ref = %s, want %s", storage.refs["stale"], storedRef)
+	}
+	if _, ok := storage.refs["counterspell"]; ok {
+		t.Error("ref was set again for an image already in the store")
+	}
+	if len(fetched) != 4 {
+		t.Errorf("fetched %v, want the four images that weren't stored", fetched)
+	}
+}
+
+func TestSyncerStorageError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(boltImage)
+	}))
+	defer srv.Close()
+	store, _ := newStore(t)
+	storageErr := errors.New("storage down")
+	syncer := images.NewSyncer(store, &refStorage{refs: make(map[string]string), err: storageErr})
+
+	_, err := syncer.Sync(context.Background(), []cubes.Card{{ID: "bolt", ImageURI: srv.URL + "/bolt.png"}})
+	if !errors.Is(err, storageErr) {
+		t.Errorf("sync: got %v, want the storage error", err)
+	}
+}
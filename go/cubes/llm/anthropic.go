@@ -0,0 +1,211 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// DefaultAnthropicBaseURL is the Anthropic API
+	DefaultAnthropicBaseURL = "https://api.anthropic.com"
+	// DefaultAnthropicModel is the Claude model used unless another is configured
+	DefaultAnthropicModel = "claude-sonnet-4-20250514"
+	// AnthropicVersion is the Messages API version requests are made against
+	AnthropicVersion = "2023-06-01"
+	// defaultAnthropicMaxTokens bounds the tool call; a full card or a deck list fits well within it
+	defaultAnthropicMaxTokens = 4096
+	// systemPrompt is shared by every provider so their results can be compared
+	systemPrompt = "You are a helpful assistant whose job it is to assist with image classification. Use the provided tools to help classify the images"
+)
+
+// Anthropic reads images with Claude through the Messages API, forcing a call to the request's tool so it returns the
+// same tool argument JSON as OpenAi
+type Anthropic struct {
+	apiKey    string
+	baseURL   string
+	model     string
+	maxTokens int
+	client    *http.Client
+}
+
+type AnthropicOpts func(*Anthropic)
+
+// AnthropicWithBaseURL points the reader at another host, such as a proxy or the stand-in in llmtest
+func AnthropicWithBaseURL(baseURL string) AnthropicOpts {
+	return func(a *Anthropic) {
+		a.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+func AnthropicWithModel(model string) AnthropicOpts {
+	return func(a *Anthropic) {
+		a.model = model
+	}
+}
+
+func AnthropicWithMaxTokens(maxTokens int) AnthropicOpts {
+	return func(a *Anthropic) {
+		a.maxTokens = maxTokens
+	}
+}
+
+func AnthropicWithClient(client *http.Client) AnthropicOpts {
+	return func(a *Anthropic) {
+		a.client = client
+	}
+}
+
+func NewAnthropic(apiKey string, opts ...AnthropicOpts) *Anthropic {
+	a := &Anthropic{
+		apiKey:    apiKey,
+		baseURL:   DefaultAnthropicBaseURL,
+		model:     DefaultAnthropicModel,
+		maxTokens: defaultAnthropicMaxTokens,
+		client:    http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// AnthropicRequest is the body of a Messages API request
+type AnthropicRequest struct {
+	Model      string               `json:"model"`
+	MaxTokens  int                  `json:"max_tokens"`
+	System     string               `json:"system,omitempty"`
+	Messages   []AnthropicMessage   `json:"messages"`
+	Tools      []AnthropicTool      `json:"tools,omitempty"`
+	ToolChoice *AnthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+type AnthropicMessage struct {
+	Role    string             `json:"role"`
+	Content []AnthropicContent `json:"content"`
+}
+
+// AnthropicContent is a content block. Text blocks set Text, image blocks set Source and tool_use blocks set Name and
+// Input.
+type AnthropicContent struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *AnthropicImageSource `json:"source,omitempty"`
+	ID     string                `json:"id,omitempty"`
+	Name   string                `json:"name,omitempty"`
+	Input  json.RawMessage       `json:"input,omitempty"`
+}
+
+// AnthropicImageSource is either base64 data with its media type or a URL
+type AnthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type AnthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type AnthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+// AnthropicResponse is the part of a Messages API response the reader uses
+type AnthropicResponse struct {
+	ID         string             `json:"id"`
+	Type       string             `json:"type"`
+	Role       string             `json:"role"`
+	Model      string             `json:"model"`
+	Content    []AnthropicContent `json:"content"`
+	StopReason string             `json:"stop_reason"`
+}
+
+// AnthropicError is the body of a failed request
+type AnthropicError struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (a *Anthropic) Generate(ctx context.Context, req Request) (string, error) {
+	var image AnthropicContent
+	switch {
+	case len(req.ImageByes) > 0:
+		image = AnthropicContent{Type: "image", Source: &AnthropicImageSource{
+			Type:      "base64",
+			MediaType: http.DetectContentType(req.ImageByes),
+			Data:      base64.StdEncoding.EncodeToString(req.ImageByes),
+		}}
+	case req.ImageURL != "":
+		image = AnthropicContent{Type: "image", Source: &AnthropicImageSource{Type: "url", URL: req.ImageURL}}
+	default:
+		return "", errors.New("no image provided")
+	}
+
+	body, err := json.Marshal(AnthropicRequest{
+		Model:     a.model,
+		MaxTokens: a.maxTokens,
+		System:    systemPrompt,
+		Messages: []AnthropicMessage{{
+			Role:    "user",
+			Content: []AnthropicContent{{Type: "text", Text: req.Prompt}, image},
+		}},
+		Tools: []AnthropicTool{{
+			Name:        req.Schema.Name,
+			Description: req.Schema.Description,
+			InputSchema: req.Schema.Schema,
+		}},
+		ToolChoice: &AnthropicToolChoice{Type: "tool", Name: req.Schema.Name},
+	})
+	if err != nil {
+		return "", fmt.Errorf(`marshal request: %w`, err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf(`build request: %w`, err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", a.apiKey)
+	httpReq.Header.Set("anthropic-version", AnthropicVersion)
+
+	httpRsp, err := a.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("generate: %w", err)
+	}
+	defer httpRsp.Body.Close()
+	raw, err := io.ReadAll(httpRsp.Body)
+	if err != nil {
+		return "", fmt.Errorf(`read response: %w`, err)
+	}
+	if httpRsp.StatusCode != http.StatusOK {
+		var apiErr AnthropicError
+		if json.Unmarshal(raw, &apiErr) == nil && apiErr.Error.Message != "" {
+			return "", fmt.Errorf(`generate: %s: %s: %s`, httpRsp.Status, apiErr.Error.Type, apiErr.Error.Message)
+		}
+		return "", fmt.Errorf(`generate: %s`, httpRsp.Status)
+	}
+
+	var rsp AnthropicResponse
+	if err := json.Unmarshal(raw, &rsp); err != nil {
+		return "", fmt.Errorf(`unmarshal response: %w`, err)
+	}
+	for _, content := range rsp.Content {
+		if content.Type == "tool_use" && content.Name == req.Schema.Name {
+			return string(content.Input), nil
+		}
+	}
+	return "", errors.New("no tool call found")
+}
@@ -0,0 +1,124 @@
+package llm_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mgdunn2/cube-datahub/cubes/llm"
+	"github.com/mgdunn2/cube-datahub/cubes/llm/llmtest"
+)
+
+type testCard struct {
+	Name     string `json:"name" jsonschema:"required"`
+	ManaCost string `json:"mana_cost"`
+}
+
+// pngImage has a PNG signature so its content type is detected as image/png
+var pngImage = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func testRequest() llm.Request {
+	return llm.Request{
+		Prompt:    "Read the card",
+		ImageByes: pngImage,
+		Schema: llm.ToolSchema{
+			Name:        "read_card",
+			Description: "Record the card",
+			Schema:      llm.GenerateSchema(testCard{}),
+		},
+	}
+}
+
+// headerRecorder records the headers of every request it sends
+type headerRecorder struct {
+	mu      sync.Mutex
+	headers []http.Header
+}
+
+func (h *headerRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	h.mu.Lock()
+	h.headers = append(h.headers, req.Header.Clone())
+	h.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestAnthropicGenerate(t *testing.T) {
+	want := testCard{Name: "Lightning Bolt", ManaCost: "{R}"}
+	srv := llmtest.NewAnthropicServer(llmtest.StaticResponse(want))
+	defer srv.Close()
+	recorder := &headerRecorder{}
+	reader := llm.NewAnthropic("test-key",
+		llm.AnthropicWithBaseURL(srv.URL),
+		llm.AnthropicWithClient(&http.Client{Transport: recorder}),
+	)
+
+	out, err := reader.Generate(context.Background(), testRequest())
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	var got testCard
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("unmarshal tool input %q: %v", out, err)
+	}
+	if got != want {
+		t.Errorf("tool input = %+v, want %+v", got, want)
+	}
+
+	if len(recorder.headers) != 1 {
+		t.Fatalf("sent %d requests, want 1", len(recorder.headers))
+	}
+	if key := recorder.headers[0].Get("x-api-key"); key != "test-key" {
+		t.Errorf("x-api-key = %q, want test-key", key)
+	}
+	if version := recorder.headers[0].Get("anthropic-version"); version != llm.AnthropicVersion {
+		t.Errorf("anthropic-version = %q, want %s", version, llm.AnthropicVersion)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("server saw %d requests, want 1", len(requests))
+	}
+	req := requests[0]
+	if req.ToolChoice == nil || req.ToolChoice.Type != "tool" || req.ToolChoice.Name != "read_card" {
+		t.Errorf("tool_choice = %+v, want the read_card tool forced", req.ToolChoice)
+	}
+	if len(req.Tools) != 1 || req.Tools[0].Name != "read_card" {
+		t.Errorf("tools = %+v, want read_card", req.Tools)
+	}
+	var image *llm.AnthropicImageSource
+	for _, content := range req.Messages[0].Content {
+		if content.Type == "image" {
+			image = content.Source
+		}
+	}
+	if image == nil {
+		t.Fatal("request has no image block")
+	}
+	if image.Type != "base64" || image.MediaType != "image/png" {
+		t.Errorf("image source = %s %s, want base64 image/png", image.Type, image.MediaType)
+	}
+	if image.Data != base64.StdEncoding.EncodeToString(pngImage) {
+		t.Errorf("image data = %q, want the encoded image", image.Data)
+	}
+}
+
+func TestAnthropicGenerateError(t *testing.T) {
+	srv := llmtest.NewAnthropicServer(llmtest.StaticResponse(testCard{Name: "Lightning Bolt"}))
+	defer srv.Close()
+	srv.FailNext(1, http.StatusServiceUnavailable)
+	reader := llm.NewAnthropic("test-key", llm.AnthropicWithBaseURL(srv.URL))
+
+	_, err := reader.Generate(context.Background(), testRequest())
+	if err == nil {
+		t.Fatal("generate succeeded against a failing server")
+	}
+	for _, part := range []string{"503", "api_error", "injected failure"} {
+		if !strings.Contains(err.Error(), part) {
+			t.Errorf("error %q doesn't mention %q", err, part)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// CacheEntry is a cached response along with what it was for
+type CacheEntry struct {
+	Key       string    `json:"key"`
+	Model     string    `json:"model"`
+	Tool      string    `json:"tool"`
+	Response  string    `json:"response"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Hits counts requests served from this response
+	Hits int `json:"hits"`
+	// Misses counts the requests for the key that had to call the model, including the one that stored this response
+	Misses int `json:"misses"`
+}
+
+// CacheStore persists cached responses
+type CacheStore interface {
+	// GetCacheEntry returns the entry for key or nil if there is none
+	GetCacheEntry(ctx context.Context, key string) (*CacheEntry, error)
+
+	// PutCacheEntry stores an entry, replacing any with the same key. A replaced entry's hits start again from zero and
+	// entry.Misses is added to its misses.
+	PutCacheEntry(ctx context.Context, entry CacheEntry) error
+
+	// RecordCacheHit counts a request served from the entry for key
+	RecordCacheHit(ctx context.Context, key string) error
+}
+
+// CacheStats counts how requests were served since the Cache was built. Misses include expired entries.
+type CacheStats struct {
+	Hits     int64
+	Misses   int64
+	Expired  int64
+	Bypassed int64
+}
+
+func (s CacheStats) String() string {
+	return fmt.Sprintf("llm cache: %d hits, %d misses (%d expired), %d bypassed", s.Hits, s.Misses, s.Expired, s.Bypassed)
+}
+
+// Cache wraps an ImageReader so a request already answered, such as re-reading the same deck photo or custom card, is
+// served from a CacheStore instead of paying for another call. Entries are keyed on the model, prompt, schema and
+// image.
+type Cache struct {
+	reader ImageReader
+	store  CacheStore
+	model  string
+	ttl    time.Duration
+	bypass bool
+
+	hits     atomic.Int64
+	misses   atomic.Int64
+	expired  atomic.Int64
+	bypassed atomic.Int64
+}
+
+type CacheOpts func(*Cache)
+
+// CacheWithModel names the model the wrapped reader uses so switching models doesn't serve the old model's answers
+func CacheWithModel(model string) CacheOpts {
+	return func(c *Cache) {
+		c.model = model
+	}
+}
+
+// CacheWithTTL expires entries older than ttl. Entries never expire by default.
+func CacheWithTTL(ttl time.Duration) CacheOpts {
+	return func(c *Cache) {
+		c.ttl = ttl
+	}
+}
+
+// CacheWithBypass skips cache lookups while still storing fresh responses, to refresh entries
+func CacheWithBypass(bypass bool) CacheOpts {
+	return func(c *Cache) {
+		c.bypass = bypass
+	}
+}
+
+func NewCache(reader ImageReader, store CacheStore, opts ...CacheOpts) *Cache {
+	c := &Cache{reader: reader, store: store}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Stats returns the hits and misses so far
+func (c *Cache) Stats() CacheStats {
+	return CacheStats{
+		Hits:     c.hits.Load(),
+		Misses:   c.misses.Load(),
+		Expired:  c.expired.Load(),
+		Bypassed: c.bypassed.Load(),
+	}
+}
+
+func (c *Cache) Generate(ctx context.Context, req Request) (string, error) {
+	key, err := RequestKey(req, c.model)
+	if err != nil {
+		return "", err
+	}
+	// misses is 1 when the request missed and 0 when the cache was bypassed
+	misses := 0
+	if c.bypass {
+		c.bypassed.Add(1)
+	} else {
+		entry, err := c.store.GetCacheEntry(ctx, key)
+		if err != nil {
+			return "", fmt.Errorf(`get cache entry: %w`, err)
+		}
+		switch {
+		case entry == nil:
+			c.misses.Add(1)
+			misses = 1
+		case c.ttl > 0 && time.Since(entry.CreatedAt) > c.ttl:
+			c.misses.Add(1)
+			c.expired.Add(1)
+			misses = 1
+		default:
+			c.hits.Add(1)
+			if err := c.store.RecordCacheHit(ctx, key); err != nil {
+				log.Printf("record llm cache hit: %v", err)
+			}
+			return entry.Response, nil
+		}
+	}
+
+	out, err := c.reader.Generate(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	// the response has been paid for, so a failure to cache it shouldn't lose it
+	entry := CacheEntry{Key: key, Model: c.model, Tool: req.Schema.Name, Response: out, CreatedAt: time.Now(), Misses: misses}
+	if err := c.store.PutCacheEntry(ctx, entry); err != nil {
+		log.Printf("put llm cache entry: %v", err)
+	}
+	return out, nil
+}
+
+// FSCacheStore keeps cache entries as JSON files in a directory, one per key
+type FSCacheStore struct {
+	dir string
+}
+
+func NewFSCacheStore(dir string) (*FSCacheStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf(`create cache dir: %w`, err)
+	}
+	return &FSCacheStore{dir: dir}, nil
+}
+
+func (s *FSCacheStore) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+func (s *FSCacheStore) GetCacheEntry(_ context.Context, key string) (*CacheEntry, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf(`read cache entry: %w`, err)
+	}
+	var entry CacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf(`unmarshal cache entry %s: %w`, key, err)
+	}
+	return &entry, nil
+}
+
+func (s *FSCacheStore) PutCacheEntry(ctx context.Context, entry CacheEntry) error {
+	existing, err := s.GetCacheEntry(ctx, entry.Key)
+	if err != nil {
+		return err
+	}
+	entry.Hits = 0
+	if existing != nil {
+		entry.Misses += existing.Misses
+	}
+	return s.write(entry)
+}
+
+// write replaces the entry's file atomically
+func (s *FSCacheStore) write(entry CacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf(`marshal cache entry: %w`, err)
+	}
+	tmp, err := os.CreateTemp(s.dir, ".entry-*")
+	if err != nil {
+		return fmt.Errorf(`create cache entry: %w`, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf(`write cache entry: %w`, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf(`write cache entry: %w`, err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(entry.Key)); err != nil {
+		return fmt.Errorf(`write cache entry: %w`, err)
+	}
+	return nil
+}
+
+// RecordCacheHit rewrites the entry with its hit count bumped. Concurrent hits on one entry may undercount.
+func (s *FSCacheStore) RecordCacheHit(ctx context.Context, key string) error {
+	entry, err := s.GetCacheEntry(ctx, key)
+	if err != nil || entry == nil {
+		return err
+	}
+	entry.Hits++
+	return s.write(*entry)
+}
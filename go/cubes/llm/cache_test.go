@@ -0,0 +1,81 @@
+package llm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mgdunn2/cube-datahub/cubes/llm"
+)
+
+// countingReader answers every request with the same response and counts the calls
+type countingReader struct {
+	calls int
+}
+
+func (r *countingReader) Generate(context.Context, llm.Request) (string, error) {
+	r.calls++
+	return `{"name": "Lightning Bolt"}`, nil
+}
+
+func TestCacheCounts(t *testing.T) {
+	ctx := context.Background()
+	store, err := llm.NewFSCacheStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	reader := &countingReader{}
+	req := testRequest()
+	key, err := llm.RequestKey(req, "test/model")
+	if err != nil {
+		t.Fatalf("request key: %v", err)
+	}
+	entry := func() llm.CacheEntry {
+		t.Helper()
+		entry, err := store.GetCacheEntry(ctx, key)
+		if err != nil || entry == nil {
+			t.Fatalf("get entry: %v, %v", entry, err)
+		}
+		return *entry
+	}
+
+	cache := llm.NewCache(reader, store, llm.CacheWithModel("test/model"))
+	for range 3 {
+		if _, err := cache.Generate(ctx, req); err != nil {
+			t.Fatalf("generate: %v", err)
+		}
+	}
+	if reader.calls != 1 {
+		t.Errorf("reader called %d times, want 1", reader.calls)
+	}
+	if stats := cache.Stats(); stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("stats = %s, want 2 hits and 1 miss", stats)
+	}
+	if e := entry(); e.Hits != 2 || e.Misses != 1 {
+		t.Errorf("entry has %d hits and %d misses, want 2 and 1", e.Hits, e.Misses)
+	}
+
+	// An expired entry is a miss, and the fresh response replacing it starts its hits again
+	expiring := llm.NewCache(reader, store, llm.CacheWithModel("test/model"), llm.CacheWithTTL(time.Nanosecond))
+	if _, err := expiring.Generate(ctx, req); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if stats := expiring.Stats(); stats.Misses != 1 || stats.Expired != 1 {
+		t.Errorf("stats = %s, want 1 expired miss", stats)
+	}
+	if e := entry(); e.Hits != 0 || e.Misses != 2 {
+		t.Errorf("replaced entry has %d hits and %d misses, want 0 and 2", e.Hits, e.Misses)
+	}
+
+	// A bypass refreshes the entry without counting a miss
+	bypass := llm.NewCache(reader, store, llm.CacheWithModel("test/model"), llm.CacheWithBypass(true))
+	if _, err := bypass.Generate(ctx, req); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if e := entry(); e.Misses != 2 {
+		t.Errorf("bypassed entry has %d misses, want 2", e.Misses)
+	}
+	if reader.calls != 3 {
+		t.Errorf("reader called %d times, want 3", reader.calls)
+	}
+}
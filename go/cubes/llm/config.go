@@ -0,0 +1,170 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// Providers an ImageReader can be built for
+const (
+	ProviderOpenAi    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderLocal     = "local"
+)
+
+// CacheDB as Config.Cache caches responses in Config.CacheStore, which the commands point at cubedb.LLMCacheStore
+const CacheDB = "db"
+
+// Config picks the provider and model that images are read with. Empty fields take the provider's defaults.
+type Config struct {
+	Provider string
+	APIKey   string
+	Model    string
+	BaseURL  string
+	// RecordDir and RecordMode wrap the reader in a Recorder when both are set
+	RecordDir  string
+	RecordMode string
+	// Cache is CacheDB, a directory to cache responses in, or empty to not cache
+	Cache       string
+	CacheStore  CacheStore
+	CacheTTL    time.Duration
+	CacheBypass bool
+}
+
+// ConfigFromEnv reads LLM_PROVIDER (openai by default), LLM_MODEL and LLM_BASE_URL. The API key comes from
+// OPENAI_API_KEY or ANTHROPIC_API_KEY depending on the provider; local servers rarely need one but can take LLM_API_KEY.
+// LLM_RECORD_DIR and LLM_RECORD_MODE (record or replay) record responses as fixtures or replay them. LLM_CACHE (db or a
+// directory), LLM_CACHE_TTL and LLM_CACHE_BYPASS cache responses.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Provider:   os.Getenv("LLM_PROVIDER"),
+		Model:      os.Getenv("LLM_MODEL"),
+		BaseURL:    os.Getenv("LLM_BASE_URL"),
+		RecordDir:  os.Getenv("LLM_RECORD_DIR"),
+		RecordMode: os.Getenv("LLM_RECORD_MODE"),
+		Cache:      os.Getenv("LLM_CACHE"),
+	}
+	if ttl := os.Getenv("LLM_CACHE_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return Config{}, fmt.Errorf(`parse LLM_CACHE_TTL: %w`, err)
+		}
+		cfg.CacheTTL = d
+	}
+	if bypass := os.Getenv("LLM_CACHE_BYPASS"); bypass != "" {
+		b, err := strconv.ParseBool(bypass)
+		if err != nil {
+			return Config{}, fmt.Errorf(`parse LLM_CACHE_BYPASS: %w`, err)
+		}
+		cfg.CacheBypass = b
+	}
+	if cfg.Provider == "" {
+		cfg.Provider = ProviderOpenAi
+	}
+	switch cfg.Provider {
+	case ProviderOpenAi:
+		cfg.APIKey = os.Getenv("OPENAI_API_KEY")
+	case ProviderAnthropic:
+		cfg.APIKey = os.Getenv("ANTHROPIC_API_KEY")
+	case ProviderLocal:
+		cfg.APIKey = os.Getenv("LLM_API_KEY")
+	}
+	return cfg, nil
+}
+
+// New builds the ImageReader for the configured provider
+func New(cfg Config) (ImageReader, error) {
+	reader, err := newProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RecordDir != "" && cfg.RecordMode != "" {
+		if reader, err = NewRecorder(reader, cfg.RecordDir, cfg.RecordMode); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Cache == "" {
+		return reader, nil
+	}
+	store := cfg.CacheStore
+	if cfg.Cache != CacheDB {
+		if store, err = NewFSCacheStore(cfg.Cache); err != nil {
+			return nil, err
+		}
+	} else if store == nil {
+		return nil, errors.New("database LLM cache needs a cache store")
+	}
+	return NewCache(reader, store,
+		CacheWithModel(cfg.Provider+"/"+cfg.model()),
+		CacheWithTTL(cfg.CacheTTL),
+		CacheWithBypass(cfg.CacheBypass),
+	), nil
+}
+
+// model is the configured model or the provider's default
+func (cfg Config) model() string {
+	if cfg.Model != "" {
+		return cfg.Model
+	}
+	switch cfg.Provider {
+	case ProviderOpenAi, "":
+		return DefaultOpenAiModel
+	case ProviderAnthropic:
+		return DefaultAnthropicModel
+	case ProviderLocal:
+		return DefaultLocalModel
+	}
+	return ""
+}
+
+func newProvider(cfg Config) (ImageReader, error) {
+	switch cfg.Provider {
+	case ProviderOpenAi, "":
+		clientOpts := []option.RequestOption{option.WithAPIKey(cfg.APIKey)}
+		if cfg.BaseURL != "" {
+			clientOpts = append(clientOpts, option.WithBaseURL(cfg.BaseURL))
+		}
+		var opts []OpenAiOpts
+		if cfg.Model != "" {
+			opts = append(opts, OpenAiWithModel(cfg.Model))
+		}
+		return NewOpenAi(openai.NewClient(clientOpts...), opts...), nil
+	case ProviderAnthropic:
+		var opts []AnthropicOpts
+		if cfg.Model != "" {
+			opts = append(opts, AnthropicWithModel(cfg.Model))
+		}
+		if cfg.BaseURL != "" {
+			opts = append(opts, AnthropicWithBaseURL(cfg.BaseURL))
+		}
+		return NewAnthropic(cfg.APIKey, opts...), nil
+	case ProviderLocal:
+		opts := []LocalOpts{LocalWithAPIKey(cfg.APIKey)}
+		if cfg.Model != "" {
+			opts = append(opts, LocalWithModel(cfg.Model))
+		}
+		if cfg.BaseURL != "" {
+			opts = append(opts, LocalWithBaseURL(cfg.BaseURL))
+		}
+		return NewLocal(opts...), nil
+	default:
+		return nil, fmt.Errorf(`unknown LLM provider %q`, cfg.Provider)
+	}
+}
+
+// NewFromEnv builds the ImageReader configured by the environment, see ConfigFromEnv. store is used when LLM_CACHE=db
+// and may be nil otherwise.
+func NewFromEnv(store CacheStore) (ImageReader, error) {
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	cfg.CacheStore = store
+	return New(cfg)
+}
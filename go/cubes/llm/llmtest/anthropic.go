@@ -0,0 +1,190 @@
+// Package llmtest provides offline stand-ins for the LLM APIs the readers in llm call, so card and deck reading can be
+// exercised and compared across providers without network access or API keys.
+package llmtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/mgdunn2/cube-datahub/cubes/llm"
+)
+
+// Responder returns the tool arguments for a tool call, given the prompt and the tool's name. Returning an error makes
+// the stand-in respond with a server error.
+type Responder func(prompt, tool string) (any, error)
+
+// StaticResponse answers every request with the same tool arguments
+func StaticResponse(arguments any) Responder {
+	return func(string, string) (any, error) {
+		return arguments, nil
+	}
+}
+
+// AnthropicServer stands in for the Anthropic Messages API. It checks requests the way the API would for the parts
+// llm.Anthropic uses and answers with a forced tool call. Point the reader at it with llm.AnthropicWithBaseURL(srv.URL).
+type AnthropicServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responder Responder
+	requests  []llm.AnthropicRequest
+	failures  []int
+}
+
+// NewAnthropicServer starts a stand-in that answers with responder. Close it when done.
+func NewAnthropicServer(responder Responder) *AnthropicServer {
+	s := &AnthropicServer{responder: responder}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/messages", s.handleMessages)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// FailNext makes the next n requests fail with status
+func (s *AnthropicServer) FailNext(n int, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, status)
+	}
+}
+
+// Requests returns every well formed request the server has received
+func (s *AnthropicServer) Requests() []llm.AnthropicRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]llm.AnthropicRequest(nil), s.requests...)
+}
+
+func (s *AnthropicServer) handleMessages(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	var failure int
+	if len(s.failures) > 0 {
+		failure, s.failures = s.failures[0], s.failures[1:]
+	}
+	s.mu.Unlock()
+	if failure != 0 {
+		writeAnthropicError(w, failure, "api_error", "injected failure")
+		return
+	}
+
+	if r.Header.Get("x-api-key") == "" {
+		writeAnthropicError(w, http.StatusUnauthorized, "authentication_error", "x-api-key header is required")
+		return
+	}
+	if r.Header.Get("anthropic-version") == "" {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", "anthropic-version header is required")
+		return
+	}
+	var req llm.AnthropicRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if err := validateAnthropicRequest(req); err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+
+	var prompt string
+	for _, content := range req.Messages[len(req.Messages)-1].Content {
+		if content.Type == "text" {
+			prompt += content.Text
+		}
+	}
+	arguments, err := s.responder(prompt, req.ToolChoice.Name)
+	if err != nil {
+		writeAnthropicError(w, http.StatusInternalServerError, "api_error", err.Error())
+		return
+	}
+	input, err := json.Marshal(arguments)
+	if err != nil {
+		writeAnthropicError(w, http.StatusInternalServerError, "api_error", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, llm.AnthropicResponse{
+		ID:    fmt.Sprintf("msg_%d", len(s.Requests())),
+		Type:  "message",
+		Role:  "assistant",
+		Model: req.Model,
+		Content: []llm.AnthropicContent{{
+			Type:  "tool_use",
+			ID:    fmt.Sprintf("toolu_%d", len(s.Requests())),
+			Name:  req.ToolChoice.Name,
+			Input: input,
+		}},
+		StopReason: "tool_use",
+	})
+}
+
+// validateAnthropicRequest checks the fields the reader relies on: a model, a token limit, a user message with an
+// image and a forced choice of a defined tool
+func validateAnthropicRequest(req llm.AnthropicRequest) error {
+	if req.Model == "" {
+		return fmt.Errorf(`model: field required`)
+	}
+	if req.MaxTokens <= 0 {
+		return fmt.Errorf(`max_tokens: must be positive`)
+	}
+	if len(req.Messages) == 0 || req.Messages[len(req.Messages)-1].Role != "user" {
+		return fmt.Errorf(`messages: last message must be from the user`)
+	}
+	hasImage := false
+	for _, content := range req.Messages[len(req.Messages)-1].Content {
+		if content.Type != "image" {
+			continue
+		}
+		if content.Source == nil {
+			return fmt.Errorf(`image: source required`)
+		}
+		switch content.Source.Type {
+		case "base64":
+			if content.Source.MediaType == "" || content.Source.Data == "" {
+				return fmt.Errorf(`image: base64 source needs media_type and data`)
+			}
+		case "url":
+			if content.Source.URL == "" {
+				return fmt.Errorf(`image: url source needs a url`)
+			}
+		default:
+			return fmt.Errorf(`image: unknown source type %q`, content.Source.Type)
+		}
+		hasImage = true
+	}
+	if !hasImage {
+		return fmt.Errorf(`messages: no image content`)
+	}
+	if req.ToolChoice == nil || req.ToolChoice.Type != "tool" {
+		return fmt.Errorf(`tool_choice: expected a forced tool`)
+	}
+	for _, tool := range req.Tools {
+		if tool.Name == req.ToolChoice.Name {
+			if tool.InputSchema["type"] != "object" {
+				return fmt.Errorf(`tools.%s.input_schema: type must be object`, tool.Name)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf(`tool_choice: tool %q is not defined`, req.ToolChoice.Name)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeAnthropicError writes an error in the shape the Anthropic API uses
+func writeAnthropicError(w http.ResponseWriter, status int, errorType, message string) {
+	var body llm.AnthropicError
+	body.Type = "error"
+	body.Error.Type = errorType
+	body.Error.Message = message
+	writeJSON(w, status, body)
+}
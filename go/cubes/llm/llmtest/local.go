@@ -0,0 +1,204 @@
+package llmtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/mgdunn2/cube-datahub/cubes/llm"
+)
+
+// LocalServer stands in for an OpenAI compatible chat completions server such as Ollama. It serves under /v1, so point
+// the reader at it with llm.LocalWithBaseURL(srv.URL + "/v1"). A server without tools rejects tool requests the way
+// Ollama does for models that lack them and answers JSON mode requests in the message content.
+type LocalServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responder Responder
+	tools     bool
+	content   func(arguments []byte) string
+	requests  []llm.ChatRequest
+	failures  []int
+}
+
+type LocalServerOpts func(*LocalServer)
+
+// LocalServerWithoutTools makes the server behave like a model without tool calling
+func LocalServerWithoutTools() LocalServerOpts {
+	return func(s *LocalServer) {
+		s.tools = false
+	}
+}
+
+// LocalServerWithContent rewrites the JSON mode answer, for checking how the reader copes with code fences or output
+// that doesn't match the schema
+func LocalServerWithContent(content func(arguments []byte) string) LocalServerOpts {
+	return func(s *LocalServer) {
+		s.content = content
+	}
+}
+
+// NewLocalServer starts a stand-in that answers with responder. Close it when done.
+func NewLocalServer(responder Responder, opts ...LocalServerOpts) *LocalServer {
+	s := &LocalServer{
+		responder: responder,
+		tools:     true,
+		content:   func(arguments []byte) string { return string(arguments) },
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", s.handleCompletions)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// FailNext makes the next n requests fail with status
+func (s *LocalServer) FailNext(n int, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, status)
+	}
+}
+
+// Requests returns every well formed request the server has received
+func (s *LocalServer) Requests() []llm.ChatRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]llm.ChatRequest(nil), s.requests...)
+}
+
+func (s *LocalServer) handleCompletions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	var failure int
+	if len(s.failures) > 0 {
+		failure, s.failures = s.failures[0], s.failures[1:]
+	}
+	s.mu.Unlock()
+	if failure != 0 {
+		writeChatError(w, failure, "injected failure")
+		return
+	}
+
+	var req llm.ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeChatError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(req.Tools) > 0 && !s.tools {
+		writeChatError(w, http.StatusBadRequest, fmt.Sprintf("%s does not support tools", req.Model))
+		return
+	}
+	prompt, err := validateChatRequest(req)
+	if err != nil {
+		writeChatError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	n := len(s.requests)
+	s.mu.Unlock()
+
+	var tool string
+	if req.ToolChoice != nil {
+		tool = req.ToolChoice.Function.Name
+	} else {
+		tool = req.ResponseFormat.JSONSchema.Name
+	}
+	arguments, err := s.responder(prompt, tool)
+	if err != nil {
+		writeChatError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	raw, err := json.Marshal(arguments)
+	if err != nil {
+		writeChatError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	message := llm.ChatMessage{Role: "assistant"}
+	finish := "stop"
+	if req.ToolChoice != nil {
+		message.Content = json.RawMessage(`""`)
+		message.ToolCalls = []llm.ChatToolCall{{
+			ID:       fmt.Sprintf("call_%d", n),
+			Type:     "function",
+			Function: llm.ChatFunction{Name: tool, Arguments: string(raw)},
+		}}
+		finish = "tool_calls"
+	} else {
+		message.Content, _ = json.Marshal(s.content(raw))
+	}
+	writeJSON(w, http.StatusOK, llm.ChatResponse{
+		ID:      fmt.Sprintf("chatcmpl-%d", n),
+		Object:  "chat.completion",
+		Model:   req.Model,
+		Choices: []llm.ChatChoice{{Message: message, FinishReason: finish}},
+	})
+}
+
+// validateChatRequest checks the fields the reader relies on and returns the user's prompt: a model, a user message
+// with text and an image, and either a forced choice of a defined tool or a JSON schema response format
+func validateChatRequest(req llm.ChatRequest) (string, error) {
+	if req.Model == "" {
+		return "", fmt.Errorf(`model is required`)
+	}
+	if len(req.Messages) == 0 || req.Messages[len(req.Messages)-1].Role != "user" {
+		return "", fmt.Errorf(`messages: last message must be from the user`)
+	}
+	var parts []llm.ChatContentPart
+	if err := json.Unmarshal(req.Messages[len(req.Messages)-1].Content, &parts); err != nil {
+		return "", fmt.Errorf(`messages: user content must be a list of parts: %w`, err)
+	}
+	var prompt string
+	hasImage := false
+	for _, part := range parts {
+		switch part.Type {
+		case "text":
+			prompt += part.Text
+		case "image_url":
+			if part.ImageURL == nil || part.ImageURL.URL == "" {
+				return "", fmt.Errorf(`image_url: url required`)
+			}
+			hasImage = true
+		default:
+			return "", fmt.Errorf(`unknown content part type %q`, part.Type)
+		}
+	}
+	if !hasImage {
+		return "", fmt.Errorf(`messages: no image content`)
+	}
+
+	switch {
+	case req.ToolChoice != nil:
+		for _, tool := range req.Tools {
+			if tool.Function.Name == req.ToolChoice.Function.Name {
+				if tool.Function.Parameters["type"] != "object" {
+					return "", fmt.Errorf(`tools.%s.parameters: type must be object`, tool.Function.Name)
+				}
+				return prompt, nil
+			}
+		}
+		return "", fmt.Errorf(`tool_choice: tool %q is not defined`, req.ToolChoice.Function.Name)
+	case req.ResponseFormat != nil:
+		if req.ResponseFormat.Type != "json_schema" || req.ResponseFormat.JSONSchema == nil {
+			return "", fmt.Errorf(`response_format: expected a json_schema`)
+		}
+		return prompt, nil
+	default:
+		return "", fmt.Errorf(`expected a forced tool or a response_format`)
+	}
+}
+
+// writeChatError writes an error in the shape OpenAI compatible servers use
+func writeChatError(w http.ResponseWriter, status int, message string) {
+	var body llm.ChatError
+	body.Error.Type = "invalid_request_error"
+	body.Error.Message = message
+	writeJSON(w, status, body)
+}
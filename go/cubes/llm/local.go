@@ -0,0 +1,358 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// DefaultLocalBaseURL is Ollama's OpenAI compatible API on its default port
+	DefaultLocalBaseURL = "http://localhost:11434/v1"
+	// DefaultLocalModel is a vision model Ollama can pull
+	DefaultLocalModel = "llama3.2-vision"
+)
+
+// Ways Local asks for structured output
+const (
+	// LocalModeAuto calls the tool and falls back to JSON mode if the model can't
+	LocalModeAuto = "auto"
+	// LocalModeTools always calls the tool
+	LocalModeTools = "tools"
+	// LocalModeJSON always asks for JSON matching the tool's schema
+	LocalModeJSON = "json"
+)
+
+var (
+	// errToolsUnsupported is returned when the server says the model doesn't support tools
+	errToolsUnsupported = errors.New("model does not support tool calling")
+	// errNoToolCall is returned when the model answers without calling the tool
+	errNoToolCall = errors.New("model did not call the tool")
+)
+
+// Local reads images with a self-hosted vision model behind an OpenAI compatible chat completions API, such as Ollama,
+// LM Studio or vLLM. Models without tool calling are asked for JSON output instead, which is validated against the
+// request's schema before it is returned.
+type Local struct {
+	baseURL string
+	model   string
+	apiKey  string
+	mode    string
+	client  *http.Client
+
+	// noTools remembers that the model can't call tools so auto mode stops trying
+	noTools atomic.Bool
+}
+
+type LocalOpts func(*Local)
+
+// LocalWithBaseURL points the reader at the server's API root, including any /v1 prefix
+func LocalWithBaseURL(baseURL string) LocalOpts {
+	return func(l *Local) {
+		l.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+func LocalWithModel(model string) LocalOpts {
+	return func(l *Local) {
+		l.model = model
+	}
+}
+
+// LocalWithAPIKey sends a bearer token, for servers behind a proxy that wants one
+func LocalWithAPIKey(apiKey string) LocalOpts {
+	return func(l *Local) {
+		l.apiKey = apiKey
+	}
+}
+
+// LocalWithMode picks LocalModeAuto, LocalModeTools or LocalModeJSON
+func LocalWithMode(mode string) LocalOpts {
+	return func(l *Local) {
+		l.mode = mode
+	}
+}
+
+func LocalWithClient(client *http.Client) LocalOpts {
+	return func(l *Local) {
+		l.client = client
+	}
+}
+
+func NewLocal(opts ...LocalOpts) *Local {
+	l := &Local{
+		baseURL: DefaultLocalBaseURL,
+		model:   DefaultLocalModel,
+		mode:    LocalModeAuto,
+		client:  http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// ChatRequest is the body of a chat completions request
+type ChatRequest struct {
+	Model          string              `json:"model"`
+	Messages       []ChatMessage       `json:"messages"`
+	Tools          []ChatTool          `json:"tools,omitempty"`
+	ToolChoice     *ChatToolChoice     `json:"tool_choice,omitempty"`
+	ResponseFormat *ChatResponseFormat `json:"response_format,omitempty"`
+	Stream         bool                `json:"stream"`
+}
+
+// ChatMessage holds either plain text content, a list of content parts or the assistant's tool calls
+type ChatMessage struct {
+	Role      string          `json:"role"`
+	Content   json.RawMessage `json:"content,omitempty"`
+	ToolCalls []ChatToolCall  `json:"tool_calls,omitempty"`
+}
+
+// ChatContentPart is a text part or an image_url part
+type ChatContentPart struct {
+	Type     string        `json:"type"`
+	Text     string        `json:"text,omitempty"`
+	ImageURL *ChatImageURL `json:"image_url,omitempty"`
+}
+
+type ChatImageURL struct {
+	URL string `json:"url"`
+}
+
+type ChatTool struct {
+	Type     string       `json:"type"`
+	Function ChatFunction `json:"function"`
+}
+
+type ChatFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+	Arguments   string         `json:"arguments,omitempty"`
+}
+
+type ChatToolChoice struct {
+	Type     string       `json:"type"`
+	Function ChatFunction `json:"function"`
+}
+
+type ChatToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function ChatFunction `json:"function"`
+}
+
+// ChatResponseFormat asks for JSON output, optionally matching a schema
+type ChatResponseFormat struct {
+	Type       string          `json:"type"`
+	JSONSchema *ChatJSONSchema `json:"json_schema,omitempty"`
+}
+
+type ChatJSONSchema struct {
+	Name   string         `json:"name"`
+	Schema map[string]any `json:"schema"`
+}
+
+// ChatResponse is the part of a chat completions response the reader uses
+type ChatResponse struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Model   string       `json:"model"`
+	Choices []ChatChoice `json:"choices"`
+}
+
+type ChatChoice struct {
+	Index        int         `json:"index"`
+	Message      ChatMessage `json:"message"`
+	FinishReason string      `json:"finish_reason"`
+}
+
+// ChatError is the body of a failed request
+type ChatError struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+func (l *Local) Generate(ctx context.Context, req Request) (string, error) {
+	var image ChatContentPart
+	switch {
+	case len(req.ImageByes) > 0:
+		image = ChatContentPart{Type: "image_url", ImageURL: &ChatImageURL{
+			URL: "data:" + http.DetectContentType(req.ImageByes) + ";base64," + base64.StdEncoding.EncodeToString(req.ImageByes),
+		}}
+	case req.ImageURL != "":
+		image = ChatContentPart{Type: "image_url", ImageURL: &ChatImageURL{URL: req.ImageURL}}
+	default:
+		return "", errors.New("no image provided")
+	}
+
+	switch l.mode {
+	case LocalModeJSON:
+		return l.generateJSON(ctx, req, image)
+	case LocalModeTools:
+		return l.generateTool(ctx, req, image)
+	case LocalModeAuto, "":
+		if !l.noTools.Load() {
+			out, err := l.generateTool(ctx, req, image)
+			switch {
+			case errors.Is(err, errToolsUnsupported):
+				l.noTools.Store(true)
+			case !errors.Is(err, errNoToolCall):
+				return out, err
+			}
+			// A model that skipped the tool once may still call it next time, so only this request falls back
+		}
+		return l.generateJSON(ctx, req, image)
+	default:
+		return "", fmt.Errorf(`unknown local mode %q`, l.mode)
+	}
+}
+
+func (l *Local) generateTool(ctx context.Context, req Request, image ChatContentPart) (string, error) {
+	user, err := userMessage(req.Prompt, image)
+	if err != nil {
+		return "", err
+	}
+	rsp, err := l.complete(ctx, ChatRequest{
+		Model:    l.model,
+		Messages: []ChatMessage{textMessage("system", systemPrompt), user},
+		Tools: []ChatTool{{Type: "function", Function: ChatFunction{
+			Name:        req.Schema.Name,
+			Description: req.Schema.Description,
+			Parameters:  req.Schema.Schema,
+		}}},
+		ToolChoice: &ChatToolChoice{Type: "function", Function: ChatFunction{Name: req.Schema.Name}},
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, call := range rsp.Choices[0].Message.ToolCalls {
+		if call.Function.Name == req.Schema.Name {
+			return call.Function.Arguments, nil
+		}
+	}
+	return "", errNoToolCall
+}
+
+func (l *Local) generateJSON(ctx context.Context, req Request, image ChatContentPart) (string, error) {
+	schema, err := json.Marshal(req.Schema.Schema)
+	if err != nil {
+		return "", fmt.Errorf(`marshal schema: %w`, err)
+	}
+	prompt := fmt.Sprintf("%s\n\n%s. Respond only with a JSON object matching this JSON schema:\n%s",
+		req.Prompt, req.Schema.Description, schema)
+	user, err := userMessage(prompt, image)
+	if err != nil {
+		return "", err
+	}
+	rsp, err := l.complete(ctx, ChatRequest{
+		Model:    l.model,
+		Messages: []ChatMessage{textMessage("system", systemPrompt), user},
+		ResponseFormat: &ChatResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &ChatJSONSchema{Name: req.Schema.Name, Schema: req.Schema.Schema},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	var content string
+	if err := json.Unmarshal(rsp.Choices[0].Message.Content, &content); err != nil {
+		return "", fmt.Errorf(`unmarshal content: %w`, err)
+	}
+	content = stripCodeFence(content)
+	if err := ValidateJSON(req.Schema.Schema, []byte(content)); err != nil {
+		return "", fmt.Errorf(`validate %s output: %w`, req.Schema.Name, err)
+	}
+	return content, nil
+}
+
+// complete posts a chat completion. A 400 on a request with tools whose message says tools aren't supported is reported
+// as errToolsUnsupported, which is how Ollama and LM Studio reject tools for models that don't have them. Any other
+// 400 is a plain error so a malformed request isn't retried without tools.
+func (l *Local) complete(ctx context.Context, chatReq ChatRequest) (*ChatResponse, error) {
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf(`marshal request: %w`, err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, l.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf(`build request: %w`, err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if l.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+l.apiKey)
+	}
+
+	httpRsp, err := l.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("generate: %w", err)
+	}
+	defer httpRsp.Body.Close()
+	raw, err := io.ReadAll(httpRsp.Body)
+	if err != nil {
+		return nil, fmt.Errorf(`read response: %w`, err)
+	}
+	if httpRsp.StatusCode != http.StatusOK {
+		var apiErr ChatError
+		message := httpRsp.Status
+		if json.Unmarshal(raw, &apiErr) == nil && apiErr.Error.Message != "" {
+			message = httpRsp.Status + ": " + apiErr.Error.Message
+		}
+		if httpRsp.StatusCode == http.StatusBadRequest && len(chatReq.Tools) > 0 && toolsUnsupported(apiErr.Error.Message) {
+			return nil, fmt.Errorf(`generate: %s: %w`, message, errToolsUnsupported)
+		}
+		return nil, fmt.Errorf(`generate: %s`, message)
+	}
+
+	var rsp ChatResponse
+	if err := json.Unmarshal(raw, &rsp); err != nil {
+		return nil, fmt.Errorf(`unmarshal response: %w`, err)
+	}
+	if len(rsp.Choices) == 0 {
+		return nil, errors.New("no choices in response")
+	}
+	return &rsp, nil
+}
+
+// toolsUnsupported reports whether an error message says the model can't use tools, e.g. Ollama's "llava does not
+// support tools"
+func toolsUnsupported(message string) bool {
+	message = strings.ToLower(message)
+	return strings.Contains(message, "tool") &&
+		(strings.Contains(message, "not support") || strings.Contains(message, "unsupported"))
+}
+
+func textMessage(role, text string) ChatMessage {
+	content, _ := json.Marshal(text)
+	return ChatMessage{Role: role, Content: content}
+}
+
+func userMessage(prompt string, image ChatContentPart) (ChatMessage, error) {
+	content, err := json.Marshal([]ChatContentPart{{Type: "text", Text: prompt}, image})
+	if err != nil {
+		return ChatMessage{}, fmt.Errorf(`marshal content: %w`, err)
+	}
+	return ChatMessage{Role: "user", Content: content}, nil
+}
+
+// stripCodeFence removes a markdown code fence, which small models often wrap JSON in despite being asked not to
+func stripCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	s = strings.TrimPrefix(s, "json")
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+}
@@ -0,0 +1,141 @@
+package llm_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mgdunn2/cube-datahub/cubes/llm"
+	"github.com/mgdunn2/cube-datahub/cubes/llm/llmtest"
+)
+
+var boltCard = testCard{Name: "Lightning Bolt", ManaCost: "{R}"}
+
+// newTestLocal returns a reader against srv in auto mode and the recorder of every request it sends
+func newTestLocal(srv *llmtest.LocalServer) (*llm.Local, *headerRecorder) {
+	recorder := &headerRecorder{}
+	return llm.NewLocal(
+		llm.LocalWithBaseURL(srv.URL+"/v1"),
+		llm.LocalWithClient(&http.Client{Transport: recorder}),
+	), recorder
+}
+
+func generateCard(t *testing.T, reader *llm.Local) testCard {
+	t.Helper()
+	out, err := reader.Generate(context.Background(), testRequest())
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	var card testCard
+	if err := json.Unmarshal([]byte(out), &card); err != nil {
+		t.Fatalf("unmarshal %q: %v", out, err)
+	}
+	return card
+}
+
+func TestLocalToolCall(t *testing.T) {
+	srv := llmtest.NewLocalServer(llmtest.StaticResponse(boltCard))
+	defer srv.Close()
+	reader, _ := newTestLocal(srv)
+
+	if card := generateCard(t, reader); card != boltCard {
+		t.Errorf("card = %+v, want %+v", card, boltCard)
+	}
+	requests := srv.Requests()
+	if len(requests) != 1 || len(requests[0].Tools) != 1 || requests[0].ResponseFormat != nil {
+		t.Fatalf("requests = %+v, want one tool request", requests)
+	}
+	if choice := requests[0].ToolChoice; choice == nil || choice.Function.Name != "read_card" {
+		t.Errorf("tool_choice = %+v, want read_card forced", choice)
+	}
+}
+
+func TestLocalFallsBackToJSON(t *testing.T) {
+	srv := llmtest.NewLocalServer(llmtest.StaticResponse(boltCard), llmtest.LocalServerWithoutTools(),
+		llmtest.LocalServerWithContent(func(arguments []byte) string {
+			return "```json\n" + string(arguments) + "\n```"
+		}))
+	defer srv.Close()
+	reader, recorder := newTestLocal(srv)
+
+	if card := generateCard(t, reader); card != boltCard {
+		t.Errorf("card = %+v, want %+v", card, boltCard)
+	}
+	// The rejected tool request and the JSON request
+	if sent := len(recorder.headers); sent != 2 {
+		t.Errorf("sent %d requests, want 2", sent)
+	}
+	// Once the server has said tools aren't supported, they aren't tried again
+	if card := generateCard(t, reader); card != boltCard {
+		t.Errorf("card = %+v, want %+v", card, boltCard)
+	}
+	if sent := len(recorder.headers); sent != 3 {
+		t.Errorf("sent %d requests, want 3", sent)
+	}
+	for _, req := range srv.Requests() {
+		if len(req.Tools) > 0 || req.ResponseFormat == nil || req.ResponseFormat.Type != "json_schema" {
+			t.Errorf("request = %+v, want a json_schema request", req)
+		}
+	}
+}
+
+func TestLocalPlainBadRequest(t *testing.T) {
+	srv := llmtest.NewLocalServer(llmtest.StaticResponse(boltCard))
+	defer srv.Close()
+	srv.FailNext(1, http.StatusBadRequest)
+	reader, recorder := newTestLocal(srv)
+
+	_, err := reader.Generate(context.Background(), testRequest())
+	if err == nil || !strings.Contains(err.Error(), "injected failure") {
+		t.Fatalf("generate: got %v, want the server's error", err)
+	}
+	// A 400 that doesn't mention tools neither falls back nor turns tools off
+	if sent := len(recorder.headers); sent != 1 {
+		t.Errorf("sent %d requests, want 1", sent)
+	}
+	generateCard(t, reader)
+	requests := srv.Requests()
+	if len(requests) != 1 || len(requests[0].Tools) != 1 {
+		t.Errorf("requests = %+v, want one tool request", requests)
+	}
+}
+
+func TestLocalRejectsInvalidJSON(t *testing.T) {
+	srv := llmtest.NewLocalServer(llmtest.StaticResponse(boltCard), llmtest.LocalServerWithoutTools(),
+		llmtest.LocalServerWithContent(func([]byte) string {
+			return `{"mana_cost": 1}`
+		}))
+	defer srv.Close()
+	reader := llm.NewLocal(llm.LocalWithBaseURL(srv.URL+"/v1"), llm.LocalWithMode(llm.LocalModeJSON))
+
+	_, err := reader.Generate(context.Background(), testRequest())
+	if err == nil || !strings.Contains(err.Error(), "validate read_card output") {
+		t.Fatalf("generate: got %v, want a validation error", err)
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	schema := llm.GenerateSchema(testCard{})
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{"valid", `{"name": "Lightning Bolt", "mana_cost": "{R}"}`, false},
+		{"missing required", `{"mana_cost": "{R}"}`, true},
+		{"wrong type", `{"name": 3, "mana_cost": "{R}"}`, true},
+		{"extra property", `{"name": "Lightning Bolt", "mana_cost": "{R}", "power": "3"}`, true},
+		{"not an object", `["Lightning Bolt"]`, true},
+		{"not json", `Lightning Bolt`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := llm.ValidateJSON(schema, []byte(tt.raw))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateJSON(%s) = %v, want error %v", tt.raw, err, tt.wantErr)
+			}
+		})
+	}
+}
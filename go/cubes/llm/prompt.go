@@ -15,12 +15,28 @@ type ImageReader interface {
 	Generate(ctx context.Context, req Request) (string, error)
 }
 
+// DefaultOpenAiModel is the OpenAI model used unless another is configured
+const DefaultOpenAiModel = "gpt-4o"
+
 type OpenAi struct {
 	client openai.Client
+	model  string
+}
+
+type OpenAiOpts func(*OpenAi)
+
+func OpenAiWithModel(model string) OpenAiOpts {
+	return func(o *OpenAi) {
+		o.model = model
+	}
 }
 
-func NewOpenAi(client openai.Client) OpenAi {
-	return OpenAi{client: client}
+func NewOpenAi(client openai.Client, opts ...OpenAiOpts) OpenAi {
+	o := OpenAi{client: client, model: DefaultOpenAiModel}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
 }
 
 type Request struct {
@@ -60,14 +76,14 @@ func (o OpenAi) Generate(ctx context.Context, req Request) (string, error) {
 		return "", errors.New("no image provided")
 	}
 	res, err := o.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
-		Model: "gpt-4o",
+		Model: o.model,
 		Messages: []openai.ChatCompletionMessageParamUnion{
 			{
 				OfSystem: &openai.ChatCompletionSystemMessageParam{
 					Content: openai.ChatCompletionSystemMessageParamContentUnion{
 						OfArrayOfContentParts: []openai.ChatCompletionContentPartTextParam{
 							{
-								Text: "You are a helpful assistant whose job it is to assist with image classification. Use the provided tools to help classify the images",
+								Text: systemPrompt,
 							},
 						},
 					},
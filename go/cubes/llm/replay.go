@@ -0,0 +1,185 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Modes a Recorder runs in
+const (
+	// RecordModeRecord calls the wrapped reader and writes each request and response to the fixture directory
+	RecordModeRecord = "record"
+	// RecordModeReplay serves responses from the fixture directory and never calls the wrapped reader
+	RecordModeReplay = "replay"
+)
+
+// ErrNotRecorded is returned in replay mode for a request with no fixture
+var ErrNotRecorded = errors.New("request not recorded")
+
+// ImageHash identifies the image of a request: the sha256 of its bytes, or of its URL when it has none
+func ImageHash(req Request) string {
+	var sum [sha256.Size]byte
+	if len(req.ImageByes) > 0 {
+		sum = sha256.Sum256(req.ImageByes)
+	} else {
+		sum = sha256.Sum256([]byte("url:" + req.ImageURL))
+	}
+	return hex.EncodeToString(sum[:])
+}
+
+// RequestKey hashes everything that shapes a response: the prompt, the image and the tool schema. Extra parts, such as
+// the model, are hashed in too.
+func RequestKey(req Request, extra ...string) (string, error) {
+	schema, err := json.Marshal(req.Schema.Schema)
+	if err != nil {
+		return "", fmt.Errorf(`marshal schema: %w`, err)
+	}
+	parts := append([]string{req.Prompt, ImageHash(req), req.Schema.Name, req.Schema.Description, string(schema)}, extra...)
+	h := sha256.New()
+	for _, part := range parts {
+		// length prefixes keep parts from running into each other
+		fmt.Fprintf(h, "%d:%s\n", len(part), part)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Recording is a fixture file: what was asked and what came back
+type Recording struct {
+	Prompt      string          `json:"prompt"`
+	ImageURL    string          `json:"imageUrl,omitempty"`
+	ImageSHA256 string          `json:"imageSha256"`
+	Schema      ToolSchema      `json:"schema"`
+	Response    json.RawMessage `json:"response"`
+}
+
+// Recorder wraps an ImageReader so its requests can be recorded once and replayed without network access, letting the
+// card and deck readers run in CI without an API key. Fixtures are named after the tool and the request key, so a
+// prompt or schema change shows up as a new fixture.
+type Recorder struct {
+	reader ImageReader
+	dir    string
+	mode   string
+}
+
+// NewRecorder records reader's responses into dir, or replays them from it. reader may be nil in replay mode.
+func NewRecorder(reader ImageReader, dir string, mode string) (*Recorder, error) {
+	switch mode {
+	case RecordModeRecord:
+		if reader == nil {
+			return nil, errors.New("recording needs a reader")
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf(`create fixture dir: %w`, err)
+		}
+	case RecordModeReplay:
+	default:
+		return nil, fmt.Errorf(`unknown record mode %q`, mode)
+	}
+	return &Recorder{reader: reader, dir: dir, mode: mode}, nil
+}
+
+// Path returns the fixture file for req
+func (r *Recorder) Path(req Request) (string, error) {
+	key, err := RequestKey(req)
+	if err != nil {
+		return "", err
+	}
+	name := req.Schema.Name
+	if name == "" {
+		name = "request"
+	}
+	return filepath.Join(r.dir, fmt.Sprintf("%s-%s.json", name, key[:16])), nil
+}
+
+func (r *Recorder) Generate(ctx context.Context, req Request) (string, error) {
+	path, err := r.Path(req)
+	if err != nil {
+		return "", err
+	}
+	if r.mode == RecordModeReplay {
+		return r.replay(path, req)
+	}
+
+	out, err := r.reader.Generate(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if err := r.record(path, req, out); err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+func (r *Recorder) replay(path string, req Request) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		prompt := req.Prompt
+		if len(prompt) > 60 {
+			prompt = prompt[:60] + "..."
+		}
+		return "", fmt.Errorf(`%w: %s tool, image %s, prompt %q: no fixture at %s, re-run in %s mode to create it`,
+			ErrNotRecorded, req.Schema.Name, ImageHash(req)[:12], prompt, path, RecordModeRecord)
+	}
+	if err != nil {
+		return "", fmt.Errorf(`read fixture: %w`, err)
+	}
+	var recording Recording
+	if err := json.Unmarshal(data, &recording); err != nil {
+		return "", fmt.Errorf(`unmarshal fixture %s: %w`, path, err)
+	}
+	var response string
+	if json.Unmarshal(recording.Response, &response) == nil {
+		return response, nil
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, recording.Response); err != nil {
+		return "", fmt.Errorf(`compact fixture %s response: %w`, path, err)
+	}
+	return compact.String(), nil
+}
+
+// record writes the fixture. JSON object and array responses are stored as JSON so fixture diffs are readable;
+// anything else is stored as a string.
+func (r *Recorder) record(path string, req Request, out string) error {
+	response := json.RawMessage(out)
+	if trimmed := strings.TrimSpace(out); !json.Valid(response) || !(strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) {
+		var err error
+		if response, err = json.Marshal(out); err != nil {
+			return fmt.Errorf(`marshal response: %w`, err)
+		}
+	}
+	data, err := json.MarshalIndent(Recording{
+		Prompt:      req.Prompt,
+		ImageURL:    req.ImageURL,
+		ImageSHA256: ImageHash(req),
+		Schema:      req.Schema,
+		Response:    response,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf(`marshal fixture: %w`, err)
+	}
+	tmp, err := os.CreateTemp(r.dir, ".fixture-*")
+	if err != nil {
+		return fmt.Errorf(`create fixture: %w`, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf(`write fixture: %w`, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf(`write fixture: %w`, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf(`write fixture: %w`, err)
+	}
+	return nil
+}
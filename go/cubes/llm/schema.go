@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// ValidateJSON checks raw against a JSON schema of the kind GenerateSchema produces: objects with properties, required
+// fields and additionalProperties, arrays with items, and the scalar types. Other keywords are ignored.
+func ValidateJSON(schema map[string]any, raw []byte) error {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return fmt.Errorf(`not JSON: %w`, err)
+	}
+	return validateValue(schema, v, "$")
+}
+
+func validateValue(schema map[string]any, v any, path string) error {
+	if err := checkType(schema["type"], v, path); err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		if required, ok := schema["required"].([]any); ok {
+			for _, name := range required {
+				if _, ok := value[fmt.Sprint(name)]; !ok {
+					return fmt.Errorf(`%s: missing required field %s`, path, name)
+				}
+			}
+		}
+		for name, field := range value {
+			fieldSchema, ok := properties[name].(map[string]any)
+			if !ok {
+				if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+					return fmt.Errorf(`%s: unexpected field %s`, path, name)
+				}
+				continue
+			}
+			if err := validateValue(fieldSchema, field, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case []any:
+		items, ok := schema["items"].(map[string]any)
+		if !ok {
+			return nil
+		}
+		for i, item := range value {
+			if err := validateValue(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkType checks v against a type keyword, which is either a type name or a list of them
+func checkType(schemaType any, v any, path string) error {
+	var allowed []string
+	switch t := schemaType.(type) {
+	case nil:
+		return nil
+	case string:
+		allowed = []string{t}
+	case []any:
+		for _, name := range t {
+			allowed = append(allowed, fmt.Sprint(name))
+		}
+	}
+	actual := jsonType(v)
+	if slices.Contains(allowed, actual) || (actual == "integer" && slices.Contains(allowed, "number")) {
+		return nil
+	}
+	return fmt.Errorf(`%s: expected %v, got %s`, path, schemaType, actual)
+}
+
+func jsonType(v any) string {
+	switch value := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if value == float64(int64(value)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -1,6 +1,10 @@
 package cubes
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +20,13 @@ const (
 	Green Color = "G"
 )
 
+// Board names used by CubeCobra. AllBoards can be passed to Storage.GetCube to load every board.
+const (
+	MainBoard  = "mainboard"
+	MaybeBoard = "maybeboard"
+	AllBoards  = "*"
+)
+
 type Card struct {
 	ID          string    `json:"id"`
 	Name        string    `json:"name"`
@@ -33,14 +44,88 @@ type Card struct {
 	Set         string    `json:"set"`
 	ReleaseDate time.Time `json:"release_date"`
 	ImageURI    string    `json:"image_uri"`
+	// ImageRef is the content hash of the locally stored copy of the image at ImageURI, empty until it is synced
+	ImageRef string `json:"image_ref,omitempty"`
+	// MTGOID is the card's Magic Online catalog ID, 0 when the printing isn't on MTGO
+	MTGOID int `json:"mtgo_id,omitempty"`
+	// CollectorNumber is the printing's number within its set, empty for custom cards
+	CollectorNumber string `json:"collector_number,omitempty"`
+}
+
+// CustomSet is the set given to custom cards read from an image
+const CustomSet = "custom"
+
+// IsCustom reports whether the card is a custom card rather than a real printing
+func (c Card) IsCustom() bool {
+	return strings.EqualFold(c.Set, CustomSet)
 }
 
+// TypeLine rebuilds the printed type line, e.g. "Legendary Creature — Elf Warrior"
+func (c Card) TypeLine() string {
+	typeLine := strings.Join(append(slices.Clone(c.SuperType), c.Type), " ")
+	typeLine = strings.TrimSpace(typeLine)
+	if len(c.SubType) > 0 {
+		typeLine += " — " + strings.Join(c.SubType, " ")
+	}
+	return typeLine
+}
+
+// Cube is a single version of a cube. Cards holds the mainboard and Boards holds any other boards, such as the
+// maybeboard, keyed by board name. Tags maps card ID to the designer's tags for that card on the mainboard in this
+// version, and BoardTags does the same for the other boards.
 type Cube struct {
+	ID            string                         `json:"id"`
+	Name          string                         `json:"name"`
+	VersionNumber int                            `json:"version_number"`
+	Cards         []Card                         `json:"cards"`
+	Boards        map[string][]Card              `json:"boards,omitempty"`
+	Tags          map[string][]string            `json:"tags"`
+	BoardTags     map[string]map[string][]string `json:"board_tags,omitempty"`
+	Date          time.Time                      `json:"date"`
+}
+
+// Board returns the cards on the named board
+func (c Cube) Board(name string) []Card {
+	if name == MainBoard {
+		return c.Cards
+	}
+	return c.Boards[name]
+}
+
+// TagsOn returns the tags of the cards on the named board, keyed by card ID
+func (c Cube) TagsOn(name string) map[string][]string {
+	if name == MainBoard {
+		return c.Tags
+	}
+	return c.BoardTags[name]
+}
+
+// CustomCard is a card that was read from an image rather than pulled from Scryfall. PHash is the perceptual hash of
+// the image, nil until it has been computed.
+type CustomCard struct {
+	ImageURL string  `json:"imageUrl"`
+	Card     Card    `json:"card"`
+	Verified bool    `json:"verified"`
+	PHash    *uint64 `json:"phash,omitempty"`
+}
+
+// Outcomes of a SyncRun
+const (
+	SyncUnchanged  = "unchanged"
+	SyncNewVersion = "new_version"
+	SyncFailed     = "failed"
+)
+
+// SyncRun records one attempt by the sync service to load a cube. VersionNumber is the latest version after the run.
+type SyncRun struct {
 	ID            string    `json:"id"`
-	Name          string    `json:"name"`
-	VersionNumber int       `json:"version_number"`
-	Cards         []Card    `json:"cards"`
-	Date          time.Time `json:"date"`
+	CubeID        string    `json:"cubeId"`
+	Source        string    `json:"source"`
+	StartedAt     time.Time `json:"startedAt"`
+	FinishedAt    time.Time `json:"finishedAt"`
+	Outcome       string    `json:"outcome"`
+	VersionNumber *int      `json:"versionNumber,omitempty"`
+	Error         string    `json:"error,omitempty"`
 }
 
 type Player struct {
@@ -54,32 +139,42 @@ type Event struct {
 	Date time.Time `json:"date"`
 }
 
+// Deck is a player's deck from an event. Cards is the maindeck; both it and Sideboard hold one entry per copy.
 type Deck struct {
 	ID          string `json:"id"`
 	PlayerID    string `json:"playerId"`
 	Event       Event  `json:"event"`
 	Cards       []Card `json:"cards"`
+	Sideboard   []Card `json:"sideboard"`
 	Description string `json:"description"`
 }
 
+// Deck boards as stored with deck cards
+const (
+	DeckMain = "main"
+	DeckSide = "side"
+)
+
 // All third party models and conversions
 
 type ScryfallCard struct {
-	ID         string             `json:"id"`
-	Name       string             `json:"name"`
-	ManaCost   *string            `json:"mana_cost"`
-	Cmc        float64            `json:"cmc"`
-	TypeLine   string             `json:"type_line"`
-	OracleText string             `json:"oracle_text"`
-	Power      *string            `json:"power"`
-	Toughness  *string            `json:"toughness"`
-	Loyalty    *string            `json:"loyalty"`
-	Defense    *string            `json:"defense"`
-	Colors     []string           `json:"colors"`
-	Set        string             `json:"set"`
-	ReleasedAt string             `json:"released_at"`
-	ImageURIs  *ScryfallImageURIs `json:"image_uris"`
-	CardFaces  []ScryfallCardFace `json:"card_faces"`
+	ID              string             `json:"id"`
+	Name            string             `json:"name"`
+	ManaCost        *string            `json:"mana_cost"`
+	Cmc             float64            `json:"cmc"`
+	TypeLine        string             `json:"type_line"`
+	OracleText      string             `json:"oracle_text"`
+	Power           *string            `json:"power"`
+	Toughness       *string            `json:"toughness"`
+	Loyalty         *string            `json:"loyalty"`
+	Defense         *string            `json:"defense"`
+	Colors          []string           `json:"colors"`
+	Set             string             `json:"set"`
+	CollectorNumber string             `json:"collector_number"`
+	MTGOID          *int               `json:"mtgo_id"`
+	ReleasedAt      string             `json:"released_at"`
+	ImageURIs       *ScryfallImageURIs `json:"image_uris"`
+	CardFaces       []ScryfallCardFace `json:"card_faces"`
 }
 
 type ScryfallCardFace struct {
@@ -131,23 +226,27 @@ func (s ScryfallCard) ToCard() (Card, error) {
 		}
 	}
 
-	superTypes, cardType, subTypes := parseTypeLine(typeLine)
+	superTypes, cardType, subTypes := ParseTypeLine(typeLine)
 
 	card := Card{
-		ID:        s.ID,
-		Name:      name,
-		ManaCost:  manaCost,
-		ManaValue: int(s.Cmc),
-		Type:      cardType,
-		SuperType: superTypes,
-		SubType:   subTypes,
-		TextBox:   oracleText,
-		Set:       s.Set,
+		ID:              s.ID,
+		Name:            name,
+		ManaCost:        manaCost,
+		ManaValue:       int(s.Cmc),
+		Type:            cardType,
+		SuperType:       superTypes,
+		SubType:         subTypes,
+		TextBox:         oracleText,
+		Set:             s.Set,
+		CollectorNumber: s.CollectorNumber,
 	}
 
 	if imageURIs != nil {
 		card.ImageURI = imageURIs.Normal
 	}
+	if s.MTGOID != nil {
+		card.MTGOID = *s.MTGOID
+	}
 
 	if t, err := time.Parse("2006-01-02", s.ReleasedAt); err == nil {
 		card.ReleaseDate = t
@@ -183,6 +282,112 @@ func (s ScryfallCard) ToCard() (Card, error) {
 	return card, nil
 }
 
+// MTGJSONCard is a card record from MTGJSON's AllPrintings file. Multi-faced cards have one record per
+// face with Side set.
+type MTGJSONCard struct {
+	Name        string             `json:"name"`
+	FaceName    string             `json:"faceName"`
+	ManaCost    *string            `json:"manaCost"`
+	ManaValue   float64            `json:"manaValue"`
+	Type        string             `json:"type"`
+	Text        string             `json:"text"`
+	Power       *string            `json:"power"`
+	Toughness   *string            `json:"toughness"`
+	Loyalty     *string            `json:"loyalty"`
+	Defense     *string            `json:"defense"`
+	Colors      []string           `json:"colors"`
+	SetCode     string             `json:"setCode"`
+	Number      string             `json:"number"`
+	Side        string             `json:"side"`
+	Identifiers MTGJSONIdentifiers `json:"identifiers"`
+}
+
+type MTGJSONIdentifiers struct {
+	ScryfallID string `json:"scryfallId"`
+	MTGOID     string `json:"mtgoId"`
+}
+
+// CardID returns the ID the card is stored under, its Scryfall ID
+func (m MTGJSONCard) CardID() string {
+	return m.Identifiers.ScryfallID
+}
+
+// ToCard converts an MTGJSON card into a domain-level Card the same way ScryfallCard.ToCard does, using the front face
+// for multi-faced cards. MTGJSON has no release date on card records so it is taken from the set.
+func (m MTGJSONCard) ToCard(releaseDate string) (Card, error) {
+	id := m.CardID()
+	if id == "" {
+		return Card{}, fmt.Errorf(`%s has no scryfall ID`, m.Name)
+	}
+	name := m.Name
+	if m.FaceName != "" {
+		name = m.FaceName
+	}
+
+	superTypes, cardType, subTypes := ParseTypeLine(m.Type)
+
+	card := Card{
+		ID:              id,
+		Name:            name,
+		ManaCost:        m.ManaCost,
+		ManaValue:       int(m.ManaValue),
+		Type:            cardType,
+		SuperType:       superTypes,
+		SubType:         subTypes,
+		TextBox:         m.Text,
+		Set:             strings.ToLower(m.SetCode),
+		CollectorNumber: m.Number,
+	}
+
+	card.ImageURI = ScryfallImageURL(id)
+	if mtgoID, err := strconv.Atoi(m.Identifiers.MTGOID); err == nil {
+		card.MTGOID = mtgoID
+	}
+
+	if releaseDate != "" {
+		t, err := time.Parse("2006-01-02", releaseDate)
+		if err != nil {
+			return Card{}, err
+		}
+		card.ReleaseDate = t
+	}
+
+	if m.Power != nil {
+		if p, err := parseIntValue(*m.Power); err == nil {
+			card.Power = p
+		}
+	}
+	if m.Toughness != nil {
+		if t, err := parseIntValue(*m.Toughness); err == nil {
+			card.Toughness = t
+		}
+	}
+	if m.Loyalty != nil {
+		if l, err := parseIntValue(*m.Loyalty); err == nil {
+			card.Loyalty = l
+		}
+	}
+	if m.Defense != nil {
+		if d, err := parseIntValue(*m.Defense); err == nil {
+			card.Defense = d
+		}
+	}
+
+	for _, c := range m.Colors {
+		card.Colors = append(card.Colors, Color(c))
+	}
+
+	return card, nil
+}
+
+// ScryfallImageURL returns the normal sized front image Scryfall serves for a card ID
+func ScryfallImageURL(scryfallID string) string {
+	if len(scryfallID) < 2 {
+		return ""
+	}
+	return fmt.Sprintf("https://cards.scryfall.io/normal/front/%c/%c/%s.jpg", scryfallID[0], scryfallID[1], scryfallID)
+}
+
 // LLMCardSchema exists purely for being converted into an OpenAI request json schema
 type LLMCardSchema struct {
 	ID         string   `json:"id"`
@@ -202,7 +407,7 @@ type LLMCardSchema struct {
 
 // ToCard converts a ScryfallCard into a domain-level Card model.
 func (s LLMCardSchema) ToCard() (Card, error) {
-	superTypes, cardType, subTypes := parseTypeLine(s.TypeLine)
+	superTypes, cardType, subTypes := ParseTypeLine(s.TypeLine)
 
 	card := Card{
 		ID:        s.ID,
@@ -236,7 +441,8 @@ func (s LLMCardSchema) ToCard() (Card, error) {
 	return card, nil
 }
 
-func parseTypeLine(typeLine string) (superTypes []string, cardType string, subTypes []string) {
+// ParseTypeLine splits a type line into its super types, card type and sub types
+func ParseTypeLine(typeLine string) (superTypes []string, cardType string, subTypes []string) {
 	// Example typeLine: "Legendary Creature — Elf Warrior"
 	parts := strings.Split(typeLine, "—")
 	left := strings.Fields(strings.TrimSpace(parts[0]))
@@ -282,8 +488,36 @@ type CubeCobraCube struct {
 	Cards CubeCobraCards `json:"cards"`
 }
 
+// CubeCobraCards holds the boards of a CubeCobra cube. Boards contains every board in the response keyed by name,
+// including the main and maybe boards.
 type CubeCobraCards struct {
-	MainBoard []CubeCobraCard `json:"mainboard"`
+	MainBoard  []CubeCobraCard            `json:"mainboard"`
+	MaybeBoard []CubeCobraCard            `json:"maybeboard"`
+	Boards     map[string][]CubeCobraCard `json:"-"`
+}
+
+func (c *CubeCobraCards) UnmarshalJSON(b []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	c.Boards = make(map[string][]CubeCobraCard, len(raw))
+	for name, value := range raw {
+		// Not every key under cards is a board, but anything that is an array, and the main and maybe boards whatever
+		// they are, must decode or a changed format would be stored as an empty cube
+		isArray := bytes.HasPrefix(bytes.TrimSpace(value), []byte("["))
+		if !isArray && name != MainBoard && name != MaybeBoard {
+			continue
+		}
+		var board []CubeCobraCard
+		if err := json.Unmarshal(value, &board); err != nil {
+			return fmt.Errorf(`decode %s: %w`, name, err)
+		}
+		c.Boards[name] = board
+	}
+	c.MainBoard = c.Boards[MainBoard]
+	c.MaybeBoard = c.Boards[MaybeBoard]
+	return nil
 }
 
 type CubeCobraCard struct {
@@ -0,0 +1,62 @@
+package cubes
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCubeCobraCardsUnmarshal(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		wantErr   bool
+		wantMain  int
+		wantMaybe int
+		boards    int
+	}{
+		{
+			name:      "boards and other keys",
+			raw:       `{"mainboard": [{"cardID": "a"}, {"cardID": "b"}], "maybeboard": [{"cardID": "c"}], "tokens": [], "id": "x", "count": 3}`,
+			wantMain:  2,
+			wantMaybe: 1,
+			boards:    3,
+		},
+		{
+			name:     "no maybeboard",
+			raw:      `{"mainboard": [{"cardID": "a"}]}`,
+			wantMain: 1,
+			boards:   1,
+		},
+		{
+			name:    "board of the wrong shape",
+			raw:     `{"mainboard": [{"cardID": "a"}], "sideboard": [{"cardID": 7}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "mainboard is not an array",
+			raw:     `{"mainboard": {"cards": [{"cardID": "a"}]}}`,
+			wantErr: true,
+		},
+		{
+			name:    "maybeboard is not an array",
+			raw:     `{"mainboard": [], "maybeboard": "none"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cards CubeCobraCards
+			err := json.Unmarshal([]byte(tt.raw), &cards)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unmarshal: got %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(cards.MainBoard) != tt.wantMain || len(cards.MaybeBoard) != tt.wantMaybe || len(cards.Boards) != tt.boards {
+				t.Errorf("got %d main, %d maybe and %d boards, want %d, %d and %d", len(cards.MainBoard),
+					len(cards.MaybeBoard), len(cards.Boards), tt.wantMain, tt.wantMaybe, tt.boards)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package cubes
+
+import (
+	"slices"
+	"strings"
+)
+
+// CardQuery filters the cards of a cube. Empty fields match everything; Tags and Colors must all be present on a card.
+type CardQuery struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`
+	Colors []Color  `json:"colors"`
+	Tags   []string `json:"tags"`
+}
+
+// Search returns the cards in the cube matching the query
+func (c Cube) Search(q CardQuery) []Card {
+	var matches []Card
+	for _, card := range c.Cards {
+		if q.matches(card, c.Tags[card.ID]) {
+			matches = append(matches, card)
+		}
+	}
+	return matches
+}
+
+// CardsWithTag returns the cards in the cube tagged with tag
+func (c Cube) CardsWithTag(tag string) []Card {
+	return c.Search(CardQuery{Tags: []string{tag}})
+}
+
+func (q CardQuery) matches(card Card, tags []string) bool {
+	if q.Name != "" && !strings.Contains(strings.ToLower(card.Name), strings.ToLower(q.Name)) {
+		return false
+	}
+	if q.Type != "" && !strings.Contains(strings.ToLower(card.TypeLine()), strings.ToLower(q.Type)) {
+		return false
+	}
+	for _, color := range q.Colors {
+		if !slices.Contains(card.Colors, color) {
+			return false
+		}
+	}
+	for _, tag := range q.Tags {
+		if !slices.ContainsFunc(tags, func(t string) bool { return strings.EqualFold(t, tag) }) {
+			return false
+		}
+	}
+	return true
+}
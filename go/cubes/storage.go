@@ -15,21 +15,61 @@ type Storage interface {
 	// UpsertCards upserts a set of cards
 	UpsertCards(ctx context.Context, cards []Card) error
 
+	// GetCachedCardIDs returns the card ID previously resolved for each of the names, keyed by lower-cased name. Names
+	// that have not been resolved are left out.
+	GetCachedCardIDs(ctx context.Context, names []string) (map[string]string, error)
+
+	// CacheCardIDs records the card ID that each name resolved to
+	CacheCardIDs(ctx context.Context, cardIDsByName map[string]string) error
+
+	// GetCardsMissingImageRef returns up to limit cards with an image URI but no stored image, ordered by ID and
+	// starting after afterID
+	GetCardsMissingImageRef(ctx context.Context, afterID string, limit int) ([]Card, error)
+
+	// SetCardImageRef records the locally stored image for a card
+	SetCardImageRef(ctx context.Context, cardID, imageRef string) error
+
 	// AddCustomCard adds a mapping from an imageURL to the cardID for that card
 	AddCustomCard(ctx context.Context, imageURL, cardID string) error
 
 	// GetAllCustomCardIDs returns all custom card ID mappings ImageURL -> CardID
 	GetAllCustomCardIDs(ctx context.Context) (map[string]string, error)
 
+	// GetCustomCards returns every custom card along with its source image URL and verification status
+	GetCustomCards(ctx context.Context) ([]CustomCard, error)
+
+	// GetCustomCard returns the custom card with the given card ID or nil if there is none
+	GetCustomCard(ctx context.Context, cardID string) (*CustomCard, error)
+
+	// SetCustomCardVerified marks whether a human has checked a custom card against its image
+	SetCustomCardVerified(ctx context.Context, cardID string, verified bool) error
+
+	// SetCustomCardPHash records the perceptual hash of a custom card image
+	SetCustomCardPHash(ctx context.Context, imageURL string, phash uint64) error
+
+	// MergeCustomCards replaces every use of the mergeIDs custom cards with keepID, in cubes, decks and image mappings,
+	// and deletes the merged cards
+	MergeCustomCards(ctx context.Context, keepID string, mergeIDs []string) error
+
 	// UpdateCube adds a new version of the cube
 	UpdateCube(ctx context.Context, cube Cube) error
 
-	// GetCube returns the cube at the specified version or the most recent if no version is provided
-	GetCube(ctx context.Context, id string, version *int) (*Cube, error)
+	// GetCube returns the cube at the specified version or the most recent if no version is provided. Only the
+	// mainboard is loaded unless boards are named; pass AllBoards to load every board.
+	GetCube(ctx context.Context, id string, version *int, boards ...string) (*Cube, error)
+
+	// GetLatestCubeVersion returns the latest version number of a cube or nil if it has never been loaded
+	GetLatestCubeVersion(ctx context.Context, cubeID string) (*int, error)
+
+	// RecordSyncRun records the outcome of a sync service run
+	RecordSyncRun(ctx context.Context, run SyncRun) error
 
 	// RecordEvent stores a cube event
 	RecordEvent(ctx context.Context, event Event) error
 
 	// RecordDeck stores a deck
 	RecordDeck(ctx context.Context, deck Deck) error
+
+	// GetDeck returns a deck along with its event and the event's cube version, or nil if there is no such deck
+	GetDeck(ctx context.Context, id string) (*Deck, error)
 }